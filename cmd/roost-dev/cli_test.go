@@ -3,7 +3,10 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/panozzaj/roost-dev/internal/config"
 )
 
 func TestCheckHelpFlag(t *testing.T) {
@@ -254,3 +257,98 @@ func TestFindSimilarNames(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatEnv(t *testing.T) {
+	t.Run("single process prints only variables", func(t *testing.T) {
+		got := formatEnv([]processEnv{{Name: "myapp", Env: []string{"FORCE_COLOR=1", "PORT=5000"}}})
+		if want := "FORCE_COLOR=1\nPORT=5000\n"; got != want {
+			t.Errorf("formatEnv = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("services get headers and multi-line values are quoted", func(t *testing.T) {
+		got := formatEnv([]processEnv{
+			{Name: "api-myapp", Service: "api", Env: []string{"KEY=line1\nline2"}},
+			{Name: "web-myapp", Service: "web", Env: []string{"PORT=5001"}},
+		})
+		want := "# api (api-myapp)\nKEY=\"line1\\nline2\"\n\n# web (web-myapp)\nPORT=5001\n"
+		if got != want {
+			t.Errorf("formatEnv = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestFormatRestarted(t *testing.T) {
+	if got, want := formatRestarted("myapp:api", []string{"api-myapp"}), "myapp:api restarted\n"; got != want {
+		t.Errorf("formatRestarted = %q, want %q", got, want)
+	}
+	if got, want := formatRestarted("myapp", nil), "myapp restarted\n"; got != want {
+		t.Errorf("formatRestarted = %q, want %q", got, want)
+	}
+	got := formatRestarted("myapp:api", []string{"api-myapp", "web-myapp"})
+	if want := "myapp:api restarted: api-myapp, web-myapp\n"; got != want {
+		t.Errorf("formatRestarted = %q, want %q", got, want)
+	}
+}
+
+func TestFormatProfiles(t *testing.T) {
+	if got, want := formatProfiles(&profileInfo{App: "shop"}), "shop has no profiles\n"; got != want {
+		t.Errorf("formatProfiles = %q, want %q", got, want)
+	}
+	info := &profileInfo{App: "shop", Profile: "prod", Profiles: []profileEntry{{Name: "prod"}, {Name: "staging", Description: "Staging DB"}}}
+	want := "  default              " + colorGray + "the config as written" + colorReset + "\n" +
+		colorGreen + "*" + colorReset + " prod\n" +
+		"  staging              " + colorGray + "Staging DB" + colorReset + "\n"
+	if got := formatProfiles(info); got != want {
+		t.Errorf("formatProfiles = %q, want %q", got, want)
+	}
+
+	info.Restarted = []string{"web-shop"}
+	info.Stopped = []string{"jobs-shop"}
+	if got, want := formatProfileSwitch(info), "shop now uses profile prod\n  restarted: web-shop\n  stopped: jobs-shop\n"; got != want {
+		t.Errorf("formatProfileSwitch = %q, want %q", got, want)
+	}
+	if got, want := formatProfileSwitch(&profileInfo{App: "shop"}), "shop now uses profile default\n"; got != want {
+		t.Errorf("formatProfileSwitch = %q, want %q", got, want)
+	}
+}
+
+func TestFormatPS(t *testing.T) {
+	entries := []psEntry{{
+		App:     "myapp",
+		Service: "web",
+		Port:    50001,
+		Tree: psNode{PID: 100, Command: "bash -c bin/dev", RSS: 2 << 20, Children: []psNode{
+			{PID: 101, Command: "node server.js", RSS: 150 << 20, Children: []psNode{{PID: 103, Command: "esbuild", RSS: 20 << 20}}},
+			{PID: 102, Command: "ruby worker.rb", RSS: 1536 << 20},
+		}},
+	}}
+	want := colorCyan + "myapp:web" + colorReset + "  port 50001\n" +
+		"      100    2.0M  bash -c bin/dev\n" +
+		"      101  150.0M  ├─ node server.js\n" +
+		"      103   20.0M  │  └─ esbuild\n" +
+		"      102    1.5G  └─ ruby worker.rb\n"
+	if got := formatPS(entries); got != want {
+		t.Errorf("formatPS =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatDiagnostics(t *testing.T) {
+	var b strings.Builder
+	formatDiagnostics(&b, nil)
+	if got, want := b.String(), "No problems found\n"; got != want {
+		t.Errorf("formatDiagnostics = %q, want %q", got, want)
+	}
+
+	b.Reset()
+	formatDiagnostics(&b, []config.Diagnostic{
+		{File: "/cfg/shop.yml", Line: 3, Column: 5, Severity: config.SeverityError, Message: "root /nope doesn't exist"},
+		{File: "/cfg/shop.yml", Line: 9, Column: 7, Severity: config.SeverityWarning, Message: `unknown key "typ" is ignored`},
+	})
+	want := colorRed + "/cfg/shop.yml:3:5: error: root /nope doesn't exist" + colorReset + "\n" +
+		colorYellow + `/cfg/shop.yml:9:7: warning: unknown key "typ" is ignored` + colorReset + "\n" +
+		"\n1 error, 1 warning\n"
+	if got := b.String(); got != want {
+		t.Errorf("formatDiagnostics =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -13,6 +13,7 @@ type GlobalConfig struct {
 	TLD           string        `json:"tld"`
 	Ollama        *OllamaConfig `json:"ollama,omitempty"`
 	ClaudeCommand string        `json:"claude_command,omitempty"` // Command to run Claude Code (default: "claude")
+	IdleTimeout   string        `json:"idle_timeout,omitempty"`   // Stop apps after no requests for this long, e.g. "30m"
 }
 
 // OllamaConfig stores settings for local LLM error analysis
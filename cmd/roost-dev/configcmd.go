@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/panozzaj/roost-dev/internal/config"
+)
+
+func cmdConfig(args []string) {
+	if len(args) == 0 {
+		printConfigUsage()
+		os.Exit(0)
+	}
+
+	subcmd := args[0]
+	subargs := args[1:]
+
+	switch subcmd {
+	case "validate":
+		cmdConfigValidate(subargs)
+	case "schema":
+		cmdConfigSchema(subargs)
+	case "-h", "--help", "help":
+		printConfigUsage()
+		os.Exit(0)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown config command: %s\n\n", subcmd)
+		printConfigUsage()
+		os.Exit(1)
+	}
+}
+
+func printConfigUsage() {
+	fmt.Println(`roost-dev config - Check app configs
+
+USAGE:
+    roost-dev config <command>
+
+COMMANDS:
+    validate    Report problems in the app configs
+    schema      Print the JSON Schema of the YAML config format
+
+EXAMPLES:
+    roost-dev config validate     # Check every config in ~/.config/roost-dev
+    roost-dev config schema > roost-dev.schema.json`)
+}
+
+func cmdConfigValidate(args []string) {
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+	configDir := fs.String("dir", getDefaultConfigDir(), "Configuration directory")
+
+	fs.Usage = func() {
+		fmt.Println(`roost-dev config validate - Report problems in the app configs
+
+USAGE:
+    roost-dev config validate [options]
+
+OPTIONS:
+  --json            Output in JSON format
+  --dir DIR         Configuration directory (default: ~/.config/roost-dev)
+
+Loads every config the way the server does and prints each problem as
+file:line:column: severity: message. Errors are configs (or services)
+that don't load or can't work: invalid values, missing directories,
+unknown depends_on targets, dependency cycles, and hostnames claimed
+twice or reserved by roost-dev. Warnings are things that are ignored or
+probably unintended, like unknown keys and a missing root.
+
+The dashboard and /api/status show the same problems.
+
+Exits with status 1 if there are errors.`)
+	}
+
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" || arg == "help" {
+			fs.Usage()
+			os.Exit(0)
+		}
+	}
+	fs.Parse(args)
+
+	globalCfg, _ := loadGlobalConfig(*configDir)
+	if globalCfg == nil {
+		globalCfg = &GlobalConfig{TLD: "test"}
+	}
+	diags, err := config.Validate(&config.Config{Dir: *configDir, TLD: globalCfg.TLD})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		if diags == nil {
+			diags = []config.Diagnostic{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		enc.Encode(diags)
+	} else {
+		formatDiagnostics(os.Stdout, diags)
+	}
+	if config.HasErrors(diags) {
+		os.Exit(1)
+	}
+}
+
+func cmdConfigSchema(args []string) {
+	usage := `roost-dev config schema - Print the JSON Schema of the YAML config format
+
+USAGE:
+    roost-dev config schema
+
+The schema is generated from the same definition the config loader uses,
+and a running roost-dev also serves it at http://roost-dev.test/schema.json
+(with your TLD). Point your editor's YAML language server at it for
+completion and checking, e.g. with this first line in a config:
+
+    # yaml-language-server: $schema=http://roost-dev.test/schema.json`
+
+	if checkHelpFlag(args, usage) {
+		return
+	}
+	os.Stdout.Write(config.Schema())
+}
+
+// formatDiagnostics writes one line per diagnostic and a count at the end
+func formatDiagnostics(w io.Writer, diags []config.Diagnostic) {
+	if len(diags) == 0 {
+		fmt.Fprintln(w, "No problems found")
+		return
+	}
+	errors, warnings := 0, 0
+	for _, d := range diags {
+		color := colorYellow
+		if d.Severity == config.SeverityError {
+			color = colorRed
+			errors++
+		} else {
+			warnings++
+		}
+		fmt.Fprintf(w, "%s%s%s\n", color, d, colorReset)
+	}
+	fmt.Fprintf(w, "\n%s, %s\n", plural(errors, "error"), plural(warnings, "warning"))
+}
+
+// plural formats a count of things, like "1 error" or "2 errors"
+func plural(n int, thing string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, thing)
+	}
+	return fmt.Sprintf("%d %ss", n, thing)
+}
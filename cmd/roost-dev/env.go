@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processEnv is one process's environment as returned by /api/env
+type processEnv struct {
+	Name    string   `json:"name"`
+	Service string   `json:"service,omitempty"`
+	Shell   string   `json:"shell"`
+	Env     []string `json:"env"`
+}
+
+func cmdEnv(args []string) {
+	fs := flag.NewFlagSet("env", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+
+	fs.Usage = func() {
+		fmt.Println(`roost-dev env - Show the environment an app or service gets
+
+USAGE:
+    roost-dev env [options] <app-or-service>
+
+OPTIONS:
+  --json            Output in JSON format (same as /api/env)
+
+Prints every variable a process would start with, after inherit_env,
+env_file and env are applied, including PORT and FORCE_COLOR. Processes
+running under a login shell may have variables changed further by your
+shell profile.
+
+EXAMPLES:
+    roost-dev env myapp           Environment of each process of myapp
+    roost-dev env myapp:web       Environment of the web service
+
+Requires the roost-dev server to be running.`)
+	}
+
+	// Check for help before parsing
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" || arg == "help" {
+			fs.Usage()
+			os.Exit(0)
+		}
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	globalCfg, _ := getConfigWithDefaults()
+	envs, err := fetchEnv(globalCfg.TLD, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(envs)
+		return
+	}
+	fmt.Print(formatEnv(envs))
+	for _, pe := range envs {
+		if pe.Shell == "login" {
+			fmt.Fprintln(os.Stderr, "Note: runs under a login shell, which may change these further (see 'roost-dev docs', SHELL)")
+			break
+		}
+	}
+}
+
+// fetchEnv asks the server for the environment of an app's processes
+func fetchEnv(tld, name string) ([]processEnv, error) {
+	resp, err := http.Get(fmt.Sprintf("http://roost-dev.%s/api/env?name=%s", tld, url.QueryEscape(name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to roost-dev: %v (is it running?)", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(body)))
+	}
+	var envs []processEnv
+	if err := json.NewDecoder(resp.Body).Decode(&envs); err != nil {
+		return nil, fmt.Errorf("failed to parse environment: %v", err)
+	}
+	return envs, nil
+}
+
+// formatEnv prints NAME=value lines, with a comment naming each service when
+// there are several. Values with newlines are quoted so each variable stays
+// on one line.
+func formatEnv(envs []processEnv) string {
+	var b strings.Builder
+	for i, pe := range envs {
+		if len(envs) > 1 {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "# %s (%s)\n", pe.Service, pe.Name)
+		}
+		for _, kv := range pe.Env {
+			if k, v, _ := strings.Cut(kv, "="); strings.ContainsAny(v, "\n\r") {
+				kv = k + "=" + strconv.Quote(v)
+			}
+			b.WriteString(kv + "\n")
+		}
+	}
+	return b.String()
+}
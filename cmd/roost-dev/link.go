@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/panozzaj/roost-dev/internal/config"
+)
+
+func cmdLink(args []string) {
+	usage := `roost-dev link - Register a project's roost.yml
+
+USAGE:
+    roost-dev link [path]
+
+ARGUMENTS:
+    path              Project directory or config file (default: current directory)
+
+Links the project's roost.yml (or roost.yaml) into ~/.config/roost-dev as
+<name>.yml, so the config can be checked into the repo and shared. The
+name comes from the file's name setting, or else the directory's name.
+root defaults to the project directory, and relative roots are resolved
+against it. A running roost-dev picks the app up right away and reloads
+it when the file changes.
+
+EXAMPLES:
+    roost-dev link                Link ./roost.yml
+    roost-dev link ~/code/shop    Link ~/code/shop/roost.yml`
+
+	if checkHelpFlag(args, usage) {
+		return
+	}
+	if len(args) > 1 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	path := "."
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	globalCfg, configDir := getConfigWithDefaults()
+	link, err := config.LinkProject(configDir, path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Linked %s -> %s\n", link.Name, link.Target)
+	fmt.Printf("  http://%s.%s\n", link.Name, globalCfg.TLD)
+}
+
+func cmdUnlink(args []string) {
+	usage := `roost-dev unlink - Unregister a linked project
+
+USAGE:
+    roost-dev unlink [app-or-path]
+
+ARGUMENTS:
+    app-or-path       App name or project path (default: current directory)
+
+Removes the link roost-dev link made; the project's roost.yml is left as
+is. A running roost-dev stops the app.
+
+EXAMPLES:
+    roost-dev unlink              Unlink the project in the current directory
+    roost-dev unlink shop         Unlink the app named shop`
+
+	if checkHelpFlag(args, usage) {
+		return
+	}
+	if len(args) > 1 {
+		fmt.Println(usage)
+		os.Exit(1)
+	}
+	arg := "."
+	if len(args) == 1 {
+		arg = args[0]
+	}
+
+	_, configDir := getConfigWithDefaults()
+	links, err := config.UnlinkProject(configDir, arg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, link := range links {
+		fmt.Printf("Unlinked %s (%s)\n", link.Name, link.Target)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/panozzaj/roost-dev/internal/config"
 )
 
 // AppStatus represents the status of a single app from the API
@@ -13,6 +15,7 @@ type AppStatus struct {
 	URL         string      `json:"url"`
 	Aliases     []string    `json:"aliases,omitempty"`
 	Description string      `json:"description,omitempty"`
+	Profile     string      `json:"profile,omitempty"`
 	Running     bool        `json:"running,omitempty"`
 	Port        int         `json:"port,omitempty"`
 	Uptime      string      `json:"uptime,omitempty"`
@@ -27,6 +30,7 @@ type SvcStatus struct {
 	Uptime  string `json:"uptime,omitempty"`
 	URL     string `json:"url"`
 	Default bool   `json:"default,omitempty"`
+	Worker  bool   `json:"worker,omitempty"`
 }
 
 // cmdList handles the 'list' command (alias for status)
@@ -64,8 +68,8 @@ func listConfigFiles(configDir, tld string) error {
 		if strings.HasPrefix(name, ".") || name == "config.json" || name == "config-theme.json" {
 			continue
 		}
-		// Skip certs directory
-		if entry.IsDir() && name == "certs" {
+		// Skip certs, logs and other files roost-dev manages
+		if config.StateEntries[name] {
 			continue
 		}
 		// Remove .yml/.yaml extension for display
@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -15,14 +18,20 @@ func cmdLogs(args []string) {
 	fs := flag.NewFlagSet("logs", flag.ExitOnError)
 
 	var (
-		follow bool
-		server bool
-		lines  int
+		follow        bool
+		server        bool
+		lines         int
+		since         string
+		beforeRestart bool
+		file          bool
 	)
 
 	fs.BoolVar(&follow, "f", false, "Follow log output (poll for new logs)")
 	fs.BoolVar(&server, "server", false, "Show server logs instead of app logs")
 	fs.IntVar(&lines, "n", 0, "Number of lines to show (0 = all available)")
+	fs.StringVar(&since, "since", "", "Show log file lines since a duration ago (10m) or time (RFC 3339)")
+	fs.BoolVar(&beforeRestart, "before-restart", false, "Show log file lines from the run before the last restart")
+	fs.BoolVar(&file, "file", false, "Read the full log file history instead of recent output")
 
 	fs.Usage = func() {
 		fmt.Println(`roost-dev logs - View logs from roost-dev or apps
@@ -32,8 +41,11 @@ USAGE:
 
 OPTIONS:
   -f            Follow log output (poll for new logs)
-  -n int        Number of lines to show (0 = all available)
-  --server      Show server logs instead of app logs
+  -n int            Number of lines to show (0 = all available)
+  --server          Show server logs instead of app logs
+  --file            Read the log file on disk (survives restarts)
+  --since value     Log file lines since a duration ago (10m) or a time
+  --before-restart  Log file lines from the run before the last restart
 
 EXAMPLES:
     roost-dev logs                  Show server request logs
@@ -41,6 +53,9 @@ EXAMPLES:
     roost-dev logs -f myapp         Follow myapp logs
     roost-dev logs --server         Show server logs (same as no args)
     roost-dev logs -n 50 myapp      Show last 50 lines of myapp logs
+    roost-dev logs --since 1h myapp Show the last hour from the log file
+    roost-dev logs --before-restart web-myapp
+                                    Show output of the run that crashed
 
 Requires the roost-dev server to be running.`)
 	}
@@ -63,26 +78,44 @@ Requires the roost-dev server to be running.`)
 		server = true
 	}
 
+	// Options that read the log files on disk
+	history := url.Values{}
+	if file {
+		history.Set("file", "true")
+	}
+	if since != "" {
+		history.Set("since", since)
+	}
+	if beforeRestart {
+		history.Set("before_restart", "true")
+	}
+	if len(history) > 0 && (server || follow) {
+		fmt.Fprintln(os.Stderr, "Error: --file, --since and --before-restart need an app name and can't be combined with -f")
+		os.Exit(1)
+	}
+
 	if follow {
 		runLogsFollow(globalCfg.TLD, appName, server, lines)
 	} else {
-		if err := runLogsOnce(globalCfg.TLD, appName, server, lines); err != nil {
+		if err := runLogsOnce(globalCfg.TLD, appName, server, lines, history); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 	}
 }
 
-// runLogsOnce fetches and prints logs once
-func runLogsOnce(tld, appName string, server bool, maxLines int) error {
-	var url string
+// runLogsOnce fetches and prints logs once. history holds the query
+// parameters for reading log files instead of recent output.
+func runLogsOnce(tld, appName string, server bool, maxLines int, history url.Values) error {
+	var logsURL string
 	if server || appName == "" {
-		url = fmt.Sprintf("http://roost-dev.%s/api/server-logs", tld)
+		logsURL = fmt.Sprintf("http://roost-dev.%s/api/server-logs", tld)
 	} else {
-		url = fmt.Sprintf("http://roost-dev.%s/api/logs?name=%s", tld, appName)
+		history.Set("name", appName)
+		logsURL = fmt.Sprintf("http://roost-dev.%s/api/logs?%s", tld, history.Encode())
 	}
 
-	resp, err := http.Get(url)
+	resp, err := http.Get(logsURL)
 	if err != nil {
 		return fmt.Errorf("failed to connect to roost-dev: %v (is it running?)", err)
 	}
@@ -91,6 +124,10 @@ func runLogsOnce(tld, appName string, server bool, maxLines int) error {
 	if resp.StatusCode == http.StatusNotFound {
 		return fmt.Errorf("app not found: %s", appName)
 	}
+	if resp.StatusCode == http.StatusBadRequest {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s", strings.TrimSpace(string(body)))
+	}
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("request failed with status %d", resp.StatusCode)
 	}
@@ -134,6 +134,18 @@ func main() {
 		cmdDocs(args)
 	case "logs":
 		cmdLogs(args)
+	case "env":
+		cmdEnv(args)
+	case "profile":
+		cmdProfile(args)
+	case "ps":
+		cmdPS(args)
+	case "link":
+		cmdLink(args)
+	case "unlink":
+		cmdUnlink(args)
+	case "config":
+		cmdConfig(args)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\nRun 'roost-dev help' for usage.\n", cmd)
 		os.Exit(1)
@@ -151,12 +163,21 @@ USAGE:
 APP STATUS:
     status            Show configured apps and their status (--json for JSON)
     list, ls          Alias for status
+    ps [app]          Show running processes with PIDs, ports and memory
 
 APP CONTROL:
     start <app>       Start an app
     stop <app>        Stop an app
     restart <app>     Restart an app
     logs [app]        View server or app logs (-f to follow)
+    env <app>         Show the environment an app or service gets
+    profile <app> [p] List an app's profiles, or switch to profile p
+
+PROJECTS:
+    link [path]       Use a project's checked-in roost.yml
+    unlink [app]      Stop using a linked project
+    config validate   Report problems in the app configs
+    config schema     Print the JSON Schema for app configs
 
 SETUP:
     setup             Interactive setup wizard (ports + cert + service)
@@ -165,7 +186,7 @@ SETUP:
 
 ADVANCED:
     serve             Start the roost-dev server (usually runs as service)
-    ports             Manage port forwarding (install/uninstall)
+    ports             Manage port forwarding and list app ports
     cert              Manage HTTPS certificates (install/uninstall)
     service           Manage background service (install/uninstall)
 
@@ -189,6 +210,7 @@ func cmdServe(args []string) {
 		advertisePort int
 		dnsPort       int
 		tld           string
+		idleTimeout   string
 	)
 
 	fs.StringVar(&configDir, "dir", getDefaultConfigDir(), "Configuration directory")
@@ -197,6 +219,7 @@ func cmdServe(args []string) {
 	fs.IntVar(&advertisePort, "advertise-port", 80, "Port to use in URLs (0 = same as http-port)")
 	fs.IntVar(&dnsPort, "dns-port", 9053, "DNS server port")
 	fs.StringVar(&tld, "tld", "", "Top-level domain (default: from config or 'localhost')")
+	fs.StringVar(&idleTimeout, "idle-timeout", "", "Stop apps with no requests for this long, e.g. 30m (default: from config or never)")
 
 	fs.Usage = func() {
 		fmt.Println(`roost-dev serve - Start the roost-dev server
@@ -256,6 +279,16 @@ CONFIGURATION:
 	if tld == "" {
 		tld = globalCfg.TLD
 	}
+	if idleTimeout == "" {
+		idleTimeout = globalCfg.IdleTimeout
+	}
+	var idleDuration time.Duration
+	if idleTimeout != "" {
+		idleDuration, err = time.ParseDuration(idleTimeout)
+		if err != nil {
+			log.Fatalf("Invalid idle timeout %q: %v", idleTimeout, err)
+		}
+	}
 
 	// Ensure config directory exists
 	if err := os.MkdirAll(configDir, 0755); err != nil {
@@ -292,6 +325,7 @@ CONFIGURATION:
 		TLD:           tld,
 		Ollama:        ollamaCfg,
 		ClaudeCommand: claudeCmd,
+		IdleTimeout:   idleDuration,
 	}
 
 	// Create and start server
@@ -814,7 +848,11 @@ func runStatus(jsonOutput bool, filter string) error {
 		if len(app.Aliases) > 0 {
 			name = fmt.Sprintf("%s (%s)", app.Name, strings.Join(app.Aliases, ", "))
 		}
-		fmt.Printf("%-25s %s %s\n", name, paddedStatus, app.URL)
+		profile := ""
+		if app.Profile != "" {
+			profile = fmt.Sprintf(" %s[%s]%s", colorGray, app.Profile, colorReset)
+		}
+		fmt.Printf("%-25s %s %s%s\n", name, paddedStatus, app.URL, profile)
 
 		// Print services for multi-service apps
 		if app.Type == "multi-service" && len(app.Services) > 0 {
@@ -841,7 +879,11 @@ func runStatus(jsonOutput bool, filter string) error {
 				}
 
 				svcName := fmt.Sprintf("%s %s", prefix, svc.Name)
-				fmt.Printf("  %-23s %s %s\n", svcName, svcPaddedStatus, svc.URL)
+				svcURL := svc.URL
+				if svc.Worker {
+					svcURL = colorGray + "(worker)" + colorReset
+				}
+				fmt.Printf("  %-23s %s %s\n", svcName, svcPaddedStatus, svcURL)
 			}
 		}
 	}
@@ -882,11 +924,24 @@ func runCommand(cmd, appName string) error {
 	case "stop":
 		fmt.Printf("%s stopped\n", appName)
 	case "restart":
-		fmt.Printf("%s restarted\n", appName)
+		var result struct {
+			Restarted []string `json:"restarted"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		fmt.Print(formatRestarted(appName, result.Restarted))
 	}
 	return nil
 }
 
+// formatRestarted reports a restart, listing the processes restarted when
+// restart_with brought along more than the one asked for
+func formatRestarted(name string, restarted []string) string {
+	if len(restarted) <= 1 {
+		return fmt.Sprintf("%s restarted\n", name)
+	}
+	return fmt.Sprintf("%s restarted: %s\n", name, strings.Join(restarted, ", "))
+}
+
 // runSetupWizard is the interactive setup wizard
 func runSetupWizard(configDir, tld string) {
 	printLogo()
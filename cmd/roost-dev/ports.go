@@ -6,9 +6,12 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/panozzaj/roost-dev/internal/diff"
+	"github.com/panozzaj/roost-dev/internal/process"
 )
 
 // cmdPorts handles the 'ports' command for managing port forwarding
@@ -22,6 +25,8 @@ func cmdPorts(args []string) {
 	subargs := args[1:]
 
 	switch subcmd {
+	case "list":
+		cmdPortsList(subargs)
 	case "install":
 		cmdPortsInstall(subargs)
 	case "uninstall":
@@ -37,12 +42,13 @@ func cmdPorts(args []string) {
 }
 
 func printPortsUsage() {
-	fmt.Println(`roost-dev ports - Manage port forwarding
+	fmt.Println(`roost-dev ports - Manage port forwarding and app ports
 
 USAGE:
     roost-dev ports <command>
 
 COMMANDS:
+    list        Show the port each app and service last ran on
     install     Setup port forwarding (80→9280, 443→9443)
     uninstall   Remove port forwarding configuration
 
@@ -54,6 +60,45 @@ DESCRIPTION:
     specifying a port number.`)
 }
 
+// cmdPortsList handles the 'ports list' command
+func cmdPortsList(args []string) {
+	if checkHelpFlag(args, `roost-dev ports list - Show sticky port assignments
+
+USAGE:
+    roost-dev ports list
+
+DESCRIPTION:
+    Shows the port each app and service last ran on. roost-dev reuses
+    that port on the next start if it is free, so URLs with ports keep
+    working across restarts. Set preferred_port in an app's YAML to pick
+    the port yourself.`) {
+		os.Exit(0)
+	}
+
+	configDir := getDefaultConfigDir()
+	ports, err := process.LoadPortAssignments(filepath.Join(configDir, "ports.json"))
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(ports) == 0 {
+		fmt.Println("No ports assigned yet.")
+		return
+	}
+
+	names := make([]string, 0, len(ports))
+	for name := range ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("%-30s %s\n", "PROCESS", "PORT")
+	fmt.Printf("%-30s %s\n", "-------", "----")
+	for _, name := range names {
+		fmt.Printf("%-30s %d\n", name, ports[name])
+	}
+}
+
 // cmdPortsInstall handles the 'ports install' command (also legacy 'install')
 func cmdPortsInstall(args []string) {
 	fs := flag.NewFlagSet("ports install", flag.ExitOnError)
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// profileInfo is an app's profiles as returned by /api/profile
+type profileInfo struct {
+	App       string         `json:"app"`
+	Profile   string         `json:"profile,omitempty"`
+	Profiles  []profileEntry `json:"profiles"`
+	Restarted []string       `json:"restarted,omitempty"`
+	Stopped   []string       `json:"stopped,omitempty"`
+}
+
+// profileEntry is one of the profiles in an app's config
+type profileEntry struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+func cmdProfile(args []string) {
+	fs := flag.NewFlagSet("profile", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+
+	fs.Usage = func() {
+		fmt.Println(`roost-dev profile - Switch an app between the variants in its config
+
+USAGE:
+    roost-dev profile [options] <app> [profile]
+
+OPTIONS:
+  --json            Output in JSON format (same as /api/profile)
+
+Without a profile, lists the app's profiles and marks the active one.
+With one, makes it active: its cmd, env and services replace those in the
+rest of the config. If the app is running, the processes the switch
+changes are restarted, services the profile leaves out are stopped, and
+the rest keep running. Switch to 'default' to run the config as written.
+
+The active profile is kept across roost-dev restarts. Profiles are set up
+under profiles: in the app's YAML (see 'roost-dev docs', PROFILES).
+
+EXAMPLES:
+    roost-dev profile shop            List shop's profiles
+    roost-dev profile shop staging    Run shop against staging
+    roost-dev profile shop default    Back to the config as written
+
+Requires the roost-dev server to be running.`)
+	}
+
+	// Check for help before parsing
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" || arg == "help" {
+			fs.Usage()
+			os.Exit(0)
+		}
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	globalCfg, _ := getConfigWithDefaults()
+	info, err := fetchProfile(globalCfg.TLD, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(info)
+		return
+	}
+	if fs.NArg() == 2 {
+		fmt.Print(formatProfileSwitch(info))
+	} else {
+		fmt.Print(formatProfiles(info))
+	}
+}
+
+// fetchProfile asks the server for an app's profiles, switching it to
+// profile first if set
+func fetchProfile(tld, name, profile string) (*profileInfo, error) {
+	query := url.Values{"name": {name}}
+	if profile != "" {
+		query.Set("profile", profile)
+	}
+	resp, err := http.Get(fmt.Sprintf("http://roost-dev.%s/api/profile?%s", tld, query.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to roost-dev: %v (is it running?)", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(body)))
+	}
+	var info profileInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to parse profiles: %v", err)
+	}
+	return &info, nil
+}
+
+// formatProfiles lists an app's profiles, with the active one marked
+func formatProfiles(info *profileInfo) string {
+	if len(info.Profiles) == 0 {
+		return fmt.Sprintf("%s has no profiles\n", info.App)
+	}
+	var b strings.Builder
+	line := func(active bool, name, description string) {
+		mark := " "
+		if active {
+			mark = colorGreen + "*" + colorReset
+		}
+		if description != "" {
+			fmt.Fprintf(&b, "%s %-20s %s%s%s\n", mark, name, colorGray, description, colorReset)
+		} else {
+			fmt.Fprintf(&b, "%s %s\n", mark, name)
+		}
+	}
+	line(info.Profile == "", "default", "the config as written")
+	for _, p := range info.Profiles {
+		line(p.Name == info.Profile, p.Name, p.Description)
+	}
+	return b.String()
+}
+
+// formatProfileSwitch reports a switch and the processes it restarted or
+// stopped
+func formatProfileSwitch(info *profileInfo) string {
+	profile := info.Profile
+	if profile == "" {
+		profile = "default"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s now uses profile %s\n", info.App, profile)
+	if len(info.Restarted) > 0 {
+		fmt.Fprintf(&b, "  restarted: %s\n", strings.Join(info.Restarted, ", "))
+	}
+	if len(info.Stopped) > 0 {
+		fmt.Fprintf(&b, "  stopped: %s\n", strings.Join(info.Stopped, ", "))
+	}
+	return b.String()
+}
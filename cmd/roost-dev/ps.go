@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// psEntry is one running process and its tree as returned by /api/ps
+type psEntry struct {
+	Name    string         `json:"name"`
+	App     string         `json:"app"`
+	Service string         `json:"service,omitempty"`
+	Port    int            `json:"port,omitempty"`
+	Ports   map[string]int `json:"ports,omitempty"`
+	Metrics *struct {
+		CPU      float64 `json:"cpu"`
+		Memory   int64   `json:"memory"`
+		Threads  int     `json:"threads,omitempty"`
+		Children int     `json:"children"`
+	} `json:"metrics,omitempty"`
+	Tree psNode `json:"tree"`
+}
+
+// psNode is a process in a tree from /api/ps
+type psNode struct {
+	PID      int      `json:"pid"`
+	Command  string   `json:"command"`
+	RSS      int64    `json:"rss"`
+	Threads  int      `json:"threads,omitempty"`
+	Children []psNode `json:"children,omitempty"`
+}
+
+// psCommandWidth is how much of each command line ps shows
+const psCommandWidth = 80
+
+func cmdPS(args []string) {
+	fs := flag.NewFlagSet("ps", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+
+	fs.Usage = func() {
+		fmt.Println(`roost-dev ps - Show the processes running for each app
+
+USAGE:
+    roost-dev ps [options] [filter]
+
+ARGUMENTS:
+    filter            Optional filter to match app or service names (substring match)
+
+OPTIONS:
+  --json            Output in JSON format (same as /api/ps)
+
+Prints each running app and service with its ports, CPU and memory use,
+and the tree of processes it started with their PIDs and memory.
+
+EXAMPLES:
+    roost-dev ps                  All running processes
+    roost-dev ps myapp            Only myapp's services
+
+Requires the roost-dev server to be running.`)
+	}
+
+	// Check for help before parsing
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" || arg == "help" {
+			fs.Usage()
+			os.Exit(0)
+		}
+	}
+	fs.Parse(args)
+
+	globalCfg, _ := getConfigWithDefaults()
+	entries, err := fetchPS(globalCfg.TLD)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if fs.NArg() > 0 {
+		filter := strings.ToLower(fs.Arg(0))
+		var matched []psEntry
+		for _, e := range entries {
+			if strings.Contains(strings.ToLower(e.App), filter) || strings.Contains(strings.ToLower(e.Service), filter) {
+				matched = append(matched, e)
+			}
+		}
+		entries = matched
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(entries)
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Println("No processes running.")
+		return
+	}
+	fmt.Print(formatPS(entries))
+}
+
+// fetchPS asks the server for the process trees of running apps
+func fetchPS(tld string) ([]psEntry, error) {
+	resp, err := http.Get(fmt.Sprintf("http://roost-dev.%s/api/ps", tld))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to roost-dev: %v (is it running?)", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(body)))
+	}
+	var entries []psEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to parse processes: %v", err)
+	}
+	return entries, nil
+}
+
+// formatPS prints a header line per app or service followed by its process
+// tree, one process per line
+func formatPS(entries []psEntry) string {
+	var b strings.Builder
+	for i, e := range entries {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		name := e.App
+		if e.Service != "" {
+			name += ":" + e.Service
+		}
+		b.WriteString(colorCyan + name + colorReset)
+		if e.Port != 0 {
+			fmt.Fprintf(&b, "  port %d", e.Port)
+		}
+		for _, portName := range slices.Sorted(maps.Keys(e.Ports)) {
+			fmt.Fprintf(&b, "  %s %d", strings.ToLower(portName), e.Ports[portName])
+		}
+		if m := e.Metrics; m != nil {
+			fmt.Fprintf(&b, "  cpu %.1f%%  mem %s", m.CPU, formatSize(m.Memory))
+		}
+		b.WriteString("\n")
+		writePSTree(&b, e.Tree, "", "")
+	}
+	return b.String()
+}
+
+// writePSTree writes a process and its children, drawing the tree with
+// prefix for the process's own line and indent for its children's
+func writePSTree(b *strings.Builder, node psNode, prefix, indent string) {
+	cmd := node.Command
+	if len(cmd) > psCommandWidth {
+		cmd = cmd[:psCommandWidth-3] + "..."
+	}
+	fmt.Fprintf(b, "  %7d %7s  %s%s\n", node.PID, formatSize(node.RSS), prefix, cmd)
+	for i, child := range node.Children {
+		if i == len(node.Children)-1 {
+			writePSTree(b, child, indent+"└─ ", indent+"   ")
+		} else {
+			writePSTree(b, child, indent+"├─ ", indent+"│  ")
+		}
+	}
+}
+
+// formatSize formats bytes with a binary unit (12.5M, 1.2G)
+func formatSize(n int64) string {
+	for i, unit := range []string{"G", "M", "K"} {
+		size := int64(1) << (10 * (3 - i))
+		if n >= size {
+			return strconv.FormatFloat(float64(n)/float64(size), 'f', 1, 64) + unit
+		}
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
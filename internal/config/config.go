@@ -3,11 +3,16 @@ package config
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -20,7 +25,8 @@ type Config struct {
 	URLPort       int // Port to use in generated URLs (for pf forwarding)
 	TLD           string
 	Ollama        *OllamaConfig
-	ClaudeCommand string // Command to run Claude Code (default: "claude")
+	ClaudeCommand string        // Command to run Claude Code (default: "claude")
+	IdleTimeout   time.Duration // Stop processes with no requests for this long (0 = never)
 }
 
 // OllamaConfig stores settings for local LLM error analysis
@@ -32,28 +38,327 @@ type OllamaConfig struct {
 
 // App represents a configured application
 type App struct {
-	Name        string
-	Description string   // Optional display name/description
-	Aliases     []string // Alternative names for CLI/lookup
-	Type        AppType
-	Port        int       // For static port proxy
-	Command     string    // For command-based apps
-	Dir         string    // Working directory
-	FilePath    string    // For static file serving
-	Services    []Service // For multi-service YAML configs
-	Env         map[string]string
-	Hidden      bool // If true, hide from dashboard (still accessible via URL)
+	Name          string
+	Description   string   // Optional display name/description
+	Aliases       []string // Alternative names for CLI/lookup
+	Type          AppType
+	Port          int               // For static port proxy
+	PreferredPort int               // Port to run a command app on if free
+	NamedPorts    []string          // Extra ports for a command app, exposed as PORT_<NAME>
+	Watch         WatchConfig       // Restart a command app when its files change
+	Command       string            // For command-based apps
+	Exec          []string          // Argv to run instead of Command (Command then holds it joined, for display)
+	Dir           string            // Working directory
+	FilePath      string            // For static file serving
+	Services      []Service         // For multi-service YAML configs
+	Env           map[string]string // EnvFiles in order, then the inline env on top
+	EnvFiles      []string          // Dotenv files the env was read from (absolute, may not exist)
+	Hidden        bool              // If true, hide from dashboard (still accessible via URL)
+	IdleTimeout   *time.Duration    // Overrides Config.IdleTimeout when set (0 = never stop)
+	ConfigFile    string            // For an app linked from elsewhere, the file it was read from
+	Profile       string            // Active profile, empty when none is
+	Profiles      []Profile         // Profiles in the config, by name
+	ProcessConfig
 }
 
 // Service represents a service within a multi-service app
 type Service struct {
-	Name      string
-	Dir       string
-	Command   string
-	Port      int // Assigned dynamically
-	Env       map[string]string
-	Default   bool     // If true, this service handles requests to the base app URL
-	DependsOn []string // Names of services that must start first
+	Name          string
+	Dir           string
+	Command       string
+	Exec          []string          // Argv to run instead of Command (Command then holds it joined, for display)
+	Port          int               // Assigned dynamically
+	PreferredPort int               // Port to use if free instead of the last used or a random one
+	NamedPorts    []string          // Extra ports reserved alongside Port, exposed as PORT_<NAME>
+	Watch         WatchConfig       // Restart when files under Dir change
+	Env           map[string]string // EnvFiles in order, then the inline env on top
+	EnvFiles      []string          // The app's env files, then the service's (absolute, may not exist)
+	Default       bool              // If true, this service handles requests to the base app URL
+	Worker        bool              // No port or hostname; ready once started (type: worker or port: false)
+	DependsOn     []string          // Names of services that must start first
+	RestartWith   []string          // Names of services whose restart also restarts this one
+	ProcessConfig
+}
+
+// ProcessConfig holds process settings that can be set per service or at the
+// top level of a YAML config, where they apply to single-command apps and act
+// as defaults for every service
+type ProcessConfig struct {
+	Restart     RestartConfig `yaml:"restart"`
+	StopSignal  Signal        `yaml:"stop_signal"`  // Sent to the process group on stop (default TERM)
+	StopTimeout time.Duration `yaml:"stop_timeout"` // Grace period before SIGKILL (default 5s)
+	StopCommand string        `yaml:"stop_cmd"`     // Run instead of sending StopSignal
+	Ready       ReadyConfig   `yaml:"ready"`
+	Health      ReadyConfig   `yaml:"health"`       // Alias for ready, folded into Ready on load
+	BeforeStart string        `yaml:"before_start"` // Hook run before the command (e.g., bundle install)
+	AfterReady  string        `yaml:"after_ready"`  // Hook run once the process is ready
+	AfterStop   string        `yaml:"after_stop"`   // Hook run after the process exits
+	Shell       string        `yaml:"shell"`        // login (default for cmd), plain, none (default for exec) or a shell path
+	InheritEnv  InheritEnv    `yaml:"inherit_env"`  // What the process inherits from roost-dev's environment
+	Limits      LimitsConfig  `yaml:"limits"`       // Memory, open files and CPU/IO priority caps
+	PIDFile     string        `yaml:"pidfile"`      // Pidfile the command writes, relative to its dir, cleaned up before start
+}
+
+// inherit fills settings left unset with the values from parent
+func (p ProcessConfig) inherit(parent ProcessConfig) ProcessConfig {
+	if p.Restart.Policy == "" {
+		p.Restart = parent.Restart
+	}
+	if p.StopSignal == 0 {
+		p.StopSignal = parent.StopSignal
+	}
+	if p.StopTimeout == 0 {
+		p.StopTimeout = parent.StopTimeout
+	}
+	if p.StopCommand == "" {
+		p.StopCommand = parent.StopCommand
+	}
+	if p.Ready == (ReadyConfig{}) {
+		p.Ready = parent.Ready
+	}
+	if p.BeforeStart == "" {
+		p.BeforeStart = parent.BeforeStart
+	}
+	if p.AfterReady == "" {
+		p.AfterReady = parent.AfterReady
+	}
+	if p.AfterStop == "" {
+		p.AfterStop = parent.AfterStop
+	}
+	if p.Shell == "" {
+		p.Shell = parent.Shell
+	}
+	if !p.InheritEnv.Set {
+		p.InheritEnv = parent.InheritEnv
+	}
+	if p.PIDFile == "" {
+		p.PIDFile = parent.PIDFile
+	}
+	p.Limits = p.Limits.inherit(parent.Limits)
+	return p
+}
+
+// normalized folds the health alias into Ready
+func (p ProcessConfig) normalized() ProcessConfig {
+	if p.Ready == (ReadyConfig{}) {
+		p.Ready = p.Health
+	}
+	p.Health = ReadyConfig{}
+	return p
+}
+
+// ReadyConfig controls when a started process counts as ready. In YAML it is
+// either a bare type (ready: none) or a mapping:
+//
+//	ready:
+//	  type: http
+//	  path: /up
+//	  status: 200
+//	  timeout: 60s
+type ReadyConfig struct {
+	Type    string         `yaml:"type"`    // tcp (default), http, exec, file, log or none
+	Path    string         `yaml:"path"`    // URL path for http, file to wait for with file
+	Status  int            `yaml:"status"`  // Expected HTTP status (default: any below 400)
+	Port    int            `yaml:"port"`    // Port to check instead of the assigned one (tcp, http)
+	Command string         `yaml:"cmd"`     // Command that exits 0 when ready (exec)
+	Pattern string         `yaml:"pattern"` // Regexp matched against log lines (log)
+	Timeout time.Duration  `yaml:"timeout"` // Mark the process failed if not ready by then
+	Regexp  *regexp.Regexp `yaml:"-"`       // Compiled Pattern
+}
+
+// UnmarshalYAML accepts either a bare type or a mapping and validates the
+// fields each type needs
+func (r *ReadyConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		r.Type = node.Value
+	} else {
+		type plain ReadyConfig
+		if err := node.Decode((*plain)(r)); err != nil {
+			return err
+		}
+	}
+
+	switch r.Type {
+	case "", "tcp", "http", "none":
+	case "exec":
+		if r.Command == "" {
+			return fmt.Errorf("line %d: exec readiness check requires cmd", node.Line)
+		}
+	case "file":
+		if r.Path == "" {
+			return fmt.Errorf("line %d: file readiness check requires path", node.Line)
+		}
+	case "log":
+		if r.Pattern == "" {
+			return fmt.Errorf("line %d: log readiness check requires pattern", node.Line)
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid log pattern: %w", node.Line, err)
+		}
+		r.Regexp = re
+	default:
+		return fmt.Errorf("line %d: invalid readiness check type %q (expected tcp, http, exec, file, log or none)", node.Line, r.Type)
+	}
+	return nil
+}
+
+// RestartConfig controls automatic restarts of crashed processes.
+// In YAML it is either a bare policy (restart: on-failure) or a mapping:
+//
+//	restart:
+//	  policy: on-failure
+//	  max_retries: 5
+//	  backoff: 1s
+//	  max_backoff: 30s
+type RestartConfig struct {
+	Policy     string        `yaml:"policy"`      // never (default), on-failure, always
+	MaxRetries int           `yaml:"max_retries"` // Consecutive crashes before giving up
+	Backoff    time.Duration `yaml:"backoff"`     // Initial delay, doubled on each attempt
+	MaxBackoff time.Duration `yaml:"max_backoff"` // Upper bound for the delay
+}
+
+// UnmarshalYAML accepts either a bare policy string or a mapping
+func (r *RestartConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		r.Policy = node.Value
+	} else {
+		type plain RestartConfig
+		if err := node.Decode((*plain)(r)); err != nil {
+			return err
+		}
+	}
+
+	switch r.Policy {
+	case "", "never", "on-failure", "always":
+		return nil
+	default:
+		return fmt.Errorf("line %d: invalid restart policy %q (expected never, on-failure or always)", node.Line, r.Policy)
+	}
+}
+
+// signals maps the names accepted for stop_signal to their values
+var signals = map[string]syscall.Signal{
+	"TERM":  syscall.SIGTERM,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"HUP":   syscall.SIGHUP,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// Signal is a signal given by name in YAML, with or without the SIG prefix
+// (TERM, SIGINT, quit)
+type Signal syscall.Signal
+
+// UnmarshalYAML parses a signal name
+func (s *Signal) UnmarshalYAML(node *yaml.Node) error {
+	name := strings.TrimPrefix(strings.ToUpper(node.Value), "SIG")
+	sig, ok := signals[name]
+	if !ok {
+		return fmt.Errorf("line %d: unknown signal %q", node.Line, node.Value)
+	}
+	*s = Signal(sig)
+	return nil
+}
+
+// InheritEnv controls which of roost-dev's environment variables a process
+// inherits. In YAML it is true (everything, the default), false (only the
+// essentials like HOME and PATH) or a list of extra variables to keep on top
+// of the essentials, where NAME_* matches a prefix:
+//
+//	inherit_env: [RAILS_MASTER_KEY, "AWS_*"]
+type InheritEnv struct {
+	Set   bool     // Configured; otherwise a service uses the app-level setting
+	All   bool     // Inherit everything
+	Allow []string // Without All, the variables to keep besides the essentials
+}
+
+// UnmarshalYAML accepts a bool or a list of variable names
+func (e *InheritEnv) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var all bool
+		if err := node.Decode(&all); err != nil {
+			return fmt.Errorf("line %d: inherit_env must be true, false or a list of variables", node.Line)
+		}
+		*e = InheritEnv{Set: true, All: all}
+		return nil
+	}
+	var allow []string
+	if err := node.Decode(&allow); err != nil {
+		return fmt.Errorf("line %d: inherit_env must be true, false or a list of variables", node.Line)
+	}
+	*e = InheritEnv{Set: true, Allow: allow}
+	return nil
+}
+
+// Clean returns true if the process shouldn't inherit everything
+func (e InheritEnv) Clean() bool {
+	return e.Set && !e.All
+}
+
+// WatchConfig restarts a process when files under its directory change. In
+// YAML it is true (watch everything), a glob or list of globs to include, or
+// a mapping:
+//
+//	watch:
+//	  include: ["**/*.go", go.mod]
+//	  exclude: ["**/*_test.go"]
+//	  debounce: 500ms
+type WatchConfig struct {
+	Enabled  bool          `yaml:"-"`
+	Include  []string      `yaml:"include"`  // Globs relative to the dir (default: everything)
+	Exclude  []string      `yaml:"exclude"`  // Globs to ignore, on top of the built-in ignores
+	Debounce time.Duration `yaml:"debounce"` // Wait for changes to settle (default 200ms)
+}
+
+// UnmarshalYAML accepts a bool, a glob, a list of globs or a mapping, and
+// checks the globs are valid
+func (w *WatchConfig) UnmarshalYAML(node *yaml.Node) error {
+	switch {
+	case node.Kind == yaml.ScalarNode && node.Tag == "!!bool":
+		var enabled bool
+		if err := node.Decode(&enabled); err != nil {
+			return err
+		}
+		*w = WatchConfig{Enabled: enabled}
+		return nil
+	case node.Kind == yaml.ScalarNode:
+		w.Include = []string{node.Value}
+	case node.Kind == yaml.SequenceNode:
+		if err := node.Decode(&w.Include); err != nil {
+			return err
+		}
+	default:
+		type plain WatchConfig
+		if err := node.Decode((*plain)(w)); err != nil {
+			return err
+		}
+	}
+	w.Enabled = true
+
+	for _, glob := range append(append([]string{}, w.Include...), w.Exclude...) {
+		for _, segment := range strings.Split(glob, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("line %d: invalid watch glob %q", node.Line, glob)
+			}
+		}
+	}
+	return nil
+}
+
+// StateEntries are files and directories roost-dev writes into the config
+// directory itself. They are not app configs, and changes to them are not
+// config changes.
+var StateEntries = map[string]bool{
+	"certs":              true,
+	"logs":               true,
+	"ports.json":         true, // sticky port assignments
+	"processes.json":     true, // running processes, for recovery after a restart
+	"processes.json.tmp": true, // written first, then renamed over processes.json
+	ProfilesFile:         true, // active profile of each app
 }
 
 // AppType indicates how to handle the app
@@ -68,9 +373,11 @@ const (
 
 // AppStore manages loaded app configurations
 type AppStore struct {
-	mu   sync.RWMutex
-	apps map[string]*App
-	cfg  *Config
+	mu          sync.RWMutex
+	apps        map[string]*App
+	cfg         *Config
+	diagnostics []Diagnostic      // Problems found by the last Load
+	profiles    map[string]string // Active profile by app name, as of the last Load
 }
 
 // NewAppStore creates a new app store
@@ -94,27 +401,71 @@ func (s *AppStore) Load() error {
 		return fmt.Errorf("reading config dir: %w", err)
 	}
 
+	var diags []Diagnostic
+	profiles, err := LoadActiveProfiles(filepath.Join(s.cfg.Dir, ProfilesFile))
+	if err != nil && !os.IsNotExist(err) {
+		diags = append(diags, Diagnostic{File: filepath.Join(s.cfg.Dir, ProfilesFile), Severity: SeverityError,
+			Message: fmt.Sprintf("%v; apps run without profiles", err)})
+	}
+	s.profiles = profiles
+	var files []*configFile
 	for _, entry := range entries {
 		name := entry.Name()
 		path := filepath.Join(s.cfg.Dir, name)
 
-		// Skip hidden files, config files (config.json, config-*.json), and certs directory
-		if strings.HasPrefix(name, ".") || name == "config.json" || strings.HasPrefix(name, "config-") || name == "certs" {
+		// Skip hidden files, config files (config.json, config-*.json), and files roost-dev manages
+		if strings.HasPrefix(name, ".") || name == "config.json" || strings.HasPrefix(name, "config-") || StateEntries[name] {
 			continue
 		}
 
+		// Report problems against the file the user edits, which for a
+		// linked project is the one in the project
+		f := &configFile{path: path}
+		if target, err := linkTarget(s.cfg.Dir, path); err == nil {
+			f.path = target
+		}
+		var fileDiags []Diagnostic
+		if isYAML(f.path) {
+			if f.root = parseYAMLFile(f.path); f.root != nil {
+				fileDiags = checkYAML(f.path, f.root)
+			}
+		}
+
+		appName := strings.TrimSuffix(name, filepath.Ext(name))
 		app, err := s.loadApp(name, path)
 		if err != nil {
-			fmt.Printf("Warning: failed to load %s: %v\n", name, err)
-			continue
+			fileDiags = append(fileDiags, loadDiagnostics(f.path, f.root, err)...)
+		} else {
+			f.app = app
+			appName = app.Name
+			s.apps[app.Name] = app
 		}
-
-		s.apps[app.Name] = app
+		for i := range fileDiags {
+			fileDiags[i].App = appName
+		}
+		diags = append(diags, fileDiags...)
+		files = append(files, f)
 	}
+	diags = append(diags, checkHostnames(files)...)
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	s.diagnostics = diags
 
 	return nil
 }
 
+// Diagnostics returns the problems found in the configs at the last load
+func (s *AppStore) Diagnostics() []Diagnostic {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.diagnostics)
+}
+
 // loadApp loads a single app configuration
 func (s *AppStore) loadApp(name, path string) (*App, error) {
 	info, err := os.Lstat(path)
@@ -124,14 +475,19 @@ func (s *AppStore) loadApp(name, path string) (*App, error) {
 
 	// Handle symlinks
 	if info.Mode()&os.ModeSymlink != 0 {
-		target, err := os.Readlink(path)
+		target, err := linkTarget(s.cfg.Dir, path)
 		if err != nil {
 			return nil, err
 		}
-		// Expand ~ in symlink target
-		if strings.HasPrefix(target, "~") {
-			home, _ := os.UserHomeDir()
-			target = filepath.Join(home, target[1:])
+		// A link to a YAML file (such as a project's roost.yml) loads it,
+		// anything else is served as static files
+		if isYAML(target) {
+			app, err := s.loadYAMLApp(name, target)
+			if err != nil {
+				return nil, err
+			}
+			app.ConfigFile = target
+			return app, nil
 		}
 		return s.loadStaticApp(name, target)
 	}
@@ -142,7 +498,7 @@ func (s *AppStore) loadApp(name, path string) (*App, error) {
 	}
 
 	// Handle YAML files
-	if strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml") {
+	if isYAML(name) {
 		return s.loadYAMLApp(name, path)
 	}
 
@@ -164,6 +520,46 @@ func (s *AppStore) loadStaticApp(name, path string) (*App, error) {
 	}, nil
 }
 
+// yamlApp is the format of a YAML app config
+type yamlApp struct {
+	Name        string                 `yaml:"name"`
+	Description string                 `yaml:"description"`
+	Aliases     []string               `yaml:"aliases"`
+	Alias       string                 `yaml:"alias"` // Single alias shorthand
+	Root        string                 `yaml:"root"`
+	Static      bool                   `yaml:"static"`         // Serve static files from root
+	Command     string                 `yaml:"cmd"`            // For single-service shorthand
+	Exec        []string               `yaml:"exec"`           // For single-service shorthand
+	PrefPort    int                    `yaml:"preferred_port"` // For single-service shorthand
+	Ports       []string               `yaml:"ports"`          // For single-service shorthand
+	Watch       WatchConfig            `yaml:"watch"`          // For single-service shorthand
+	Env         map[string]string      `yaml:"env"`            // For single-service shorthand
+	EnvFile     envFileList            `yaml:"env_file"`       // Read by the app and every service
+	Hidden      bool                   `yaml:"hidden"`         // Hide from dashboard
+	IdleTimeout *time.Duration         `yaml:"idle_timeout"`   // Stop after no requests for this long
+	Process     ProcessConfig          `yaml:",inline"`
+	Services    map[string]yamlService `yaml:"services"`
+	Profiles    map[string]yamlProfile `yaml:"profiles"` // Variants to switch between, by name
+}
+
+// yamlService is the format of a service in a YAML app config
+type yamlService struct {
+	Dir         string            `yaml:"dir"`
+	Command     string            `yaml:"cmd"`
+	Exec        []string          `yaml:"exec"`
+	PrefPort    int               `yaml:"preferred_port"`
+	Ports       []string          `yaml:"ports"`
+	Watch       WatchConfig       `yaml:"watch"`
+	Env         map[string]string `yaml:"env"`
+	EnvFile     envFileList       `yaml:"env_file"`
+	Type        string            `yaml:"type"` // web (default) or worker
+	Port        *bool             `yaml:"port"` // false: same as type: worker
+	Default     bool              `yaml:"default"`
+	DependsOn   []string          `yaml:"depends_on"`
+	RestartWith []string          `yaml:"restart_with"` // restart along with these services
+	Process     ProcessConfig     `yaml:",inline"`
+}
+
 // loadYAMLApp loads a YAML configuration (single or multi-service)
 func (s *AppStore) loadYAMLApp(name, path string) (*App, error) {
 	data, err := os.ReadFile(path)
@@ -171,28 +567,12 @@ func (s *AppStore) loadYAMLApp(name, path string) (*App, error) {
 		return nil, err
 	}
 
-	var yamlCfg struct {
-		Name        string            `yaml:"name"`
-		Description string            `yaml:"description"`
-		Aliases     []string          `yaml:"aliases"`
-		Alias       string            `yaml:"alias"` // Single alias shorthand
-		Root        string            `yaml:"root"`
-		Static      bool              `yaml:"static"` // Serve static files from root
-		Command     string            `yaml:"cmd"`    // For single-service shorthand
-		Env         map[string]string `yaml:"env"`    // For single-service shorthand
-		Hidden      bool              `yaml:"hidden"` // Hide from dashboard
-		Services    map[string]struct {
-			Dir       string            `yaml:"dir"`
-			Command   string            `yaml:"cmd"`
-			Env       map[string]string `yaml:"env"`
-			Default   bool              `yaml:"default"`
-			DependsOn []string          `yaml:"depends_on"`
-		} `yaml:"services"`
-	}
+	var yamlCfg yamlApp
 
 	if err := yaml.Unmarshal(data, &yamlCfg); err != nil {
 		return nil, fmt.Errorf("parsing YAML: %w", err)
 	}
+	yamlCfg.Process = yamlCfg.Process.normalized()
 
 	// Use filename without extension if name not specified
 	appName := yamlCfg.Name
@@ -200,13 +580,60 @@ func (s *AppStore) loadYAMLApp(name, path string) (*App, error) {
 		appName = strings.TrimSuffix(name, filepath.Ext(name))
 	}
 
-	// Expand ~ in root
-	root := yamlCfg.Root
-	if strings.HasPrefix(root, "~") {
-		home, _ := os.UserHomeDir()
-		root = filepath.Join(home, root[1:])
+	// Whether the app runs as a single command is up to the config as
+	// written, so a profile leaving out services doesn't change its URLs
+	single := len(yamlCfg.Services) == 1
+	profiles, profile, err := applyProfile(&yamlCfg, s.profiles[appName])
+	if err != nil {
+		return nil, err
 	}
 
+	if err := validatePortNames(yamlCfg.Ports); err != nil {
+		return nil, err
+	}
+	if err := validateCommand(yamlCfg.Command, yamlCfg.Exec, yamlCfg.Process.Shell); err != nil {
+		return nil, err
+	}
+	workers := 0
+	for svcName, svcCfg := range yamlCfg.Services {
+		if err := validatePortNames(svcCfg.Ports); err != nil {
+			return nil, fmt.Errorf("service %s: %w", svcName, err)
+		}
+		shell := svcCfg.Process.inherit(yamlCfg.Process).Shell
+		if err := validateCommand(svcCfg.Command, svcCfg.Exec, shell); err != nil {
+			return nil, fmt.Errorf("service %s: %w", svcName, err)
+		}
+		for _, other := range svcCfg.RestartWith {
+			if other == svcName {
+				return nil, fmt.Errorf("service %s: restart_with can't name the service itself", svcName)
+			}
+			if _, ok := yamlCfg.Services[other]; !ok {
+				return nil, fmt.Errorf("service %s: restart_with: unknown service %q", svcName, other)
+			}
+		}
+		worker, err := isWorker(svcCfg.Type, svcCfg.Port)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", svcName, err)
+		}
+		if worker {
+			workers++
+			if svcCfg.Default {
+				return nil, fmt.Errorf("service %s: a worker can't be the default service", svcName)
+			}
+			if svcCfg.PrefPort != 0 {
+				return nil, fmt.Errorf("service %s: a worker has no port, so preferred_port doesn't apply", svcName)
+			}
+			if ready := svcCfg.Process.normalized().Ready; needsPort(ready) {
+				return nil, fmt.Errorf("service %s: ready: %s needs a port to check; set ready.port or use another check", svcName, ready.Type)
+			}
+		}
+	}
+	if workers > 0 && workers == len(yamlCfg.Services) {
+		return nil, fmt.Errorf("every service is a worker; an app needs one that serves its hostname")
+	}
+
+	root := resolveRoot(yamlCfg.Root, path)
+
 	// Merge alias and aliases
 	aliases := yamlCfg.Aliases
 	if yamlCfg.Alias != "" {
@@ -232,36 +659,62 @@ func (s *AppStore) loadYAMLApp(name, path string) (*App, error) {
 		}, nil
 	}
 
-	// Single-service shorthand: cmd at top level
-	if yamlCfg.Command != "" {
+	appEnvFiles := yamlCfg.EnvFile.resolve(root)
+
+	// Single-service shorthand: cmd or exec at top level
+	if yamlCfg.Command != "" || len(yamlCfg.Exec) > 0 {
+		env, err := layerEnv(appEnvFiles, yamlCfg.Env)
+		if err != nil {
+			return nil, err
+		}
 		return &App{
-			Name:        appName,
-			Description: yamlCfg.Description,
-			Aliases:     aliases,
-			Type:        AppTypeCommand,
-			Command:     yamlCfg.Command,
-			Dir:         root,
-			Env:         yamlCfg.Env,
-			Hidden:      yamlCfg.Hidden,
+			Name:          appName,
+			Description:   yamlCfg.Description,
+			Aliases:       aliases,
+			Type:          AppTypeCommand,
+			Command:       commandLine(yamlCfg.Command, yamlCfg.Exec),
+			Exec:          yamlCfg.Exec,
+			PreferredPort: yamlCfg.PrefPort,
+			NamedPorts:    yamlCfg.Ports,
+			Watch:         yamlCfg.Watch,
+			Dir:           root,
+			Env:           env,
+			EnvFiles:      appEnvFiles,
+			Hidden:        yamlCfg.Hidden,
+			IdleTimeout:   yamlCfg.IdleTimeout,
+			Profile:       profile,
+			Profiles:      profiles,
+			ProcessConfig: yamlCfg.Process,
 		}, nil
 	}
 
 	// Single service in services map → treat as simple command
-	if len(yamlCfg.Services) == 1 {
-		for _, svcCfg := range yamlCfg.Services {
-			svcDir := root
-			if svcCfg.Dir != "" {
-				svcDir = filepath.Join(root, svcCfg.Dir)
+	if single {
+		for svcName, svcCfg := range yamlCfg.Services {
+			svcDir := serviceDir(root, svcCfg.Dir)
+			envFiles := append(slices.Clone(appEnvFiles), svcCfg.EnvFile.resolve(s.envFileDir(appName, root, svcDir))...)
+			env, err := layerEnv(envFiles, svcCfg.Env)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: %w", svcName, err)
 			}
 			return &App{
-				Name:        appName,
-				Description: yamlCfg.Description,
-				Aliases:     aliases,
-				Type:        AppTypeCommand,
-				Command:     svcCfg.Command,
-				Dir:         svcDir,
-				Env:         svcCfg.Env,
-				Hidden:      yamlCfg.Hidden,
+				Name:          appName,
+				Description:   yamlCfg.Description,
+				Aliases:       aliases,
+				Type:          AppTypeCommand,
+				Command:       commandLine(svcCfg.Command, svcCfg.Exec),
+				Exec:          svcCfg.Exec,
+				PreferredPort: svcCfg.PrefPort,
+				NamedPorts:    svcCfg.Ports,
+				Watch:         svcCfg.Watch,
+				Dir:           svcDir,
+				Env:           env,
+				EnvFiles:      envFiles,
+				Hidden:        yamlCfg.Hidden,
+				IdleTimeout:   yamlCfg.IdleTimeout,
+				Profile:       profile,
+				Profiles:      profiles,
+				ProcessConfig: svcCfg.Process.normalized().inherit(yamlCfg.Process),
 			}, nil
 		}
 	}
@@ -269,29 +722,49 @@ func (s *AppStore) loadYAMLApp(name, path string) (*App, error) {
 	// Multi-service
 	var services []Service
 	for svcName, svcCfg := range yamlCfg.Services {
-		// Validate service name - no spaces allowed (breaks subdomain parsing)
+		// Spaces would break subdomain parsing; Load reports these
 		if strings.Contains(svcName, " ") {
-			fmt.Printf("Warning: skipping service %q in %s (spaces not allowed in service names)\n", svcName, appName)
 			continue
 		}
 
-		svcDir := root
-		if svcCfg.Dir != "" {
-			svcDir = filepath.Join(root, svcCfg.Dir)
+		svcDir := serviceDir(root, svcCfg.Dir)
+		envFiles := append(slices.Clone(appEnvFiles), svcCfg.EnvFile.resolve(s.envFileDir(appName, root, svcDir))...)
+		env, err := layerEnv(envFiles, svcCfg.Env)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", svcName, err)
+		}
+
+		// Services inherit top-level process settings unless they set their own
+		procCfg := svcCfg.Process.normalized().inherit(yamlCfg.Process)
+		worker, _ := isWorker(svcCfg.Type, svcCfg.Port)
+		if worker && needsPort(procCfg.Ready) {
+			// An app-level check against the service's port can't apply to a worker
+			procCfg.Ready = ReadyConfig{}
 		}
 
 		services = append(services, Service{
-			Name:      svcName,
-			Dir:       svcDir,
-			Command:   svcCfg.Command,
-			Env:       svcCfg.Env,
-			Default:   svcCfg.Default,
-			DependsOn: svcCfg.DependsOn,
+			Name:          svcName,
+			Dir:           svcDir,
+			Command:       commandLine(svcCfg.Command, svcCfg.Exec),
+			Exec:          svcCfg.Exec,
+			PreferredPort: svcCfg.PrefPort,
+			NamedPorts:    svcCfg.Ports,
+			Watch:         svcCfg.Watch,
+			Env:           env,
+			EnvFiles:      envFiles,
+			Default:       svcCfg.Default,
+			Worker:        worker,
+			DependsOn:     svcCfg.DependsOn,
+			RestartWith:   svcCfg.RestartWith,
+			ProcessConfig: procCfg,
 		})
 	}
 
 	// Sort services so dependencies come first
-	services = topologicalSort(services)
+	services, err = topologicalSort(services)
+	if err != nil {
+		return nil, err
+	}
 
 	return &App{
 		Name:        appName,
@@ -301,9 +774,49 @@ func (s *AppStore) loadYAMLApp(name, path string) (*App, error) {
 		Dir:         root,
 		Services:    services,
 		Hidden:      yamlCfg.Hidden,
+		IdleTimeout: yamlCfg.IdleTimeout,
+		Profile:     profile,
+		Profiles:    profiles,
 	}, nil
 }
 
+// serviceDir returns the directory a service runs in: dir relative to root,
+// unless dir starts with a reference like ${root}, which is filled in when
+// the service starts
+func serviceDir(root, dir string) string {
+	switch {
+	case dir == "":
+		return root
+	case strings.HasPrefix(dir, "${"):
+		return dir
+	default:
+		return filepath.Join(root, dir)
+	}
+}
+
+// envFileDir returns the directory a service's env files are relative to:
+// its dir, with the references known before it starts filled in
+func (s *AppStore) envFileDir(appName, root, dir string) string {
+	vars := Vars{Root: root, Name: appName, TLD: s.cfg.TLD}
+	if resolved, err := vars.Interpolate(dir); err == nil && !strings.Contains(resolved, "${") {
+		return resolved
+	}
+	return root
+}
+
+// resolveRoot expands ~ in the root of the YAML config at path. A project's
+// roost.yml runs in (or relative to) the project.
+func resolveRoot(root, path string) string {
+	if strings.HasPrefix(root, "~") {
+		home, _ := os.UserHomeDir()
+		root = filepath.Join(home, root[1:])
+	}
+	if isProjectFile(path) && !filepath.IsAbs(root) {
+		root = filepath.Join(filepath.Dir(path), root)
+	}
+	return root
+}
+
 // loadSimpleApp loads a simple config file (port number, command, or path)
 func (s *AppStore) loadSimpleApp(name, path string) (*App, error) {
 	data, err := os.ReadFile(path)
@@ -431,6 +944,49 @@ func (s *AppStore) All() []*App {
 	return apps
 }
 
+// EnvFiles returns the env files apps read, each with the names of the apps
+// that use it, so changes to them can restart those apps
+func (s *AppStore) EnvFiles() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files := make(map[string][]string)
+	add := func(paths []string, appName string) {
+		for _, path := range paths {
+			if !slices.Contains(files[path], appName) {
+				files[path] = append(files[path], appName)
+			}
+		}
+	}
+	for _, app := range s.apps {
+		add(app.EnvFiles, app.Name)
+		for _, svc := range app.Services {
+			add(svc.EnvFiles, app.Name)
+		}
+	}
+	return files
+}
+
+// LinkedFiles returns the files outside the config directory that links in
+// it point to, each with the name of the app read from it (or, if it failed
+// to load, the link's name), so editing one reloads the app
+func (s *AppStore) LinkedFiles() map[string]string {
+	links, _ := Links(s.cfg.Dir)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	files := make(map[string]string)
+	for _, link := range links {
+		files[link.Target] = link.Name
+	}
+	for _, app := range s.apps {
+		if app.ConfigFile != "" {
+			files[app.ConfigFile] = app.Name
+		}
+	}
+	return files
+}
+
 // Reload refreshes the app configurations
 func (s *AppStore) Reload() error {
 	// Clear existing
@@ -441,8 +997,75 @@ func (s *AppStore) Reload() error {
 	return s.Load()
 }
 
-// topologicalSort orders services so dependencies come before dependents
-func topologicalSort(services []Service) []Service {
+// validateCommand checks a process has at most one of cmd and exec, and a
+// shell setting that can run it
+func validateCommand(cmd string, exec []string, shell string) error {
+	if cmd != "" && len(exec) > 0 {
+		return fmt.Errorf("cmd and exec can't both be set")
+	}
+	switch {
+	case shell == "", shell == "login", shell == "plain":
+	case shell == "none":
+		if cmd != "" {
+			return fmt.Errorf("shell: none needs exec (an argv list) instead of cmd")
+		}
+	case filepath.IsAbs(shell):
+	default:
+		return fmt.Errorf("invalid shell %q (expected login, plain, none or an absolute path)", shell)
+	}
+	return nil
+}
+
+// isWorker returns true if a service is a worker, set with type: worker or
+// port: false
+func isWorker(typ string, port *bool) (bool, error) {
+	switch typ {
+	case "", "web":
+	case "worker":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid type %q (expected web or worker)", typ)
+	}
+	return port != nil && !*port, nil
+}
+
+// needsPort returns true if a readiness check connects to the process's own
+// port, which a worker doesn't have
+func needsPort(r ReadyConfig) bool {
+	return (r.Type == "tcp" || r.Type == "http") && r.Port == 0
+}
+
+// commandLine returns the command shown for a process: cmd, or the exec argv
+// joined with spaces
+func commandLine(cmd string, exec []string) string {
+	if len(exec) > 0 {
+		return strings.Join(exec, " ")
+	}
+	return cmd
+}
+
+// portNamePattern matches names usable as a hostname label; in the environment
+// variable name they are upper-cased with hyphens turned into underscores
+var portNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// validatePortNames checks the names in a ports: list
+func validatePortNames(names []string) error {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if !portNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid port name %q (use lowercase letters, digits and hyphens)", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate port name %q", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// topologicalSort orders services so dependencies come before dependents,
+// or returns an error naming a dependency cycle
+func topologicalSort(services []Service) ([]Service, error) {
 	// Build lookup and in-degree count
 	byName := make(map[string]*Service)
 	inDegree := make(map[string]int)
@@ -492,9 +1115,42 @@ func topologicalSort(services []Service) []Service {
 		}
 	}
 
-	// If we couldn't sort all (cycle), return original
+	// Services left over depend on each other in a cycle
 	if len(result) != len(services) {
-		return services
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(findCycle(services, inDegree), " -> "))
+	}
+	return result, nil
+}
+
+// findCycle returns a dependency cycle among the services that
+// topologicalSort couldn't order (those with a remaining in-degree), starting
+// and ending with the same service
+func findCycle(services []Service, inDegree map[string]int) []string {
+	deps := make(map[string][]string)
+	var start string
+	for _, svc := range services {
+		if inDegree[svc.Name] == 0 {
+			continue
+		}
+		for _, dep := range svc.DependsOn {
+			if inDegree[dep] > 0 {
+				deps[svc.Name] = append(deps[svc.Name], dep)
+			}
+		}
+		if start == "" || svc.Name < start {
+			start = svc.Name
+		}
+	}
+
+	// Every leftover service has a leftover dependency, so following the
+	// first one from any of them must come back around
+	var path []string
+	seen := make(map[string]int)
+	for name := start; ; name = deps[name][0] {
+		if i, ok := seen[name]; ok {
+			return append(path[i:], name)
+		}
+		seen[name] = len(path)
+		path = append(path, name)
 	}
-	return result
 }
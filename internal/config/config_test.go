@@ -1,9 +1,16 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestLoadSimpleApp(t *testing.T) {
@@ -193,6 +200,8 @@ func TestAppStore(t *testing.T) {
 	os.WriteFile(filepath.Join(tmpDir, "app2"), []byte("rails server"), 0644)
 	os.WriteFile(filepath.Join(tmpDir, ".hidden"), []byte("3001"), 0644)
 	os.WriteFile(filepath.Join(tmpDir, "config.json"), []byte(`{"tld":"test"}`), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "ports.json"), []byte(`{"app1":3000}`), 0644)
+	os.Mkdir(filepath.Join(tmpDir, "logs"), 0755)
 
 	t.Run("loads apps from directory", func(t *testing.T) {
 		store := NewAppStore(cfg)
@@ -227,6 +236,17 @@ func TestAppStore(t *testing.T) {
 		}
 	})
 
+	t.Run("skips files roost-dev manages", func(t *testing.T) {
+		store := NewAppStore(cfg)
+		store.Load()
+
+		for _, name := range []string{"ports.json", "logs"} {
+			if _, found := store.Get(name); found {
+				t.Errorf("%s should not be loaded as an app", name)
+			}
+		}
+	})
+
 	t.Run("All returns sorted apps", func(t *testing.T) {
 		store := NewAppStore(cfg)
 		store.Load()
@@ -314,7 +334,10 @@ func TestTopologicalSort(t *testing.T) {
 			{Name: "api", DependsOn: nil},
 		}
 
-		sorted := topologicalSort(services)
+		sorted, err := topologicalSort(services)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 
 		if len(sorted) != 2 {
 			t.Fatalf("expected 2 services, got %d", len(sorted))
@@ -335,7 +358,10 @@ func TestTopologicalSort(t *testing.T) {
 			{Name: "b", DependsOn: []string{"a"}},
 		}
 
-		sorted := topologicalSort(services)
+		sorted, err := topologicalSort(services)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 
 		// Should be: a, b, c
 		if sorted[0].Name != "a" {
@@ -355,7 +381,10 @@ func TestTopologicalSort(t *testing.T) {
 			{Name: "api"},
 		}
 
-		sorted := topologicalSort(services)
+		sorted, err := topologicalSort(services)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 
 		// Should be alphabetically sorted when no deps
 		if sorted[0].Name != "api" {
@@ -363,17 +392,35 @@ func TestTopologicalSort(t *testing.T) {
 		}
 	})
 
-	t.Run("handles cycle gracefully", func(t *testing.T) {
+	t.Run("reports a cycle", func(t *testing.T) {
 		services := []Service{
-			{Name: "a", DependsOn: []string{"b"}},
-			{Name: "b", DependsOn: []string{"a"}},
+			{Name: "web", DependsOn: []string{"api"}},
+			{Name: "api", DependsOn: []string{"db"}},
+			{Name: "db", DependsOn: []string{"web"}},
+			{Name: "cache"},
 		}
 
-		sorted := topologicalSort(services)
+		_, err := topologicalSort(services)
+		if err == nil || err.Error() != "dependency cycle: api -> db -> web -> api" {
+			t.Errorf("expected cycle error, got %v", err)
+		}
+	})
 
-		// Should return original on cycle
-		if len(sorted) != 2 {
-			t.Errorf("expected 2 services returned on cycle, got %d", len(sorted))
+	t.Run("reports a service depending on itself", func(t *testing.T) {
+		_, err := topologicalSort([]Service{{Name: "web", DependsOn: []string{"web"}}})
+		if err == nil || err.Error() != "dependency cycle: web -> web" {
+			t.Errorf("expected cycle error, got %v", err)
+		}
+	})
+
+	t.Run("loading an app with a cycle fails", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		store := NewAppStore(&Config{Dir: tmpDir})
+		path := filepath.Join(tmpDir, "cycle.yml")
+		os.WriteFile(path, []byte("root: /tmp\nservices:\n  web:\n    cmd: bin/web\n    depends_on: [api]\n  api:\n    cmd: bin/api\n    depends_on: [web]\n"), 0644)
+		_, err := store.loadYAMLApp("cycle.yml", path)
+		if err == nil || !strings.Contains(err.Error(), "dependency cycle: api -> web -> api") {
+			t.Errorf("expected cycle error, got %v", err)
 		}
 	})
 
@@ -383,7 +430,10 @@ func TestTopologicalSort(t *testing.T) {
 			{Name: "api"},
 		}
 
-		sorted := topologicalSort(services)
+		sorted, err := topologicalSort(services)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 
 		// Should still sort, ignoring unknown dep
 		if len(sorted) != 2 {
@@ -701,3 +751,1393 @@ func TestLoadStaticApp(t *testing.T) {
 		}
 	})
 }
+
+func TestRestartConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{Dir: tmpDir}
+	store := NewAppStore(cfg)
+
+	t.Run("parses bare policy", func(t *testing.T) {
+		yaml := `
+root: /tmp/bare
+cmd: ./worker
+restart: on-failure
+`
+		path := filepath.Join(tmpDir, "bare.yml")
+		os.WriteFile(path, []byte(yaml), 0644)
+
+		app, err := store.loadYAMLApp("bare.yml", path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if app.Restart.Policy != "on-failure" {
+			t.Errorf("expected policy 'on-failure', got %q", app.Restart.Policy)
+		}
+	})
+
+	t.Run("parses mapping with durations", func(t *testing.T) {
+		yaml := `
+root: /tmp/mapping
+cmd: ./worker
+restart:
+  policy: always
+  max_retries: 3
+  backoff: 2s
+  max_backoff: 1m
+`
+		path := filepath.Join(tmpDir, "mapping.yml")
+		os.WriteFile(path, []byte(yaml), 0644)
+
+		app, err := store.loadYAMLApp("mapping.yml", path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := RestartConfig{Policy: "always", MaxRetries: 3, Backoff: 2 * time.Second, MaxBackoff: time.Minute}
+		if app.Restart != want {
+			t.Errorf("expected %+v, got %+v", want, app.Restart)
+		}
+	})
+
+	t.Run("services inherit app policy unless overridden", func(t *testing.T) {
+		yaml := `
+root: /tmp/inherit
+restart: on-failure
+services:
+  web:
+    cmd: npm start
+  worker:
+    cmd: ./worker
+    restart: always
+`
+		path := filepath.Join(tmpDir, "inherit.yml")
+		os.WriteFile(path, []byte(yaml), 0644)
+
+		app, err := store.loadYAMLApp("inherit.yml", path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, svc := range app.Services {
+			want := "on-failure"
+			if svc.Name == "worker" {
+				want = "always"
+			}
+			if svc.Restart.Policy != want {
+				t.Errorf("service %s: expected policy %q, got %q", svc.Name, want, svc.Restart.Policy)
+			}
+		}
+	})
+
+	t.Run("rejects unknown policy", func(t *testing.T) {
+		yaml := `
+root: /tmp/invalid
+cmd: ./worker
+restart: sometimes
+`
+		path := filepath.Join(tmpDir, "invalid.yml")
+		os.WriteFile(path, []byte(yaml), 0644)
+
+		if _, err := store.loadYAMLApp("invalid.yml", path); err == nil {
+			t.Error("expected error for unknown restart policy")
+		}
+	})
+}
+
+func TestStopConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{Dir: tmpDir}
+	store := NewAppStore(cfg)
+
+	t.Run("parses stop settings", func(t *testing.T) {
+		yaml := `
+root: /tmp/stop
+cmd: bundle exec puma
+stop_signal: QUIT
+stop_timeout: 15s
+stop_cmd: bundle exec pumactl stop
+`
+		path := filepath.Join(tmpDir, "stop.yml")
+		os.WriteFile(path, []byte(yaml), 0644)
+
+		app, err := store.loadYAMLApp("stop.yml", path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if app.StopSignal != Signal(syscall.SIGQUIT) {
+			t.Errorf("expected SIGQUIT, got %v", app.StopSignal)
+		}
+		if app.StopTimeout != 15*time.Second {
+			t.Errorf("expected stop timeout 15s, got %v", app.StopTimeout)
+		}
+		if app.StopCommand != "bundle exec pumactl stop" {
+			t.Errorf("expected stop command, got %q", app.StopCommand)
+		}
+	})
+
+	t.Run("accepts SIG prefix and lowercase names", func(t *testing.T) {
+		for _, name := range []string{"SIGINT", "int", "sigint"} {
+			var sig Signal
+			node := &yaml.Node{Kind: yaml.ScalarNode, Value: name}
+			if err := sig.UnmarshalYAML(node); err != nil {
+				t.Errorf("%s: unexpected error: %v", name, err)
+			} else if sig != Signal(syscall.SIGINT) {
+				t.Errorf("%s: expected SIGINT, got %v", name, sig)
+			}
+		}
+	})
+
+	t.Run("services inherit stop settings unless overridden", func(t *testing.T) {
+		yaml := `
+root: /tmp/stopinherit
+stop_signal: INT
+stop_timeout: 10s
+services:
+  web:
+    cmd: npm start
+  worker:
+    cmd: ./worker
+    stop_signal: QUIT
+`
+		path := filepath.Join(tmpDir, "stopinherit.yml")
+		os.WriteFile(path, []byte(yaml), 0644)
+
+		app, err := store.loadYAMLApp("stopinherit.yml", path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, svc := range app.Services {
+			want := Signal(syscall.SIGINT)
+			if svc.Name == "worker" {
+				want = Signal(syscall.SIGQUIT)
+			}
+			if svc.StopSignal != want {
+				t.Errorf("service %s: expected signal %v, got %v", svc.Name, want, svc.StopSignal)
+			}
+			if svc.StopTimeout != 10*time.Second {
+				t.Errorf("service %s: expected inherited stop timeout 10s, got %v", svc.Name, svc.StopTimeout)
+			}
+		}
+	})
+
+	t.Run("rejects unknown signal", func(t *testing.T) {
+		yaml := `
+root: /tmp/badsignal
+cmd: ./worker
+stop_signal: BOGUS
+`
+		path := filepath.Join(tmpDir, "badsignal.yml")
+		os.WriteFile(path, []byte(yaml), 0644)
+
+		if _, err := store.loadYAMLApp("badsignal.yml", path); err == nil {
+			t.Error("expected error for unknown stop signal")
+		}
+	})
+}
+
+func TestReadyConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{Dir: tmpDir}
+	store := NewAppStore(cfg)
+
+	t.Run("parses ready checks per service", func(t *testing.T) {
+		yaml := `
+root: /tmp/ready
+services:
+  web:
+    cmd: bin/rails server
+    ready:
+      type: http
+      path: /up
+      status: 200
+      timeout: 60s
+  worker:
+    cmd: bin/jobs
+    ready:
+      type: log
+      pattern: "Started \\d+ workers"
+  collector:
+    cmd: python collector.py
+    health:
+      type: file
+      path: tmp/ready
+  cron:
+    cmd: ./cron
+    ready: none
+`
+		path := filepath.Join(tmpDir, "ready.yml")
+		os.WriteFile(path, []byte(yaml), 0644)
+
+		app, err := store.loadYAMLApp("ready.yml", path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		byName := map[string]Service{}
+		for _, svc := range app.Services {
+			byName[svc.Name] = svc
+		}
+
+		web := byName["web"].Ready
+		if web.Type != "http" || web.Path != "/up" || web.Status != 200 || web.Timeout != time.Minute {
+			t.Errorf("unexpected web check: %+v", web)
+		}
+		worker := byName["worker"].Ready
+		if worker.Regexp == nil || !worker.Regexp.MatchString("Started 5 workers") {
+			t.Errorf("expected compiled log pattern, got %+v", worker)
+		}
+		if collector := byName["collector"].Ready; collector.Type != "file" || collector.Path != "tmp/ready" {
+			t.Errorf("expected health alias to set ready check, got %+v", collector)
+		}
+		if cron := byName["cron"].Ready; cron.Type != "none" {
+			t.Errorf("expected bare type, got %+v", cron)
+		}
+	})
+
+	t.Run("rejects incomplete or unknown checks", func(t *testing.T) {
+		tests := map[string]string{
+			"unknown": "ready: websocket",
+			"exec":    "ready: {type: exec}",
+			"file":    "ready: {type: file}",
+			"log":     "ready: {type: log}",
+			"regexp":  "ready: {type: log, pattern: \"(\"}",
+		}
+		for name, ready := range tests {
+			yaml := "root: /tmp/badready\ncmd: ./worker\n" + ready + "\n"
+			path := filepath.Join(tmpDir, "badready-"+name+".yml")
+			os.WriteFile(path, []byte(yaml), 0644)
+
+			if _, err := store.loadYAMLApp("badready.yml", path); err == nil {
+				t.Errorf("%s: expected error for %q", name, ready)
+			}
+		}
+	})
+}
+
+func TestPreferredPortConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{Dir: tmpDir}
+	store := NewAppStore(cfg)
+
+	yaml := `
+root: /tmp/prefport
+services:
+  web:
+    cmd: npm start
+    preferred_port: 3000
+  api:
+    cmd: ./api
+`
+	path := filepath.Join(tmpDir, "prefport.yml")
+	os.WriteFile(path, []byte(yaml), 0644)
+
+	app, err := store.loadYAMLApp("prefport.yml", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, svc := range app.Services {
+		want := 0
+		if svc.Name == "web" {
+			want = 3000
+		}
+		if svc.PreferredPort != want {
+			t.Errorf("service %s: expected preferred port %d, got %d", svc.Name, want, svc.PreferredPort)
+		}
+	}
+}
+
+func TestHooksConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{Dir: tmpDir}
+	store := NewAppStore(cfg)
+
+	yaml := `
+root: /tmp/hooks
+before_start: bundle install
+after_stop: rm -f tmp/pids/server.pid
+services:
+  web:
+    cmd: bin/rails server
+    after_ready: bin/seed
+  worker:
+    cmd: bin/jobs
+    before_start: bin/check-redis
+`
+	path := filepath.Join(tmpDir, "hooks.yml")
+	os.WriteFile(path, []byte(yaml), 0644)
+
+	app, err := store.loadYAMLApp("hooks.yml", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, svc := range app.Services {
+		switch svc.Name {
+		case "web":
+			if svc.BeforeStart != "bundle install" || svc.AfterReady != "bin/seed" || svc.AfterStop != "rm -f tmp/pids/server.pid" {
+				t.Errorf("web: expected inherited and own hooks, got %+v", svc.ProcessConfig)
+			}
+		case "worker":
+			if svc.BeforeStart != "bin/check-redis" || svc.AfterReady != "" {
+				t.Errorf("worker: expected its own before_start only, got %+v", svc.ProcessConfig)
+			}
+		}
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want WatchConfig
+	}{
+		{"true watches everything", "watch: true", WatchConfig{Enabled: true}},
+		{"false disables", "watch: false", WatchConfig{}},
+		{"single glob", `watch: "**/*.go"`, WatchConfig{Enabled: true, Include: []string{"**/*.go"}}},
+		{"list of globs", "watch: [\"*.py\", pyproject.toml]", WatchConfig{Enabled: true, Include: []string{"*.py", "pyproject.toml"}}},
+		{
+			"mapping",
+			"watch:\n  include: [\"lib/**\"]\n  exclude: [\"*_test.exs\"]\n  debounce: 500ms",
+			WatchConfig{Enabled: true, Include: []string{"lib/**"}, Exclude: []string{"*_test.exs"}, Debounce: 500 * time.Millisecond},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg struct {
+				Watch WatchConfig `yaml:"watch"`
+			}
+			if err := yaml.Unmarshal([]byte(tt.yaml), &cfg); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Watch.Enabled != tt.want.Enabled || !slices.Equal(cfg.Watch.Include, tt.want.Include) ||
+				!slices.Equal(cfg.Watch.Exclude, tt.want.Exclude) || cfg.Watch.Debounce != tt.want.Debounce {
+				t.Errorf("expected %+v, got %+v", tt.want, cfg.Watch)
+			}
+		})
+	}
+
+	t.Run("rejects invalid globs", func(t *testing.T) {
+		var cfg struct {
+			Watch WatchConfig `yaml:"watch"`
+		}
+		if err := yaml.Unmarshal([]byte(`watch: "src/[*.go"`), &cfg); err == nil {
+			t.Error("expected error for malformed glob")
+		}
+	})
+}
+
+func TestNamedPortsConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{Dir: tmpDir}
+	store := NewAppStore(cfg)
+
+	t.Run("parses ports per service", func(t *testing.T) {
+		yaml := `
+root: /tmp/namedports
+services:
+  web:
+    cmd: npm start
+    ports: [livereload, debug]
+  api:
+    cmd: ./api
+`
+		path := filepath.Join(tmpDir, "namedports.yml")
+		os.WriteFile(path, []byte(yaml), 0644)
+
+		app, err := store.loadYAMLApp("namedports.yml", path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, svc := range app.Services {
+			if svc.Name == "web" && !slices.Equal(svc.NamedPorts, []string{"livereload", "debug"}) {
+				t.Errorf("expected web ports [livereload debug], got %v", svc.NamedPorts)
+			}
+			if svc.Name == "api" && len(svc.NamedPorts) != 0 {
+				t.Errorf("expected no api ports, got %v", svc.NamedPorts)
+			}
+		}
+	})
+
+	t.Run("rejects invalid and duplicate names", func(t *testing.T) {
+		for _, ports := range []string{"[Live_Reload]", "[debug, debug]"} {
+			yaml := "root: /tmp/namedports\ncmd: npm start\nports: " + ports + "\n"
+			path := filepath.Join(tmpDir, "badports.yml")
+			os.WriteFile(path, []byte(yaml), 0644)
+
+			if _, err := store.loadYAMLApp("badports.yml", path); err == nil {
+				t.Errorf("expected error for ports: %s", ports)
+			}
+		}
+	})
+}
+
+func TestIdleTimeoutConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{Dir: tmpDir}
+	store := NewAppStore(cfg)
+
+	t.Run("parses idle_timeout", func(t *testing.T) {
+		yaml := `
+root: /tmp/idle
+cmd: npm start
+idle_timeout: 45m
+`
+		path := filepath.Join(tmpDir, "idle.yml")
+		os.WriteFile(path, []byte(yaml), 0644)
+
+		app, err := store.loadYAMLApp("idle.yml", path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if app.IdleTimeout == nil || *app.IdleTimeout != 45*time.Minute {
+			t.Errorf("expected idle timeout 45m, got %v", app.IdleTimeout)
+		}
+	})
+
+	t.Run("leaves idle_timeout unset when omitted", func(t *testing.T) {
+		yaml := `
+root: /tmp/noidle
+cmd: npm start
+`
+		path := filepath.Join(tmpDir, "noidle.yml")
+		os.WriteFile(path, []byte(yaml), 0644)
+
+		app, err := store.loadYAMLApp("noidle.yml", path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if app.IdleTimeout != nil {
+			t.Errorf("expected nil idle timeout, got %v", *app.IdleTimeout)
+		}
+	})
+}
+
+func TestExecConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{Dir: tmpDir}
+	store := NewAppStore(cfg)
+
+	load := func(t *testing.T, yaml string) (*App, error) {
+		t.Helper()
+		path := filepath.Join(tmpDir, "exec.yml")
+		os.WriteFile(path, []byte(yaml), 0644)
+		return store.loadYAMLApp("exec.yml", path)
+	}
+
+	t.Run("top-level exec", func(t *testing.T) {
+		app, err := load(t, "root: /tmp/exec\nexec: [bin/rails, server, -p, $PORT]\n")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if app.Type != AppTypeCommand || !slices.Equal(app.Exec, []string{"bin/rails", "server", "-p", "$PORT"}) {
+			t.Errorf("expected command app with exec argv, got %+v", app)
+		}
+		if app.Command != "bin/rails server -p $PORT" {
+			t.Errorf("expected joined command for display, got %q", app.Command)
+		}
+	})
+
+	t.Run("service exec inherits shell", func(t *testing.T) {
+		app, err := load(t, `
+root: /tmp/exec
+shell: plain
+services:
+  web:
+    exec: [bin/rails, server]
+  worker:
+    cmd: bin/jobs
+    shell: login
+`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, svc := range app.Services {
+			switch svc.Name {
+			case "web":
+				if len(svc.Exec) != 2 || svc.Shell != "plain" {
+					t.Errorf("web: expected exec with inherited shell, got %+v", svc)
+				}
+			case "worker":
+				if svc.Exec != nil || svc.Shell != "login" {
+					t.Errorf("worker: expected cmd with its own shell, got %+v", svc)
+				}
+			}
+		}
+	})
+
+	errorTests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"cmd and exec", "root: /tmp\ncmd: npm start\nexec: [npm, start]\n", "can't both be set"},
+		{"shell none with cmd", "root: /tmp\ncmd: npm start\nshell: none\n", "shell: none needs exec"},
+		{"inherited shell none with cmd", "root: /tmp\nshell: none\nservices:\n  web:\n    cmd: npm start\n", "service web: shell: none needs exec"},
+		{"unknown shell", "root: /tmp\nexec: [npm, start]\nshell: zsh\n", "invalid shell"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(t, tt.yaml)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	t.Run("shell path", func(t *testing.T) {
+		app, err := load(t, "root: /tmp\ncmd: npm start\nshell: /bin/bash\n")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if app.Shell != "/bin/bash" {
+			t.Errorf("expected shell path, got %q", app.Shell)
+		}
+	})
+}
+
+func TestParseDotenv(t *testing.T) {
+	t.Setenv("ROOST_TEST_HOME", "/home/me")
+	data := `# comment
+FOO=bar
+export EXPORTED=yes
+SPACED = value with spaces   # trailing comment
+HASH=abc#def
+SINGLE='literal $FOO \n'
+DOUBLE="line1\nline2 \"quoted\" \$FOO"
+MULTI="first
+second"
+REF=${FOO}-$FOO-${ROOST_TEST_HOME}
+DEFAULT=${MISSING:-fallback}
+LATER=${PORT}
+EMPTY=
+`
+	env := map[string]string{}
+	if err := parseDotenv(data, env); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"FOO":      "bar",
+		"EXPORTED": "yes",
+		"SPACED":   "value with spaces",
+		"HASH":     "abc#def",
+		"SINGLE":   `literal $FOO \n`,
+		"DOUBLE":   "line1\nline2 \"quoted\" $FOO",
+		"MULTI":    "first\nsecond",
+		"REF":      "bar-bar-/home/me",
+		"DEFAULT":  "fallback",
+		"LATER":    "${PORT}",
+		"EMPTY":    "",
+	}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("%s = %q, want %q", k, env[k], v)
+		}
+	}
+	if len(env) != len(want) {
+		t.Errorf("expected %d variables, got %v", len(want), env)
+	}
+
+	errorTests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"missing equals", "FOO\n", "1: expected KEY=value"},
+		{"invalid name", "\n1FOO=bar\n", "2: expected KEY=value"},
+		{"unterminated quote", "FOO=\"bar\n", "1: unterminated \" quote"},
+		{"text after quote", "FOO='bar' baz\n", "1: unexpected \"baz\""},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseDotenv(tt.data, map[string]string{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestEnvFileConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "api"), 0755)
+	os.WriteFile(filepath.Join(root, ".env"), []byte("SHARED=root\nLEVEL=app\nDB=postgres://localhost/dev\n"), 0644)
+	os.WriteFile(filepath.Join(root, ".env.development"), []byte("LEVEL=development\n"), 0644)
+	os.WriteFile(filepath.Join(root, "api", ".env"), []byte("LEVEL=service\nAPI_DB=${DB}_api\n"), 0644)
+
+	cfg := &Config{Dir: tmpDir}
+	store := NewAppStore(cfg)
+	yaml := `
+root: ` + root + `
+env_file: [.env, .env.development, .env.local]
+services:
+  api:
+    dir: api
+    cmd: bin/api
+    env_file: .env
+    env:
+      LEVEL: inline
+  web:
+    cmd: bin/web
+`
+	path := filepath.Join(tmpDir, "envfile.yml")
+	os.WriteFile(path, []byte(yaml), 0644)
+
+	app, err := store.loadYAMLApp("envfile.yml", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, svc := range app.Services {
+		switch svc.Name {
+		case "api":
+			if svc.Env["LEVEL"] != "inline" || svc.Env["SHARED"] != "root" || svc.Env["API_DB"] != "postgres://localhost/dev_api" {
+				t.Errorf("api: expected app files, service file, then inline env, got %v", svc.Env)
+			}
+			wantFiles := []string{
+				filepath.Join(root, ".env"), filepath.Join(root, ".env.development"),
+				filepath.Join(root, ".env.local"), filepath.Join(root, "api", ".env"),
+			}
+			if !slices.Equal(svc.EnvFiles, wantFiles) {
+				t.Errorf("api: expected env files %v, got %v", wantFiles, svc.EnvFiles)
+			}
+		case "web":
+			if svc.Env["LEVEL"] != "development" {
+				t.Errorf("web: expected later app file to win, got %v", svc.Env)
+			}
+		}
+	}
+
+	t.Run("EnvFiles maps each file to its apps", func(t *testing.T) {
+		if err := store.Load(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		owners := store.EnvFiles()
+		for _, f := range []string{".env.local", "api/.env"} {
+			if got := owners[filepath.Join(root, f)]; !slices.Equal(got, []string{"envfile"}) {
+				t.Errorf("%s: expected [envfile], got %v", f, got)
+			}
+		}
+	})
+
+	t.Run("parse errors name the file and line", func(t *testing.T) {
+		os.WriteFile(filepath.Join(root, ".env.local"), []byte("OK=1\nbroken\n"), 0644)
+		defer os.Remove(filepath.Join(root, ".env.local"))
+		_, err := store.loadYAMLApp("envfile.yml", path)
+		want := filepath.Join(root, ".env.local") + ":2: expected KEY=value"
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	})
+}
+
+func TestInheritEnvConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{Dir: tmpDir}
+	store := NewAppStore(cfg)
+
+	yaml := `
+root: /tmp
+inherit_env: false
+services:
+  web:
+    cmd: bin/web
+  api:
+    cmd: bin/api
+    inherit_env: [NPM_TOKEN, "LC_*"]
+  legacy:
+    cmd: bin/legacy
+    inherit_env: true
+`
+	path := filepath.Join(tmpDir, "inherit.yml")
+	os.WriteFile(path, []byte(yaml), 0644)
+
+	app, err := store.loadYAMLApp("inherit.yml", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, svc := range app.Services {
+		switch svc.Name {
+		case "web":
+			if !svc.InheritEnv.Clean() || len(svc.InheritEnv.Allow) != 0 {
+				t.Errorf("web: expected inherited inherit_env: false, got %+v", svc.InheritEnv)
+			}
+		case "api":
+			if !svc.InheritEnv.Clean() || !slices.Equal(svc.InheritEnv.Allow, []string{"NPM_TOKEN", "LC_*"}) {
+				t.Errorf("api: expected its own allowlist, got %+v", svc.InheritEnv)
+			}
+		case "legacy":
+			if svc.InheritEnv.Clean() {
+				t.Errorf("legacy: expected to inherit everything, got %+v", svc.InheritEnv)
+			}
+		}
+	}
+
+	t.Run("rejects other values", func(t *testing.T) {
+		os.WriteFile(path, []byte("root: /tmp\ncmd: bin/web\ninherit_env: sometimes\n"), 0644)
+		_, err := store.loadYAMLApp("inherit.yml", path)
+		if err == nil || !strings.Contains(err.Error(), "inherit_env must be true, false or a list") {
+			t.Errorf("expected inherit_env error, got %v", err)
+		}
+	})
+}
+
+func TestWorkerConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &Config{Dir: tmpDir}
+	store := NewAppStore(cfg)
+	path := filepath.Join(tmpDir, "workers.yml")
+
+	yaml := `
+root: /tmp
+ready: http
+services:
+  web:
+    cmd: bin/web
+  jobs:
+    cmd: bin/jobs
+    type: worker
+  mailer:
+    cmd: bin/mailer
+    port: false
+`
+	os.WriteFile(path, []byte(yaml), 0644)
+	app, err := store.loadYAMLApp("workers.yml", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, svc := range app.Services {
+		switch svc.Name {
+		case "web":
+			if svc.Worker || svc.Ready.Type != "http" {
+				t.Errorf("web: expected a web service with the app's ready check, got %+v", svc)
+			}
+		case "jobs", "mailer":
+			if !svc.Worker {
+				t.Errorf("%s: expected a worker", svc.Name)
+			}
+			if svc.Ready.Type != "" {
+				t.Errorf("%s: expected the app's port-based ready check to be dropped, got %q", svc.Name, svc.Ready.Type)
+			}
+		}
+	}
+
+	errCases := map[string]string{
+		"type: cron":                             `invalid type "cron"`,
+		"type: worker\n    default: true":        "a worker can't be the default service",
+		"type: worker\n    preferred_port: 4000": "preferred_port doesn't apply",
+		"type: worker\n    ready: tcp":           "ready: tcp needs a port to check",
+	}
+	for opts, want := range errCases {
+		t.Run(want, func(t *testing.T) {
+			yaml := "root: /tmp\nservices:\n  web:\n    cmd: bin/web\n  jobs:\n    cmd: bin/jobs\n    " + opts + "\n"
+			os.WriteFile(path, []byte(yaml), 0644)
+			_, err := store.loadYAMLApp("workers.yml", path)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("expected error containing %q, got %v", want, err)
+			}
+		})
+	}
+
+	t.Run("a worker's ready check on another port is fine", func(t *testing.T) {
+		yaml := "root: /tmp\nservices:\n  web:\n    cmd: bin/web\n  jobs:\n    cmd: bin/jobs\n    type: worker\n    ready: {type: tcp, port: 9000}\n"
+		os.WriteFile(path, []byte(yaml), 0644)
+		if _, err := store.loadYAMLApp("workers.yml", path); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("needs one service that isn't a worker", func(t *testing.T) {
+		yaml := "root: /tmp\nservices:\n  jobs:\n    cmd: bin/jobs\n    type: worker\n"
+		os.WriteFile(path, []byte(yaml), 0644)
+		_, err := store.loadYAMLApp("workers.yml", path)
+		if err == nil || !strings.Contains(err.Error(), "every service is a worker") {
+			t.Errorf("expected all-workers error, got %v", err)
+		}
+	})
+}
+
+func TestRestartWithConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewAppStore(&Config{Dir: tmpDir})
+	path := filepath.Join(tmpDir, "cascade.yml")
+
+	os.WriteFile(path, []byte(`
+root: /tmp
+services:
+  api:
+    cmd: bin/api
+  web:
+    cmd: bin/web
+    depends_on: [api]
+    restart_with: [api]
+`), 0644)
+	app, err := store.loadYAMLApp("cascade.yml", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if web := app.Services[1]; web.Name != "web" || !slices.Equal(web.RestartWith, []string{"api"}) {
+		t.Errorf("expected web to restart with api, got %+v", web)
+	}
+
+	errCases := map[string]string{
+		"restart_with: [db]":  `restart_with: unknown service "db"`,
+		"restart_with: [web]": "restart_with can't name the service itself",
+	}
+	for opt, want := range errCases {
+		t.Run(want, func(t *testing.T) {
+			os.WriteFile(path, []byte("root: /tmp\nservices:\n  api:\n    cmd: bin/api\n  web:\n    cmd: bin/web\n    "+opt+"\n"), 0644)
+			_, err := store.loadYAMLApp("cascade.yml", path)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("expected error containing %q, got %v", want, err)
+			}
+		})
+	}
+}
+
+func TestLimitsConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewAppStore(&Config{Dir: tmpDir})
+	path := filepath.Join(tmpDir, "limits.yml")
+
+	os.WriteFile(path, []byte(`
+root: /tmp
+limits:
+  memory: 2G
+  nice: 10
+services:
+  web:
+    cmd: bin/web
+  webpack:
+    cmd: bin/webpack
+    limits:
+      memory: 1.5GiB
+      open_files: 4096
+      ionice: best-effort:6
+`), 0644)
+	app, err := store.loadYAMLApp("limits.yml", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, svc := range app.Services {
+		switch svc.Name {
+		case "web":
+			want := LimitsConfig{Memory: 2 << 30, Nice: 10}
+			if svc.Limits != want {
+				t.Errorf("web: expected the app's limits %+v, got %+v", want, svc.Limits)
+			}
+		case "webpack":
+			want := LimitsConfig{Memory: 3 << 29, OpenFiles: 4096, Nice: 10, IONice: IONice{Class: "best-effort", Level: 6}}
+			if svc.Limits != want {
+				t.Errorf("webpack: expected %+v, got %+v", want, svc.Limits)
+			}
+		}
+	}
+
+	sizes := map[string]int64{"512M": 512 << 20, "1073741824": 1 << 30, "64kb": 64 << 10, "1T": 1 << 40}
+	for s, want := range sizes {
+		if got, err := parseByteSize(s); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+
+	errCases := map[string]string{
+		"memory: lots":          `invalid size "lots"`,
+		"memory: -1G":           `invalid size "-1G"`,
+		"open_files: -1":        "open_files must be positive",
+		"nice: 20":              "nice must be between -20 and 19",
+		"ionice: realtime":      `invalid ionice "realtime"`,
+		"ionice: idle:3":        `invalid ionice "idle:3"`,
+		"ionice: best-effort:8": "ionice level must be 0-7",
+	}
+	for limit, want := range errCases {
+		t.Run(want, func(t *testing.T) {
+			os.WriteFile(path, []byte("root: /tmp\ncmd: bin/web\nlimits:\n  "+limit+"\n"), 0644)
+			_, err := store.loadYAMLApp("limits.yml", path)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("expected error containing %q, got %v", want, err)
+			}
+		})
+	}
+}
+
+func TestPIDFileConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewAppStore(&Config{Dir: tmpDir})
+	path := filepath.Join(tmpDir, "pids.yml")
+
+	os.WriteFile(path, []byte(`
+root: /tmp
+pidfile: tmp/pids/server.pid
+services:
+  web:
+    cmd: bin/web
+  jobs:
+    cmd: bin/jobs
+    pidfile: tmp/pids/jobs.pid
+`), 0644)
+	app, err := store.loadYAMLApp("pids.yml", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, svc := range app.Services {
+		want := map[string]string{"web": "tmp/pids/server.pid", "jobs": "tmp/pids/jobs.pid"}[svc.Name]
+		if svc.PIDFile != want {
+			t.Errorf("%s: expected pidfile %q, got %q", svc.Name, want, svc.PIDFile)
+		}
+	}
+}
+
+func TestLinkProject(t *testing.T) {
+	configDir := t.TempDir()
+	project := filepath.Join(t.TempDir(), "Shop")
+	os.MkdirAll(filepath.Join(project, "web"), 0755)
+	os.WriteFile(filepath.Join(project, "roost.yml"), []byte(`
+services:
+  web:
+    dir: web
+    cmd: bin/web
+  api:
+    cmd: bin/api
+`), 0644)
+
+	link, err := LinkProject(configDir, project)
+	if err != nil {
+		t.Fatalf("link failed: %v", err)
+	}
+	target := filepath.Join(project, "roost.yml")
+	if link.Name != "shop" || link.Path != filepath.Join(configDir, "shop.yml") || link.Target != target {
+		t.Errorf("unexpected link %+v", link)
+	}
+	if again, err := LinkProject(configDir, target); err != nil || again.Path != link.Path {
+		t.Errorf("expected linking again to be a no-op, got %+v, %v", again, err)
+	}
+
+	store := NewAppStore(&Config{Dir: configDir})
+	if err := store.Load(); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	app, ok := store.Get("shop")
+	if !ok {
+		t.Fatal("expected the linked app to load")
+	}
+	if app.ConfigFile != target {
+		t.Errorf("expected ConfigFile %s, got %s", target, app.ConfigFile)
+	}
+	for _, svc := range app.Services {
+		want := map[string]string{"web": filepath.Join(project, "web"), "api": project}[svc.Name]
+		if svc.Dir != want {
+			t.Errorf("%s: expected dir %s relative to the project, got %s", svc.Name, want, svc.Dir)
+		}
+	}
+	if files := store.LinkedFiles(); files[target] != "shop" {
+		t.Errorf("expected %s in linked files, got %v", target, files)
+	}
+
+	t.Run("name collision", func(t *testing.T) {
+		other := filepath.Join(t.TempDir(), "shop")
+		os.Mkdir(other, 0755)
+		os.WriteFile(filepath.Join(other, "roost.yml"), []byte("cmd: bin/server\n"), 0644)
+		if _, err := LinkProject(configDir, other); err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("expected a collision error, got %v", err)
+		}
+	})
+
+	t.Run("missing roost.yml", func(t *testing.T) {
+		if _, err := LinkProject(configDir, t.TempDir()); err == nil || !strings.Contains(err.Error(), "no roost.yml") {
+			t.Errorf("expected a missing file error, got %v", err)
+		}
+	})
+
+	t.Run("unlink", func(t *testing.T) {
+		if _, err := UnlinkProject(configDir, "nope"); err == nil {
+			t.Error("expected an error for an unknown app")
+		}
+		links, err := UnlinkProject(configDir, project)
+		if err != nil || len(links) != 1 || links[0].Name != "shop" {
+			t.Fatalf("expected shop unlinked, got %+v, %v", links, err)
+		}
+		if _, err := os.Lstat(link.Path); !os.IsNotExist(err) {
+			t.Error("expected the link removed")
+		}
+		if _, err := os.Stat(target); err != nil {
+			t.Error("expected the project's file kept")
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	project := t.TempDir()
+	os.Mkdir(filepath.Join(project, "web"), 0755)
+
+	tests := map[string]struct {
+		files map[string]string
+		want  []string // file:line:col: severity: message, with the file's base name
+	}{
+		"valid": {
+			files: map[string]string{"shop.yml": "root: " + project + "\nservices:\n  web:\n    dir: web\n    cmd: bin/web\n"},
+		},
+		"unknown key": {
+			files: map[string]string{"shop.yml": "root: " + project + "\ncmd: bin/server\nready:\n  typ: http\n"},
+			want:  []string{`shop.yml:4:3: warning: unknown key "typ" is ignored (did you mean "type"?)`},
+		},
+		"missing root": {
+			files: map[string]string{"shop.yml": "cmd: bin/server\n"},
+			want:  []string{"shop.yml: warning: no root set, so commands run in roost-dev's own directory"},
+		},
+		"nonexistent dirs": {
+			files: map[string]string{
+				"shop.yml":  "root: " + project + "\nservices:\n  api:\n    dir: api\n    cmd: bin/api\n",
+				"admin.yml": "root: /nonexistent\ncmd: bin/server\n",
+			},
+			want: []string{
+				"admin.yml:1:7: error: root /nonexistent doesn't exist",
+				"shop.yml:4:10: error: service api: dir " + filepath.Join(project, "api") + " doesn't exist",
+			},
+		},
+		"unknown dependency": {
+			files: map[string]string{"shop.yml": "root: " + project + "\nservices:\n  web:\n    cmd: bin/web\n    depends_on: [webb]\n"},
+			want:  []string{`shop.yml:5:18: error: service web: depends_on: unknown service "webb" (did you mean "web"?)`},
+		},
+		"dependency cycle": {
+			files: map[string]string{"shop.yml": "root: " + project + "\nservices:\n  a:\n    cmd: a\n    depends_on: [b]\n  b:\n    cmd: b\n    depends_on: [a]\n"},
+			want:  []string{"shop.yml:5:5: error: dependency cycle:"},
+		},
+		"service name with spaces": {
+			files: map[string]string{"shop.yml": "root: " + project + "\nservices:\n  my web:\n    cmd: bin/web\n"},
+			want:  []string{`shop.yml:3:3: error: service "my web" is skipped: service names can't contain spaces`},
+		},
+		"parse error": {
+			files: map[string]string{"shop.yml": "root: " + project + "\ncmd: bin/server\n  port: 3000\n"},
+			want:  []string{"shop.yml:3: error: mapping values are not allowed"},
+		},
+		"hostname collision": {
+			files: map[string]string{
+				"shop.yml":  "root: " + project + "\ncmd: bin/server\n",
+				"store.yml": "root: " + project + "\ncmd: bin/server\naliases: [shop]\n",
+			},
+			want: []string{`store.yml:3:11: error: hostname "shop" of alias shop of store is also used by app shop`},
+		},
+		"references": {
+			files: map[string]string{"shop.yml": "root: " + project + "\nservices:\n  web:\n    cmd: bin/web -p ${port}\n    env:\n      API: ${service.apu.url}\n    before_start: cd ${rot}\n  jobs:\n    cmd: bin/jobs --port ${port}\n    type: worker\n"},
+			want: []string{
+				`shop.yml:6:12: error: env: ${service.apu.url}: unknown service "apu"`,
+				`shop.yml:7:19: warning: before_start: ${rot} isn't a reference, so it's left for the shell (did you mean "root"?)`,
+				"shop.yml:9:10: error: cmd: ${port}: the process has no port",
+			},
+		},
+		"profiles": {
+			files: map[string]string{"shop.yml": "root: " + project + "\nservices:\n  web:\n    cmd: bin/web\nprofiles:\n  staging:\n    services:\n      web:\n        env:\n          API: ${service.apu.url}\n  lean:\n    without: [jobs]\n"},
+			want: []string{
+				`shop.yml:10:16: error: env: ${service.apu.url}: unknown service "apu"`,
+				`shop.yml:11:3: error: profile lean: without: unknown service "jobs"`,
+			},
+		},
+		"reserved name": {
+			files: map[string]string{"roost-dev.yml": "root: " + project + "\ncmd: bin/server\n"},
+			want:  []string{`roost-dev.yml: error: app roost-dev can't be reached: "roost-dev" is reserved for the dashboard`},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			for file, content := range tc.files {
+				os.WriteFile(filepath.Join(dir, file), []byte(content), 0644)
+			}
+			diags, err := Validate(&Config{Dir: dir, TLD: "test"})
+			if err != nil {
+				t.Fatalf("validate failed: %v", err)
+			}
+			var got []string
+			for _, d := range diags {
+				got = append(got, strings.TrimPrefix(d.String(), dir+"/"))
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %d diagnostics, got %q", len(tc.want), got)
+			}
+			for i, want := range tc.want {
+				if !strings.HasPrefix(got[i], want) {
+					t.Errorf("expected %q, got %q", want, got[i])
+				}
+			}
+			if HasErrors(diags) != slices.ContainsFunc(tc.want, func(s string) bool { return strings.Contains(s, ": error:") }) {
+				t.Errorf("HasErrors wrong for %q", got)
+			}
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal(Schema(), &schema); err != nil {
+		t.Fatalf("schema isn't JSON: %v", err)
+	}
+	if schema["$schema"] == nil || schema["additionalProperties"] != false {
+		t.Errorf("unexpected top level %v", schema)
+	}
+
+	props := schema["properties"].(map[string]any)
+	for _, key := range []string{"root", "cmd", "services", "ready", "restart", "limits", "pidfile", "idle_timeout"} {
+		if _, ok := props[key]; !ok {
+			t.Errorf("expected app key %s in the schema", key)
+		}
+	}
+	if _, ok := props["Process"]; ok {
+		t.Error("expected the inlined process settings to be flattened")
+	}
+	if desc, _ := props["health"].(map[string]any)["description"].(string); desc != "Alias for ready" {
+		t.Errorf("expected the inlined fields described, got %q", desc)
+	}
+
+	service := props["services"].(map[string]any)["additionalProperties"].(map[string]any)
+	svcProps := service["properties"].(map[string]any)
+	for _, key := range []string{"dir", "depends_on", "restart_with", "type", "stop_signal"} {
+		if _, ok := svcProps[key]; !ok {
+			t.Errorf("expected service key %s in the schema", key)
+		}
+	}
+	if got := svcProps["type"].(map[string]any)["enum"]; len(got.([]any)) != 2 {
+		t.Errorf("expected web and worker, got %v", got)
+	}
+	signal := svcProps["stop_signal"].(map[string]any)["enum"].([]any)
+	if !slices.Contains(signal, any("TERM")) || !slices.Contains(signal, any("sigint")) {
+		t.Errorf("expected signal names, got %v", signal)
+	}
+	if ready := svcProps["ready"].(map[string]any); len(ready["anyOf"].([]any)) != 2 {
+		t.Errorf("expected ready as a bare type or a mapping, got %v", ready)
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("ROOST_TEST_HOME", "/home/me")
+	vars := Vars{
+		Root: "/code/shop",
+		Name: "shop",
+		TLD:  "test",
+		Port: 5000,
+		Services: map[string]ServiceVars{
+			"api":  {URL: "http://api-shop.test", Port: 7000},
+			"jobs": {},
+		},
+		Env: map[string]string{
+			"PORT":          "5000",
+			"PORT_DEBUG":    "6000",
+			"PORT_DEBUG_UI": "6001",
+			"PORT_API":      "7000",
+			"URL_API":       "http://api-shop.test",
+		},
+	}
+
+	tests := []struct {
+		in, want, wantEnv string
+	}{
+		{in: "${root}/web", want: "/code/shop/web"},
+		{in: "${name}.${tld}:${port}", want: "shop.test:5000"},
+		{in: "${env:ROOST_TEST_HOME}/.cache", want: "/home/me/.cache"},
+		{in: "${service.api.url}/graphql", want: "http://api-shop.test/graphql"},
+		{in: "--api-port=${service.api.port}", want: "--api-port=7000"},
+		{in: "$${root} stays", want: "${root} stays"},
+		{in: "${HOME:-/tmp} ${unclosed", want: "${HOME:-/tmp} ${unclosed"},
+		// Env values and exec args also get $PORT and friends
+		{in: "http://localhost:$PORT", want: "http://localhost:$PORT", wantEnv: "http://localhost:5000"},
+		{in: "$PORT,$PORT_DEBUG,$PORT_DEBUG_UI", want: "$PORT,$PORT_DEBUG,$PORT_DEBUG_UI", wantEnv: "5000,6000,6001"},
+		{in: "$PORT_API/$URL_API", want: "$PORT_API/$URL_API", wantEnv: "7000/http://api-shop.test"},
+		{in: "$PORT_OTHER $HOME", want: "$PORT_OTHER $HOME", wantEnv: "5000_OTHER $HOME"},
+		{in: "${PORT}_OTHER:${PORT_DEBUG}", want: "${PORT}_OTHER:${PORT_DEBUG}", wantEnv: "5000_OTHER:6000"},
+	}
+	for _, tt := range tests {
+		if got, err := vars.Interpolate(tt.in); err != nil || got != tt.want {
+			t.Errorf("Interpolate(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+		wantEnv := tt.wantEnv
+		if wantEnv == "" {
+			wantEnv = tt.want
+		}
+		if got, err := vars.InterpolateEnv(tt.in); err != nil || got != wantEnv {
+			t.Errorf("InterpolateEnv(%q) = %q, %v, want %q", tt.in, got, err, wantEnv)
+		}
+	}
+
+	errors := map[string]string{
+		"${service.apu.url}":   `unknown service "apu" (did you mean "api"?)`,
+		"${service.api.host}":  `unknown property "host"`,
+		"${service.jobs.port}": "jobs is a worker and has no port",
+		"${service.api}":       "expected ${service.<name>.url}",
+		"${env:}":              "invalid variable name",
+	}
+	for in, want := range errors {
+		if _, err := vars.Interpolate(in); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Interpolate(%q): expected error containing %q, got %v", in, want, err)
+		}
+	}
+	worker := Vars{}
+	if _, err := worker.Interpolate("--port ${port}"); err == nil {
+		t.Error("expected ${port} to fail for a process without a port")
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewAppStore(&Config{Dir: tmpDir})
+	path := filepath.Join(tmpDir, "shop.yml")
+
+	os.WriteFile(path, []byte(`
+root: /tmp
+services:
+  web:
+    cmd: bin/dev
+    env:
+      DATABASE_URL: postgres://localhost/shop
+      LOG_LEVEL: debug
+    depends_on: [jobs]
+  jobs:
+    cmd: bin/jobs
+    type: worker
+profiles:
+  staging:
+    description: Against the staging database
+    env:
+      DATABASE_URL: postgres://staging/shop
+  prod:
+    env:
+      RAILS_ENV: production
+    services:
+      web:
+        cmd: bin/serve
+        env:
+          LOG_LEVEL: info
+    without: [jobs]
+`), 0644)
+
+	load := func(active string) *App {
+		t.Helper()
+		store.profiles = map[string]string{"shop": active}
+		app, err := store.loadYAMLApp("shop.yml", path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return app
+	}
+
+	app := load("")
+	if app.Profile != "" || len(app.Profiles) != 2 || app.Profiles[0].Name != "prod" || app.Profiles[1].Description != "Against the staging database" {
+		t.Errorf("expected no active profile and prod, staging listed, got %q %+v", app.Profile, app.Profiles)
+	}
+	if web := app.Services[1]; web.Command != "bin/dev" || web.Env["DATABASE_URL"] != "postgres://localhost/shop" {
+		t.Errorf("expected the config as written, got %+v", web)
+	}
+
+	app = load("staging")
+	if app.Profile != "staging" || len(app.Services) != 2 {
+		t.Fatalf("expected staging with both services, got %q %+v", app.Profile, app.Services)
+	}
+	for _, svc := range app.Services {
+		if svc.Env["DATABASE_URL"] != "postgres://staging/shop" {
+			t.Errorf("%s: expected the profile's env, got %v", svc.Name, svc.Env)
+		}
+	}
+
+	app = load("prod")
+	if len(app.Services) != 1 {
+		t.Fatalf("expected jobs left out, got %+v", app.Services)
+	}
+	web := app.Services[0]
+	if web.Command != "bin/serve" || len(web.DependsOn) != 0 {
+		t.Errorf("expected web overridden without its dependency on jobs, got %+v", web)
+	}
+	if web.Env["RAILS_ENV"] != "production" || web.Env["LOG_LEVEL"] != "info" || web.Env["DATABASE_URL"] != "postgres://localhost/shop" {
+		t.Errorf("expected the profile's env over the service's, got %v", web.Env)
+	}
+
+	if app := load("gone"); app.Profile != "" || len(app.Services) != 2 {
+		t.Errorf("expected a profile no longer in the config to be ignored, got %q", app.Profile)
+	}
+
+	t.Run("single-command app", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "api.yml")
+		os.WriteFile(path, []byte("root: /tmp\ncmd: bin/dev\nprofiles:\n  build:\n    cmd: bin/build && bin/serve\n    env: {NODE_ENV: production}\n"), 0644)
+		store.profiles = map[string]string{"api": "build"}
+		app, err := store.loadYAMLApp("api.yml", path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if app.Command != "bin/build && bin/serve" || app.Env["NODE_ENV"] != "production" {
+			t.Errorf("expected the profile's command and env, got %q %v", app.Command, app.Env)
+		}
+	})
+
+	errCases := map[string]string{
+		"  default:\n    env: {A: b}":               "default is the config without a profile",
+		"  bad name:\n    env: {A: b}":              "invalid name",
+		"  p:\n    cmd: bin/other":                  "cmd and exec apply to a single-command app",
+		"  p:\n    services:\n      webb: {cmd: x}": `unknown service "webb" (did you mean "web"?)`,
+		"  p:\n    without: [jbs]":                  `without: unknown service "jbs" (did you mean "jobs"?)`,
+		"  p:\n    without: [web, jobs]":            "without leaves no services to run",
+	}
+	for profiles, want := range errCases {
+		t.Run(want, func(t *testing.T) {
+			yaml := "root: /tmp\nservices:\n  web:\n    cmd: bin/web\n  jobs:\n    cmd: bin/jobs\nprofiles:\n" + profiles + "\n"
+			os.WriteFile(path, []byte(yaml), 0644)
+			store.profiles = nil
+			_, err := store.loadYAMLApp("shop.yml", path)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("expected error containing %q, got %v", want, err)
+			}
+		})
+	}
+}
+
+func TestSetProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := NewAppStore(&Config{Dir: tmpDir})
+	os.WriteFile(filepath.Join(tmpDir, "shop.yml"), []byte(`
+root: /tmp
+services:
+  web:
+    cmd: bin/web
+  jobs:
+    cmd: bin/jobs
+    type: worker
+profiles:
+  lean:
+    without: [jobs]
+  broken:
+    without: [web]
+`), 0644)
+	if err := store.Load(); err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+
+	if err := store.SetProfile("shop", "lean"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if app, _ := store.Get("shop"); app.Profile != "lean" || len(app.Services) != 1 {
+		t.Errorf("expected lean with one service, got %q %+v", app.Profile, app.Services)
+	}
+	active, err := LoadActiveProfiles(filepath.Join(tmpDir, ProfilesFile))
+	if err != nil || active["shop"] != "lean" {
+		t.Errorf("expected lean saved, got %v %v", active, err)
+	}
+
+	// A fresh store (roost-dev restarting) picks the saved profile up
+	fresh := NewAppStore(&Config{Dir: tmpDir})
+	fresh.Load()
+	if app, _ := fresh.Get("shop"); app == nil || app.Profile != "lean" {
+		t.Errorf("expected lean after a restart, got %+v", app)
+	}
+
+	err = store.SetProfile("shop", "broken")
+	if err == nil || !strings.Contains(err.Error(), "doesn't load with profile broken") {
+		t.Errorf("expected error for a profile leaving only workers, got %v", err)
+	}
+	if app, _ := store.Get("shop"); app == nil || app.Profile != "lean" {
+		t.Errorf("expected to stay on lean, got %+v", app)
+	}
+
+	if err := store.SetProfile("shop", "leen"); err == nil || !strings.Contains(err.Error(), `(did you mean "lean"?)`) {
+		t.Errorf("expected unknown profile error, got %v", err)
+	}
+
+	if err := store.SetProfile("shop", DefaultProfile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if app, _ := store.Get("shop"); app.Profile != "" || len(app.Services) != 2 {
+		t.Errorf("expected the config as written, got %q %+v", app.Profile, app.Services)
+	}
+}
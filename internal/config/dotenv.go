@@ -0,0 +1,224 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envFileList is an env_file value: one path or a list of them
+type envFileList []string
+
+// UnmarshalYAML accepts a single path or a list of paths
+func (l *envFileList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = envFileList{node.Value}
+		return nil
+	}
+	var paths []string
+	if err := node.Decode(&paths); err != nil {
+		return fmt.Errorf("line %d: env_file must be a path or a list of paths", node.Line)
+	}
+	*l = paths
+	return nil
+}
+
+// resolve returns the paths made absolute against dir
+func (l envFileList) resolve(dir string) []string {
+	paths := make([]string, len(l))
+	for i, p := range l {
+		if strings.HasPrefix(p, "~") {
+			home, _ := os.UserHomeDir()
+			p = filepath.Join(home, p[1:])
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		paths[i] = p
+	}
+	return paths
+}
+
+// layerEnv builds a process environment from env files, read in order with
+// later files overriding earlier ones, and then the inline env on top.
+// Missing files are skipped, so optional ones like .env.local can be listed.
+func layerEnv(files []string, inline map[string]string) (map[string]string, error) {
+	if len(files) == 0 {
+		return inline, nil
+	}
+	env := make(map[string]string)
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := parseDotenv(string(data), env); err != nil {
+			return nil, fmt.Errorf("%s:%w", path, err)
+		}
+	}
+	maps.Copy(env, inline)
+	return env, nil
+}
+
+// parseDotenv parses a .env file into env. It supports comments, an export
+// prefix, single quotes (literal), double quotes (escapes like \n, may span
+// lines) and ${VAR}, ${VAR:-default} and $VAR references to earlier
+// variables or roost-dev's environment. References to anything else, like
+// $PORT or ${root}, are left to be filled in when the process starts.
+func parseDotenv(data string, env map[string]string) error {
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNum := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !isEnvName(key) {
+			return fmt.Errorf("%d: expected KEY=value, got %q", lineNum, lines[i])
+		}
+		value = strings.TrimLeft(value, " \t")
+
+		switch {
+		case strings.HasPrefix(value, "'"), strings.HasPrefix(value, `"`):
+			quote := value[0]
+			value = value[1:]
+			// Quoted values may continue over the following lines
+			for closing(value, quote) < 0 && i+1 < len(lines) {
+				i++
+				value += "\n" + lines[i]
+			}
+			end := closing(value, quote)
+			if end < 0 {
+				return fmt.Errorf("%d: unterminated %c quote for %s", lineNum, quote, key)
+			}
+			if rest := strings.TrimSpace(value[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return fmt.Errorf("%d: unexpected %q after quoted value of %s", lineNum, rest, key)
+			}
+			value = value[:end]
+			if quote == '"' {
+				value = expandDotenv(value, env, true)
+			}
+		default:
+			// An unquoted value ends at a comment
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = value[:j]
+			}
+			if j := strings.Index(value, "\t#"); j >= 0 {
+				value = value[:j]
+			}
+			value = expandDotenv(strings.TrimSpace(value), env, false)
+		}
+		env[key] = value
+	}
+	return nil
+}
+
+// closing returns the index of the quote that ends a quoted value, or -1.
+// Backslash escapes only count inside double quotes.
+func closing(value string, quote byte) int {
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// expandDotenv expands variable references in a value, and backslash escapes
+// if escapes is set (double-quoted values)
+func expandDotenv(value string, env map[string]string, escapes bool) string {
+	lookup := func(name string) (string, bool) {
+		if v, ok := env[name]; ok {
+			return v, true
+		}
+		return os.LookupEnv(name)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && escapes && i+1 < len(value):
+			i++
+			switch value[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(value[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(value[i])
+			}
+
+		case c == '$' && i+1 < len(value) && value[i+1] == '{':
+			end := strings.IndexByte(value[i:], '}')
+			if end < 0 {
+				b.WriteString(value[i:])
+				return b.String()
+			}
+			ref := value[i+2 : i+end]
+			name, def, hasDefault := strings.Cut(ref, ":-")
+			if v, ok := lookup(name); ok && (v != "" || !hasDefault) {
+				b.WriteString(v)
+			} else if hasDefault {
+				b.WriteString(def)
+			} else {
+				b.WriteString(value[i : i+end+1])
+			}
+			i += end
+
+		case c == '$':
+			j := i + 1
+			for j < len(value) && isEnvNameChar(value[j], j == i+1) {
+				j++
+			}
+			if v, ok := lookup(value[i+1 : j]); ok && j > i+1 {
+				b.WriteString(v)
+			} else {
+				b.WriteString(value[i:j])
+			}
+			i = j - 1
+
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// isEnvName returns true if s is a valid environment variable name
+func isEnvName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isEnvNameChar(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isEnvNameChar(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
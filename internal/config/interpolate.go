@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// referenceNames are the references that aren't in a namespace like env:
+var referenceNames = []string{"root", "name", "tld", "port"}
+
+// Vars are the values the ${...} references in a process's cmd, exec args,
+// env values, dir and hooks resolve to when it starts:
+//
+//	${root}  ${name}  ${tld}  ${port}  ${env:HOME}
+//	${service.api.url}  ${service.api.port}
+//
+// $${ is a literal ${.
+type Vars struct {
+	Root     string                 // ${root}: the app's root
+	Name     string                 // ${name}: the app's name
+	TLD      string                 // ${tld}
+	Port     int                    // ${port}: the process's port, 0 if it has none
+	Services map[string]ServiceVars // ${service.<name>.url} and ${service.<name>.port}
+
+	// Env holds the variables env values and exec args can also use as $NAME
+	// or ${NAME}: $PORT, $PORT_<NAME> and the sibling variables. Other
+	// strings leave them to the shell.
+	Env map[string]string
+}
+
+// ServiceVars are what a service of the same app exposes to references
+type ServiceVars struct {
+	URL  string // Empty for a worker
+	Port int    // 0 for a worker
+}
+
+// Interpolate fills in the references in s. ${NAME} that isn't a reference
+// is left as is, for the shell.
+func (v Vars) Interpolate(s string) (string, error) {
+	return v.interpolate(s, nil)
+}
+
+// InterpolateEnv fills in the references in an env value or exec arg, along
+// with $NAME and ${NAME} for the variables in v.Env
+func (v Vars) InterpolateEnv(s string) (string, error) {
+	return v.interpolate(s, v.Env)
+}
+
+func (v Vars) interpolate(s string, env map[string]string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "$${"):
+			b.WriteString("${")
+			i += 3
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				b.WriteString(s[i:])
+				return b.String(), nil
+			}
+			value, ok, err := v.resolve(s[i+2:i+end], env)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				value = s[i : i+end+1]
+			}
+			b.WriteString(value)
+			i += end + 1
+		case s[i] == '$' && env != nil:
+			// The longest variable the name starts with, so $PORT_DEBUG_UI
+			// isn't taken for $PORT_DEBUG, or $PORT_API for $PORT
+			j := i + 1
+			for j < len(s) && isEnvNameChar(s[j], j == i+1) {
+				j++
+			}
+			name := s[i+1 : j]
+			for name != "" {
+				if _, ok := env[name]; ok {
+					break
+				}
+				name = name[:len(name)-1]
+			}
+			if name == "" {
+				b.WriteByte('$')
+				i++
+				continue
+			}
+			b.WriteString(env[name])
+			i += 1 + len(name)
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// resolve returns the value of the reference ref (what's inside ${}), or
+// false if it isn't one
+func (v Vars) resolve(ref string, env map[string]string) (string, bool, error) {
+	switch {
+	case ref == "root":
+		return v.Root, true, nil
+	case ref == "name":
+		return v.Name, true, nil
+	case ref == "tld":
+		return v.TLD, true, nil
+	case ref == "port":
+		if v.Port == 0 {
+			return "", false, fmt.Errorf("${port}: the process has no port")
+		}
+		return strconv.Itoa(v.Port), true, nil
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		if !isEnvName(name) {
+			return "", false, fmt.Errorf("${%s}: invalid variable name %q", ref, name)
+		}
+		return os.Getenv(name), true, nil
+	case strings.HasPrefix(ref, "service."):
+		rest := strings.TrimPrefix(ref, "service.")
+		dot := strings.LastIndexByte(rest, '.')
+		if dot < 0 {
+			return "", false, fmt.Errorf("${%s}: expected ${service.<name>.url} or ${service.<name>.port}", ref)
+		}
+		name, attr := rest[:dot], rest[dot+1:]
+		svc, ok := v.Services[name]
+		if !ok {
+			names := slices.Sorted(maps.Keys(v.Services))
+			return "", false, fmt.Errorf("${%s}: unknown service %q%s", ref, name, suggestion(name, names))
+		}
+		switch attr {
+		case "url":
+			if svc.URL == "" {
+				return "", false, fmt.Errorf("${%s}: %s is a worker and has no URL", ref, name)
+			}
+			return svc.URL, true, nil
+		case "port":
+			if svc.Port == 0 {
+				return "", false, fmt.Errorf("${%s}: %s is a worker and has no port", ref, name)
+			}
+			return strconv.Itoa(svc.Port), true, nil
+		default:
+			return "", false, fmt.Errorf("${%s}: unknown property %q (expected url or port)", ref, attr)
+		}
+	}
+	if value, ok := env[ref]; ok {
+		return value, true, nil
+	}
+	return "", false, nil
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LimitsConfig caps the resources a process group can use:
+//
+//	limits:
+//	  memory: 2G          # cgroup memory.max on Linux when available, else RLIMIT_DATA
+//	  open_files: 4096    # RLIMIT_NOFILE
+//	  nice: 10            # lower CPU priority
+//	  ionice: idle        # Linux I/O priority: idle, best-effort or best-effort:0-7
+type LimitsConfig struct {
+	Memory    ByteSize `yaml:"memory"`
+	OpenFiles int      `yaml:"open_files"`
+	Nice      int      `yaml:"nice"`
+	IONice    IONice   `yaml:"ionice"`
+}
+
+// UnmarshalYAML validates the ranges of the numeric limits
+func (l *LimitsConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain LimitsConfig
+	if err := node.Decode((*plain)(l)); err != nil {
+		return err
+	}
+	if l.OpenFiles < 0 {
+		return fmt.Errorf("line %d: limits.open_files must be positive", node.Line)
+	}
+	if l.Nice < -20 || l.Nice > 19 {
+		return fmt.Errorf("line %d: limits.nice must be between -20 and 19", node.Line)
+	}
+	return nil
+}
+
+// inherit fills limits left unset with the values from parent
+func (l LimitsConfig) inherit(parent LimitsConfig) LimitsConfig {
+	if l.Memory == 0 {
+		l.Memory = parent.Memory
+	}
+	if l.OpenFiles == 0 {
+		l.OpenFiles = parent.OpenFiles
+	}
+	if l.Nice == 0 {
+		l.Nice = parent.Nice
+	}
+	if l.IONice.Class == "" {
+		l.IONice = parent.IONice
+	}
+	return l
+}
+
+// ByteSize is a size in bytes, written in YAML as a number of bytes or with
+// a binary unit: 512M, 1.5G, 2GiB
+type ByteSize int64
+
+// UnmarshalYAML parses a size with an optional K, M, G or T unit
+func (b *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	size, err := parseByteSize(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*b = ByteSize(size)
+	return nil
+}
+
+// parseByteSize parses sizes like 512M, 1.5G or 1073741824
+func parseByteSize(s string) (int64, error) {
+	num := strings.TrimSpace(strings.ToUpper(s))
+	for _, suffix := range []string{"IB", "B"} {
+		if trimmed, ok := strings.CutSuffix(num, suffix); ok && trimmed != "" {
+			num = trimmed
+			break
+		}
+	}
+	mult := 1.0
+	if num != "" {
+		if i := strings.IndexByte("KMGT", num[len(num)-1]); i >= 0 {
+			mult = math.Pow(1024, float64(i+1))
+			num = strings.TrimSpace(num[:len(num)-1])
+		}
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n <= 0 || math.IsInf(n*mult, 0) {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 512M or 2G)", s)
+	}
+	return int64(n * mult), nil
+}
+
+// IONice is a Linux I/O scheduling priority: idle, or best-effort with an
+// optional level from 0 (highest) to 7
+type IONice struct {
+	Class string // "idle" or "best-effort"
+	Level int    // Level within best-effort (default 4, the kernel's)
+}
+
+// UnmarshalYAML parses idle, best-effort or best-effort:N
+func (n *IONice) UnmarshalYAML(node *yaml.Node) error {
+	class, level, hasLevel := strings.Cut(node.Value, ":")
+	switch {
+	case class == "idle" && !hasLevel:
+		*n = IONice{Class: class}
+	case class == "best-effort" && !hasLevel:
+		*n = IONice{Class: class, Level: 4}
+	case class == "best-effort":
+		l, err := strconv.Atoi(level)
+		if err != nil || l < 0 || l > 7 {
+			return fmt.Errorf("line %d: ionice level must be 0-7, got %q", node.Line, level)
+		}
+		*n = IONice{Class: class, Level: l}
+	default:
+		return fmt.Errorf("line %d: invalid ionice %q (expected idle, best-effort or best-effort:0-7)", node.Line, node.Value)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFiles are the names a config checked into a project can have. Its
+// root defaults to the directory it's in.
+var ProjectFiles = []string{"roost.yml", "roost.yaml"}
+
+// Link is a symlink in the config directory to a config kept elsewhere,
+// usually a project's roost.yml
+type Link struct {
+	Name   string // App name the link is registered under
+	Path   string // The symlink in the config directory
+	Target string // Absolute path of the file it points to
+}
+
+// isProjectFile returns true if path is a project's own config
+func isProjectFile(path string) bool {
+	return slices.Contains(ProjectFiles, filepath.Base(path))
+}
+
+// FindProjectFile returns the project config at path: path itself if it's a
+// file, or the roost.yml (or roost.yaml) in it if it's a directory
+func FindProjectFile(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return abs, nil
+	}
+	for _, name := range ProjectFiles {
+		candidate := filepath.Join(abs, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no %s in %s", ProjectFiles[0], abs)
+}
+
+// projectAppName returns the name a project config registers as: its name
+// setting, or else its directory's name
+func projectAppName(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	var cfg struct {
+		Name string `yaml:"name"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return "", fmt.Errorf("parsing %s: %w", file, err)
+	}
+	name := cfg.Name
+	if name == "" {
+		name = filepath.Base(filepath.Dir(file))
+	}
+	name = strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsRune(name, filepath.Separator) {
+		return "", fmt.Errorf("can't use %q as an app name; set name in %s", name, file)
+	}
+	return name, nil
+}
+
+// LinkProject registers the project config at path (a file, or a directory
+// with a roost.yml) by symlinking <name>.yml in the config directory to it.
+// Linking the same file again is a no-op.
+func LinkProject(configDir, path string) (*Link, error) {
+	target, err := FindProjectFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name, err := projectAppName(target)
+	if err != nil {
+		return nil, err
+	}
+	link := &Link{Name: name, Path: filepath.Join(configDir, name+".yml"), Target: target}
+
+	for _, ext := range []string{".yml", ".yaml", ""} {
+		existing := filepath.Join(configDir, name+ext)
+		if _, err := os.Lstat(existing); err != nil {
+			continue
+		}
+		if current, err := linkTarget(configDir, existing); err == nil && current == target {
+			link.Path = existing
+			return link, nil
+		}
+		return nil, fmt.Errorf("an app named %s already exists (%s)", name, existing)
+	}
+
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Symlink(target, link.Path); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// Links returns the symlinks in the config directory that point to YAML files
+func Links(configDir string) ([]Link, error) {
+	entries, err := os.ReadDir(configDir)
+	if err != nil {
+		return nil, err
+	}
+	var links []Link
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		path := filepath.Join(configDir, entry.Name())
+		target, err := linkTarget(configDir, path)
+		if err != nil || !isYAML(target) {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		links = append(links, Link{Name: name, Path: path, Target: target})
+	}
+	return links, nil
+}
+
+// UnlinkProject removes the links registered by LinkProject for arg, which is
+// an app name or a project path, and returns them
+func UnlinkProject(configDir, arg string) ([]Link, error) {
+	links, err := Links(configDir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []Link
+	if target, err := FindProjectFile(arg); err == nil {
+		for _, link := range links {
+			if link.Target == target {
+				matches = append(matches, link)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		for _, link := range links {
+			if link.Name == arg {
+				matches = append(matches, link)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no linked project matches %s", arg)
+	}
+	for _, link := range matches {
+		if err := os.Remove(link.Path); err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+// linkTarget returns the absolute path a symlink in the config directory
+// points to, expanding ~
+func linkTarget(configDir, path string) (string, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(target, "~") {
+		home, _ := os.UserHomeDir()
+		target = filepath.Join(home, target[1:])
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(configDir, target)
+	}
+	return filepath.Clean(target), nil
+}
+
+// isYAML returns true if path names a YAML file
+func isYAML(path string) bool {
+	return strings.HasSuffix(path, ".yml") || strings.HasSuffix(path, ".yaml")
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+// ProfilesFile is where the active profile of each app is kept, in the
+// config directory
+const ProfilesFile = "profiles.json"
+
+// DefaultProfile names the config as written, without a profile applied.
+// Switching to it turns the active profile off.
+const DefaultProfile = "default"
+
+// Profile is a named variant of an app's config
+type Profile struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// yamlProfile is the format of a profile in a YAML app config: overrides
+// applied on top of the rest of the config while the profile is active
+type yamlProfile struct {
+	Description string                        `yaml:"description"`
+	Command     string                        `yaml:"cmd"`      // For a single-command app
+	Exec        []string                      `yaml:"exec"`     // For a single-command app
+	Env         map[string]string             `yaml:"env"`      // Set for the app and every service
+	Services    map[string]yamlProfileService `yaml:"services"` // Per-service overrides
+	Without     []string                      `yaml:"without"`  // Services not run in this profile
+}
+
+// yamlProfileService is what a profile can override in a service
+type yamlProfileService struct {
+	Command string            `yaml:"cmd"`
+	Exec    []string          `yaml:"exec"`
+	Env     map[string]string `yaml:"env"`
+}
+
+// profileNamePattern matches names usable as profile names, which are typed
+// on the command line
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// check reports what in the profile doesn't fit the config it applies to
+func (p yamlProfile) check(name string, cfg *yamlApp) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("profile %q: invalid name (use letters, digits, -, _ and .)", name)
+	}
+	if name == DefaultProfile {
+		return fmt.Errorf("profile %q: %s is the config without a profile; pick another name", name, DefaultProfile)
+	}
+	if cfg.Static {
+		return fmt.Errorf("profile %s: static sites have nothing to override", name)
+	}
+	if p.Command != "" && len(p.Exec) > 0 {
+		return fmt.Errorf("profile %s: cmd and exec can't both be set", name)
+	}
+	if (p.Command != "" || len(p.Exec) > 0) && len(cfg.Services) > 1 {
+		return fmt.Errorf("profile %s: cmd and exec apply to a single-command app; set them per service under services", name)
+	}
+
+	names := slices.Sorted(maps.Keys(cfg.Services))
+	for svcName, svc := range p.Services {
+		if _, ok := cfg.Services[svcName]; !ok {
+			return fmt.Errorf("profile %s: services: unknown service %q%s", name, svcName, suggestion(svcName, names))
+		}
+		if svc.Command != "" && len(svc.Exec) > 0 {
+			return fmt.Errorf("profile %s: service %s: cmd and exec can't both be set", name, svcName)
+		}
+	}
+	for _, svcName := range p.Without {
+		if _, ok := cfg.Services[svcName]; !ok {
+			return fmt.Errorf("profile %s: without: unknown service %q%s", name, svcName, suggestion(svcName, names))
+		}
+	}
+	if len(cfg.Services) > 0 && len(p.Without) >= len(cfg.Services) {
+		return fmt.Errorf("profile %s: without leaves no services to run", name)
+	}
+	return nil
+}
+
+// apply overrides the config with the profile, which check has accepted
+func (p yamlProfile) apply(cfg *yamlApp) {
+	if len(cfg.Services) == 0 && (p.Command != "" || len(p.Exec) > 0) {
+		cfg.Command, cfg.Exec = p.Command, p.Exec
+	}
+	cfg.Env = overrideEnv(cfg.Env, p.Env)
+
+	single := len(cfg.Services) == 1
+	for _, name := range p.Without {
+		delete(cfg.Services, name)
+	}
+	for name, svc := range cfg.Services {
+		override := p.Services[name]
+		if single && override.Command == "" && len(override.Exec) == 0 {
+			// A lone service is the app's command
+			override.Command, override.Exec = p.Command, p.Exec
+		}
+		if override.Command != "" || len(override.Exec) > 0 {
+			svc.Command, svc.Exec = override.Command, override.Exec
+		}
+		svc.Env = overrideEnv(overrideEnv(svc.Env, p.Env), override.Env)
+		// Services left out can't be waited for or restarted along with
+		svc.DependsOn = slices.DeleteFunc(slices.Clone(svc.DependsOn), func(dep string) bool {
+			return slices.Contains(p.Without, dep)
+		})
+		svc.RestartWith = slices.DeleteFunc(slices.Clone(svc.RestartWith), func(other string) bool {
+			return slices.Contains(p.Without, other)
+		})
+		cfg.Services[name] = svc
+	}
+}
+
+// overrideEnv returns env with the variables in override set on top
+func overrideEnv(env, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return env
+	}
+	merged := maps.Clone(env)
+	if merged == nil {
+		merged = make(map[string]string, len(override))
+	}
+	maps.Copy(merged, override)
+	return merged
+}
+
+// applyProfile checks every profile in the config, so a broken one shows up
+// before anyone switches to it, then applies the active one. It returns the
+// profiles and the name of the one applied, empty if none is.
+func applyProfile(cfg *yamlApp, active string) ([]Profile, string, error) {
+	var profiles []Profile
+	for _, name := range slices.Sorted(maps.Keys(cfg.Profiles)) {
+		p := cfg.Profiles[name]
+		if err := p.check(name, cfg); err != nil {
+			return nil, "", err
+		}
+		profiles = append(profiles, Profile{Name: name, Description: p.Description})
+	}
+	p, ok := cfg.Profiles[active]
+	if !ok {
+		// Not set, or removed from the config since it was picked
+		return profiles, "", nil
+	}
+	p.apply(cfg)
+	return profiles, active, nil
+}
+
+// LoadActiveProfiles reads the active profile of each app from a profiles
+// file
+func LoadActiveProfiles(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[string]string)
+	if err := json.Unmarshal(data, &active); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return active, nil
+}
+
+// SetProfile makes profile the active profile of an app, or turns profiles
+// off for it with DefaultProfile, and reloads the configs. The choice is
+// kept in the config directory, so it survives restarts.
+func (s *AppStore) SetProfile(appName, profile string) error {
+	app, ok := s.Get(appName)
+	if !ok {
+		return fmt.Errorf("unknown app %q", appName)
+	}
+	if profile != DefaultProfile && !slices.ContainsFunc(app.Profiles, func(p Profile) bool { return p.Name == profile }) {
+		if len(app.Profiles) == 0 {
+			return fmt.Errorf("%s has no profiles", appName)
+		}
+		names := make([]string, len(app.Profiles))
+		for i, p := range app.Profiles {
+			names[i] = p.Name
+		}
+		return fmt.Errorf("%s has no profile %q%s", appName, profile, suggestion(profile, names))
+	}
+
+	path := filepath.Join(s.cfg.Dir, ProfilesFile)
+	active, err := LoadActiveProfiles(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if active == nil {
+		active = make(map[string]string)
+	}
+	previous, hadPrevious := active[appName]
+	if profile == DefaultProfile {
+		delete(active, appName)
+	} else {
+		active[appName] = profile
+	}
+	if err := saveActiveProfiles(path, active); err != nil {
+		return err
+	}
+	if err := s.Reload(); err != nil {
+		return err
+	}
+	if _, ok := s.Get(appName); ok {
+		return nil
+	}
+
+	// The profile checks out on its own but the app doesn't load with it
+	// (like one leaving only workers), so go back to the previous one
+	delete(active, appName)
+	if hadPrevious {
+		active[appName] = previous
+	}
+	if err := saveActiveProfiles(path, active); err != nil {
+		return err
+	}
+	s.Reload()
+	return fmt.Errorf("%s doesn't load with profile %s; run roost-dev config validate for details", appName, profile)
+}
+
+// saveActiveProfiles writes the active profile of each app to a profiles file
+func saveActiveProfiles(path string, active map[string]string) error {
+	data, err := json.MarshalIndent(active, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
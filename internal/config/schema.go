@@ -0,0 +1,206 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// schemaType is implemented by config types whose YAML form isn't simply
+// their fields, like the shorthands their UnmarshalYAML accepts. object is
+// the schema of the fields, nil for types that aren't structs.
+type schemaType interface {
+	jsonSchema(object map[string]any) map[string]any
+}
+
+// Schema returns the JSON Schema of the YAML app config format, generated
+// from the types the loader decodes into
+func Schema() []byte {
+	schema := typeSchema(reflect.TypeOf(yamlApp{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "roost-dev app config"
+	data, _ := json.MarshalIndent(schema, "", "  ")
+	return append(data, '\n')
+}
+
+// typeSchema returns the schema for values that decode into t
+func typeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return map[string]any{
+			"type":        "string",
+			"pattern":     `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`,
+			"description": "A duration such as 500ms, 30s or 1h30m",
+		}
+	}
+
+	var object map[string]any
+	if t.Kind() == reflect.Struct {
+		properties := make(map[string]any)
+		for key, ft := range yamlFields(t) {
+			properties[key] = typeSchema(ft)
+		}
+		object = map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+		// Inlined structs describe their own fields
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if _, opts, _ := strings.Cut(f.Tag.Get("yaml"), ","); opts != "inline" {
+				continue
+			}
+			if st, ok := reflect.New(f.Type).Interface().(schemaType); ok {
+				object = st.jsonSchema(object)
+			}
+		}
+	}
+	if st, ok := reflect.New(t).Interface().(schemaType); ok {
+		return st.jsonSchema(object)
+	}
+	if object != nil {
+		return object
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// property returns the schema of a field in an object schema
+func property(object map[string]any, key string) map[string]any {
+	return object["properties"].(map[string]any)[key].(map[string]any)
+}
+
+// oneOf returns a schema matching any of schemas, described by description
+func oneOf(description string, schemas ...map[string]any) map[string]any {
+	list := make([]any, len(schemas))
+	for i, s := range schemas {
+		list[i] = s
+	}
+	return map[string]any{"description": description, "anyOf": list}
+}
+
+func (*yamlApp) jsonSchema(object map[string]any) map[string]any {
+	services := property(object, "services")
+	services["propertyNames"] = map[string]any{"pattern": "^[^ ]+$"}
+	services["description"] = "Services run by the app, each at <service>-<app>.<tld>"
+	property(object, "root")["description"] = "Directory commands run in (~ is expanded). In a project's roost.yml it defaults to the project directory."
+	property(object, "static")["description"] = "Serve the files in root instead of running a command"
+	property(object, "cmd")["description"] = "Command to run, with $PORT set to the assigned port. Can use references like ${root} and ${service.api.url}."
+	property(object, "exec")["description"] = "Command to run without a shell, as a list of arguments"
+	property(object, "env")["description"] = "Environment variables for the process"
+	property(object, "idle_timeout")["description"] = "Stop the app after no requests for this long"
+	profiles := property(object, "profiles")
+	profiles["propertyNames"] = map[string]any{"pattern": profileNamePattern.String(), "not": map[string]any{"const": DefaultProfile}}
+	profiles["description"] = "Variants of the app to switch between with roost-dev profile, by name"
+	return object
+}
+
+func (*yamlProfile) jsonSchema(object map[string]any) map[string]any {
+	property(object, "cmd")["description"] = "Command to run instead, for a single-command app"
+	property(object, "exec")["description"] = "Command to run instead without a shell, for a single-command app"
+	property(object, "env")["description"] = "Environment variables set for the app and every service, over their own"
+	property(object, "services")["description"] = "cmd, exec and env overrides for services, by name"
+	property(object, "without")["description"] = "Services that don't run in this profile"
+	return object
+}
+
+func (*yamlService) jsonSchema(object map[string]any) map[string]any {
+	property(object, "type")["enum"] = []any{"web", "worker"}
+	property(object, "port")["description"] = "false runs the service without a port, like type: worker"
+	property(object, "depends_on")["description"] = "Services started, and ready, before this one"
+	property(object, "restart_with")["description"] = "Services whose restart also restarts this one"
+	return object
+}
+
+func (*ProcessConfig) jsonSchema(object map[string]any) map[string]any {
+	property(object, "health")["description"] = "Alias for ready"
+	property(object, "shell")["description"] = "login (default for cmd), plain, none (default for exec) or the path of a shell"
+	property(object, "shell")["examples"] = []any{"login", "plain", "none"}
+	property(object, "pidfile")["description"] = "Pidfile the command writes, relative to its dir, cleaned up before start"
+	return object
+}
+
+func (*ReadyConfig) jsonSchema(object map[string]any) map[string]any {
+	types := []any{"tcp", "http", "exec", "file", "log", "none"}
+	property(object, "type")["enum"] = types
+	return oneOf("When a started process counts as ready",
+		map[string]any{"type": "string", "enum": types}, object)
+}
+
+func (*RestartConfig) jsonSchema(object map[string]any) map[string]any {
+	policies := []any{"never", "on-failure", "always"}
+	property(object, "policy")["enum"] = policies
+	return oneOf("Automatic restarts of crashed processes",
+		map[string]any{"type": "string", "enum": policies}, object)
+}
+
+func (*WatchConfig) jsonSchema(object map[string]any) map[string]any {
+	return oneOf("Restart the process when files under its directory change",
+		map[string]any{"type": "boolean"},
+		map[string]any{"type": "string"},
+		map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		object)
+}
+
+func (*InheritEnv) jsonSchema(map[string]any) map[string]any {
+	return oneOf("Which of roost-dev's environment variables the process inherits: true (all), false (only essentials like HOME and PATH) or extra variables to keep, where NAME_* matches a prefix",
+		map[string]any{"type": "boolean"},
+		map[string]any{"type": "array", "items": map[string]any{"type": "string"}})
+}
+
+func (*envFileList) jsonSchema(map[string]any) map[string]any {
+	return oneOf("Dotenv files to read, relative to the directory",
+		map[string]any{"type": "string"},
+		map[string]any{"type": "array", "items": map[string]any{"type": "string"}})
+}
+
+func (*Signal) jsonSchema(map[string]any) map[string]any {
+	var names []string
+	for name := range signals {
+		names = append(names, name, "SIG"+name, strings.ToLower(name), "sig"+strings.ToLower(name))
+	}
+	sort.Strings(names)
+	return map[string]any{
+		"type":        "string",
+		"enum":        names,
+		"description": "Signal sent to the process group on stop",
+	}
+}
+
+func (*LimitsConfig) jsonSchema(object map[string]any) map[string]any {
+	property(object, "open_files")["minimum"] = 0
+	property(object, "nice")["minimum"] = -20
+	property(object, "nice")["maximum"] = 19
+	object["description"] = "Memory, open files and CPU/IO priority caps"
+	return object
+}
+
+func (*ByteSize) jsonSchema(map[string]any) map[string]any {
+	return map[string]any{
+		"type":        []any{"string", "integer"},
+		"pattern":     `^[0-9]+(\.[0-9]+)? *([KMGTkmgt]([Ii]?[Bb])?|[Bb])?$`,
+		"description": "A size in bytes, or with a binary unit: 512M, 1.5G, 2GiB",
+	}
+}
+
+func (*IONice) jsonSchema(map[string]any) map[string]any {
+	return map[string]any{
+		"type":    "string",
+		"pattern": "^(idle|best-effort(:[0-7])?)$",
+	}
+}
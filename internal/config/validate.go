@@ -0,0 +1,466 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is how serious a Diagnostic is
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // The config doesn't load, or part of it won't work
+	SeverityWarning Severity = "warning" // It loads, but likely not as intended
+)
+
+// ReservedNames are hostnames roost-dev serves itself
+var ReservedNames = map[string]string{
+	"roost-dev":  "the dashboard",
+	"roost-test": "the welcome page",
+}
+
+// Diagnostic is a problem found in a config file
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"` // 0 if it isn't about one place in the file
+	Column   int      `json:"column,omitempty"`
+	Severity Severity `json:"severity"`
+	App      string   `json:"app,omitempty"` // The app the file loaded as, or else the file's name
+	Message  string   `json:"message"`
+}
+
+// String formats the diagnostic as file:line:column: severity: message
+func (d Diagnostic) String() string {
+	pos := d.File
+	if d.Line > 0 {
+		pos += ":" + strconv.Itoa(d.Line)
+		if d.Column > 0 {
+			pos += ":" + strconv.Itoa(d.Column)
+		}
+	}
+	return fmt.Sprintf("%s: %s: %s", pos, d.Severity, d.Message)
+}
+
+// Validate loads the configs in cfg.Dir and returns the problems found
+func Validate(cfg *Config) ([]Diagnostic, error) {
+	store := NewAppStore(cfg)
+	if err := store.Load(); err != nil {
+		return nil, err
+	}
+	return store.Diagnostics(), nil
+}
+
+// HasErrors returns true if any of diags is an error
+func HasErrors(diags []Diagnostic) bool {
+	return slices.ContainsFunc(diags, func(d Diagnostic) bool { return d.Severity == SeverityError })
+}
+
+// configFile is a file Load read, kept for checks across apps
+type configFile struct {
+	path string
+	root *yaml.Node // Top-level mapping of a YAML file, nil otherwise
+	app  *App       // nil if it failed to load
+}
+
+// diagnose returns a diagnostic for path at node, or for the whole file if
+// node is nil
+func diagnose(path string, node *yaml.Node, severity Severity, format string, args ...any) Diagnostic {
+	d := Diagnostic{File: path, Severity: severity, Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		d.Line, d.Column = node.Line, node.Column
+	}
+	return d
+}
+
+// parseYAMLFile returns the top-level mapping of a YAML file, or nil if it
+// can't be read or parsed (loading it reports why)
+func parseYAMLFile(path string) *yaml.Node {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	if root := doc.Content[0]; root.Kind == yaml.MappingNode {
+		return root
+	}
+	return nil
+}
+
+// mappingEntry returns the key and value nodes for key in a mapping node
+func mappingEntry(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// checkYAML checks a parsed YAML config for what loading it doesn't catch:
+// unknown keys, missing or nonexistent directories and dependencies on
+// services that don't exist
+func checkYAML(path string, root *yaml.Node) []Diagnostic {
+	var diags []Diagnostic
+	checkKeys(path, root, reflect.TypeOf(yamlApp{}), &diags)
+
+	var cfg yamlApp
+	if err := root.Decode(&cfg); err != nil {
+		return diags // Loading reports it
+	}
+	_, services := mappingEntry(root, "services")
+	_, rootValue := mappingEntry(root, "root")
+	dir := resolveRoot(cfg.Root, path)
+	runs := cfg.Command != "" || len(cfg.Exec) > 0 || len(cfg.Services) > 0
+
+	switch {
+	case cfg.Static:
+		// Loading checks the root of a static site
+	case dir == "" && runs:
+		diags = append(diags, diagnose(path, nil, SeverityWarning,
+			"no root set, so commands run in roost-dev's own directory"))
+	case dir != "" && !isDir(dir):
+		diags = append(diags, diagnose(path, rootValue, SeverityError, "root %s doesn't exist", dir))
+		dir = "" // Don't report every service dir under it too
+	}
+
+	names := make([]string, 0, len(cfg.Services))
+	for name := range cfg.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		svc := cfg.Services[name]
+		key, value := mappingEntry(services, name)
+		if strings.Contains(name, " ") {
+			diags = append(diags, diagnose(path, key, SeverityError,
+				"service %q is skipped: service names can't contain spaces", name))
+		}
+		if svc.Dir != "" && dir != "" && !strings.HasPrefix(svc.Dir, "${") {
+			if svcDir := filepath.Join(dir, svc.Dir); !isDir(svcDir) {
+				_, dirNode := mappingEntry(value, "dir")
+				diags = append(diags, diagnose(path, dirNode, SeverityError, "service %s: dir %s doesn't exist", name, svcDir))
+			}
+		}
+		_, deps := mappingEntry(value, "depends_on")
+		for i, dep := range svc.DependsOn {
+			if _, ok := cfg.Services[dep]; ok {
+				continue
+			}
+			node := deps
+			if deps != nil && deps.Kind == yaml.SequenceNode && i < len(deps.Content) {
+				node = deps.Content[i]
+			}
+			diags = append(diags, diagnose(path, node, SeverityError,
+				"service %s: depends_on: unknown service %q%s", name, dep, suggestion(dep, names)))
+		}
+	}
+
+	// Check references with stand-in values; only the errors matter
+	vars := Vars{Port: 1, Services: make(map[string]ServiceVars, len(cfg.Services))}
+	for name, svc := range cfg.Services {
+		if worker, _ := isWorker(svc.Type, svc.Port); !worker {
+			vars.Services[name] = ServiceVars{URL: "http://" + name, Port: 1}
+		} else {
+			vars.Services[name] = ServiceVars{}
+		}
+	}
+	checkReferences(path, root, vars, &diags)
+	_, profiles := mappingEntry(root, "profiles")
+	for _, name := range slices.Sorted(maps.Keys(cfg.Profiles)) {
+		_, profile := mappingEntry(profiles, name)
+		checkReferences(path, profile, vars, &diags)
+	}
+	for _, name := range names {
+		svcVars := vars
+		if worker, _ := isWorker(cfg.Services[name].Type, cfg.Services[name].Port); worker {
+			svcVars.Port = 0
+		}
+		_, value := mappingEntry(services, name)
+		checkReferences(path, value, svcVars, &diags)
+		for _, profileName := range slices.Sorted(maps.Keys(cfg.Profiles)) {
+			_, profile := mappingEntry(profiles, profileName)
+			_, overrides := mappingEntry(profile, "services")
+			_, override := mappingEntry(overrides, name)
+			checkReferences(path, override, svcVars, &diags)
+		}
+	}
+	return diags
+}
+
+// interpolatedKeys are the settings references are filled in for
+var interpolatedKeys = []string{"cmd", "exec", "env", "dir", "stop_cmd", "before_start", "after_ready", "after_stop"}
+
+// shellReference matches a ${name} that isn't escaped as $${name}
+var shellReference = regexp.MustCompile(`(?:^|[^$])\$\{([a-z][a-z0-9_]*)\}`)
+
+// checkReferences reports references in an app or service mapping that
+// can't be filled in, and lowercase ${names} that look like misspelled ones
+func checkReferences(path string, node *yaml.Node, vars Vars, diags *[]Diagnostic) {
+	for _, key := range interpolatedKeys {
+		_, value := mappingEntry(node, key)
+		if value == nil {
+			continue
+		}
+		scalars := []*yaml.Node{value}
+		switch value.Kind {
+		case yaml.SequenceNode:
+			scalars = value.Content
+		case yaml.MappingNode:
+			scalars = nil
+			for i := 1; i < len(value.Content); i += 2 {
+				scalars = append(scalars, value.Content[i])
+			}
+		}
+		for _, n := range scalars {
+			if n.Kind != yaml.ScalarNode {
+				continue
+			}
+			if _, err := vars.Interpolate(n.Value); err != nil {
+				*diags = append(*diags, diagnose(path, n, SeverityError, "%s: %v", key, err))
+				continue
+			}
+			for _, m := range shellReference.FindAllStringSubmatch(n.Value, -1) {
+				if slices.Contains(referenceNames, m[1]) {
+					continue
+				}
+				if hint := suggestion(m[1], referenceNames); hint != "" {
+					*diags = append(*diags, diagnose(path, n, SeverityWarning,
+						"%s: ${%s} isn't a reference, so it's left for the shell%s", key, m[1], hint))
+				}
+			}
+		}
+	}
+}
+
+// checkKeys reports keys in node that the type it decodes into (a struct, or
+// a map of structs) doesn't have
+func checkKeys(path string, node *yaml.Node, t reflect.Type, diags *[]Diagnostic) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if node.Kind != yaml.MappingNode {
+		return // Scalar and list shorthands
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		elem := t.Elem()
+		for i := 1; i < len(node.Content); i += 2 {
+			checkKeys(path, node.Content[i], elem, diags)
+		}
+	case reflect.Struct:
+		fields := yamlFields(t)
+		known := make([]string, 0, len(fields))
+		for key := range fields {
+			known = append(known, key)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				*diags = append(*diags, diagnose(path, key, SeverityWarning,
+					"unknown key %q is ignored%s", key.Value, suggestion(key.Value, known)))
+				continue
+			}
+			checkKeys(path, value, field, diags)
+		}
+	}
+}
+
+// yamlFields returns the YAML keys a struct decodes, with their types,
+// including those of inlined structs
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		switch {
+		case name == "-" || !f.IsExported():
+		case opts == "inline":
+			for key, ft := range yamlFields(f.Type) {
+				fields[key] = ft
+			}
+		case name == "":
+			fields[strings.ToLower(f.Name)] = f.Type
+		default:
+			fields[name] = f.Type
+		}
+	}
+	return fields
+}
+
+// suggestion returns ` (did you mean "x"?)` for the closest of candidates to
+// s, or "" if none is close
+func suggestion(s string, candidates []string) string {
+	best, bestDist := "", max(1, len(s)/4)+1
+	for _, c := range candidates {
+		if d := editDistance(s, c); d < bestDist || (d == bestDist && c < best) {
+			best, bestDist = c, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(curr[j-1]+1, prev[j]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+var (
+	errLine    = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	errService = regexp.MustCompile(`^service ([^:]+): `)
+	errCycle   = regexp.MustCompile(`^dependency cycle: ([^ ]+) `)
+	errProfile = regexp.MustCompile(`^profile "?([^":]+)"?: `)
+)
+
+// loadDiagnostics turns the error from loading a file into diagnostics,
+// placed at the line it names or the part of the file it's about
+func loadDiagnostics(path string, root *yaml.Node, err error) []Diagnostic {
+	msg := strings.TrimPrefix(err.Error(), "parsing YAML: ")
+	msg = strings.TrimPrefix(msg, "yaml: unmarshal errors:\n")
+
+	var diags []Diagnostic
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		d := diagnose(path, nil, SeverityError, "%s", line)
+		if m := errLine.FindStringSubmatch(line); m != nil {
+			d.Line, _ = strconv.Atoi(m[1])
+			d.Message = m[2]
+		} else if m := errService.FindStringSubmatch(line); m != nil {
+			_, services := mappingEntry(root, "services")
+			if key, _ := mappingEntry(services, m[1]); key != nil {
+				d.Line, d.Column = key.Line, key.Column
+			}
+		} else if m := errProfile.FindStringSubmatch(line); m != nil {
+			_, profiles := mappingEntry(root, "profiles")
+			if key, _ := mappingEntry(profiles, m[1]); key != nil {
+				d.Line, d.Column = key.Line, key.Column
+			}
+		} else if m := errCycle.FindStringSubmatch(line); m != nil {
+			_, services := mappingEntry(root, "services")
+			_, svc := mappingEntry(services, m[1])
+			if key, _ := mappingEntry(svc, "depends_on"); key != nil {
+				d.Line, d.Column = key.Line, key.Column
+			}
+		} else if strings.HasPrefix(line, "static") {
+			if key, _ := mappingEntry(root, "root"); key != nil {
+				d.Line, d.Column = key.Line, key.Column
+			} else if key, _ := mappingEntry(root, "static"); key != nil {
+				d.Line, d.Column = key.Line, key.Column
+			}
+		}
+		d.Message = strings.TrimPrefix(d.Message, "yaml: ")
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// hostname is a name an app answers to, and where it comes from
+type hostname struct {
+	name string
+	what string // "app shop", "alias store of shop", "service web of shop"
+	file *configFile
+	node *yaml.Node
+}
+
+// checkHostnames reports hostnames that more than one app, alias or service
+// claims, and ones roost-dev reserves
+func checkHostnames(files []*configFile) []Diagnostic {
+	var hosts []hostname
+	for _, f := range files {
+		app := f.app
+		if app == nil {
+			continue
+		}
+		_, nameNode := mappingEntry(f.root, "name")
+		hosts = append(hosts, hostname{app.Name, "app " + app.Name, f, nameNode})
+
+		_, aliases := mappingEntry(f.root, "aliases")
+		_, alias := mappingEntry(f.root, "alias")
+		for _, a := range app.Aliases {
+			var node *yaml.Node
+			if alias != nil && alias.Value == a {
+				node = alias
+			} else if aliases != nil {
+				for _, n := range aliases.Content {
+					if n.Value == a {
+						node = n
+					}
+				}
+			}
+			hosts = append(hosts, hostname{a, fmt.Sprintf("alias %s of %s", a, app.Name), f, node})
+		}
+
+		_, services := mappingEntry(f.root, "services")
+		for _, svc := range app.Services {
+			if svc.Worker {
+				continue
+			}
+			key, _ := mappingEntry(services, svc.Name)
+			name := strings.ToLower(strings.ReplaceAll(svc.Name, " ", "-")) + "-" + app.Name
+			hosts = append(hosts, hostname{name, fmt.Sprintf("service %s of %s", svc.Name, app.Name), f, key})
+		}
+	}
+
+	var diags []Diagnostic
+	claimed := make(map[string]hostname)
+	for _, h := range hosts {
+		if what, ok := ReservedNames[h.name]; ok {
+			d := diagnose(h.file.path, h.node, SeverityError, "%s can't be reached: %q is reserved for %s", h.what, h.name, what)
+			d.App = h.file.app.Name
+			diags = append(diags, d)
+			continue
+		}
+		if first, ok := claimed[h.name]; ok {
+			d := diagnose(h.file.path, h.node, SeverityError, "hostname %q of %s is also used by %s (%s)", h.name, h.what, first.what, first.file.path)
+			d.App = h.file.app.Name
+			diags = append(diags, d)
+			continue
+		}
+		claimed[h.name] = h
+	}
+	return diags
+}
+
+// isDir returns true if path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
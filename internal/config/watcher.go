@@ -7,22 +7,30 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/panozzaj/roost-dev/internal/debounce"
 )
 
+// watchDebounce is how long config changes must settle before they're
+// reported, so an editor's save (often several events) reloads once
+const watchDebounce = 200 * time.Millisecond
+
 // Watcher watches the config directory for changes
 type Watcher struct {
-	watcher  *fsnotify.Watcher
-	dir      string
-	onChange func(changedFiles []string)
-	done     chan struct{}
-
-	// Track changed files during debounce window
-	pendingMu    sync.Mutex
-	pendingFiles map[string]bool
+	watcher *fsnotify.Watcher
+	dir     string
+	done    chan struct{}
+	changes *debounce.Files // Batches changes until they settle
+
+	// Files outside the config directory (e.g., env files) and the
+	// directories watched for them
+	filesMu sync.Mutex
+	files   map[string]bool
+	dirs    map[string]bool
 }
 
 // NewWatcher creates a new config directory watcher
-// The onChange callback receives a list of changed filenames (base names, not full paths)
+// The onChange callback receives a list of changed filenames: base names for
+// files in the config directory, full paths for files added with WatchFiles
 func NewWatcher(dir string, onChange func(changedFiles []string)) (*Watcher, error) {
 	w, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -35,14 +43,68 @@ func NewWatcher(dir string, onChange func(changedFiles []string)) (*Watcher, err
 	}
 
 	return &Watcher{
-		watcher:      w,
-		dir:          dir,
-		onChange:     onChange,
-		done:         make(chan struct{}),
-		pendingFiles: make(map[string]bool),
+		watcher: w,
+		dir:     dir,
+		done:    make(chan struct{}),
+		changes: debounce.NewFiles(watchDebounce, func(files []string) {
+			log.Printf("Config changed: %v", files)
+			onChange(files)
+		}),
+		files: make(map[string]bool),
+		dirs:  make(map[string]bool),
 	}, nil
 }
 
+// WatchFiles sets the files outside the config directory to watch, replacing
+// the previous set. Their directories are watched so files that don't exist
+// yet, or that editors replace on save, are noticed too.
+func (w *Watcher) WatchFiles(paths []string) {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+
+	files := make(map[string]bool, len(paths))
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		files[filepath.Clean(path)] = true
+		if dir := filepath.Dir(path); dir != filepath.Clean(w.dir) {
+			dirs[dir] = true
+		}
+	}
+	for dir := range dirs {
+		if w.dirs[dir] {
+			continue
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			log.Printf("Config watcher: can't watch %s: %v", dir, err)
+			delete(dirs, dir)
+		}
+	}
+	for dir := range w.dirs {
+		if !dirs[dir] {
+			w.watcher.Remove(dir)
+		}
+	}
+	w.files, w.dirs = files, dirs
+}
+
+// changedName returns the name to report for a changed path, or "" if the
+// change doesn't matter
+func (w *Watcher) changedName(path string) string {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+	if w.files[path] {
+		return path
+	}
+	if filepath.Dir(path) != filepath.Clean(w.dir) {
+		return ""
+	}
+	// Ignore files roost-dev writes itself
+	if StateEntries[filepath.Base(path)] {
+		return ""
+	}
+	return filepath.Base(path)
+}
+
 // Start begins watching for changes
 func (w *Watcher) Start() {
 	go w.run()
@@ -55,16 +117,10 @@ func (w *Watcher) Stop() {
 }
 
 func (w *Watcher) run() {
-	// Debounce timer - wait for rapid changes to settle
-	var debounceTimer *time.Timer
-	debounceDelay := 200 * time.Millisecond
-
 	for {
 		select {
 		case <-w.done:
-			if debounceTimer != nil {
-				debounceTimer.Stop()
-			}
+			w.changes.Stop()
 			return
 
 		case event, ok := <-w.watcher.Events:
@@ -72,30 +128,14 @@ func (w *Watcher) run() {
 				return
 			}
 
+			name := w.changedName(filepath.Clean(event.Name))
+			if name == "" {
+				continue
+			}
+
 			// Only react to relevant events
 			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
-				// Track this changed file
-				w.pendingMu.Lock()
-				w.pendingFiles[filepath.Base(event.Name)] = true
-				w.pendingMu.Unlock()
-
-				// Debounce: reset timer on each event
-				if debounceTimer != nil {
-					debounceTimer.Stop()
-				}
-				debounceTimer = time.AfterFunc(debounceDelay, func() {
-					// Collect and clear pending files
-					w.pendingMu.Lock()
-					files := make([]string, 0, len(w.pendingFiles))
-					for f := range w.pendingFiles {
-						files = append(files, f)
-					}
-					w.pendingFiles = make(map[string]bool)
-					w.pendingMu.Unlock()
-
-					log.Printf("Config changed: %v", files)
-					w.onChange(files)
-				})
+				w.changes.Add(name)
 			}
 
 		case err, ok := <-w.watcher.Errors:
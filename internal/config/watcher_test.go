@@ -183,6 +183,44 @@ func TestWatcher(t *testing.T) {
 		}
 	})
 
+	t.Run("reports watched files outside the config dir by path", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		repoDir := t.TempDir()
+		envFile := filepath.Join(repoDir, ".env")
+
+		var got atomic.Value
+		w, err := NewWatcher(tmpDir, func(changedFiles []string) {
+			got.Store(changedFiles)
+		})
+		if err != nil {
+			t.Fatalf("failed to create watcher: %v", err)
+		}
+		w.WatchFiles([]string{envFile})
+		w.Start()
+		defer w.Stop()
+
+		// Give the watcher time to start
+		time.Sleep(50 * time.Millisecond)
+
+		// Other files in the same directory don't count
+		os.WriteFile(filepath.Join(repoDir, "README.md"), []byte("readme"), 0644)
+		time.Sleep(400 * time.Millisecond)
+		if got.Load() != nil {
+			t.Fatalf("expected unrelated file to be ignored, got %v", got.Load())
+		}
+
+		// Creating the file counts, since it may not exist when first watched
+		if err := os.WriteFile(envFile, []byte("FOO=bar\n"), 0644); err != nil {
+			t.Fatalf("failed to create env file: %v", err)
+		}
+		time.Sleep(400 * time.Millisecond)
+
+		files, _ := got.Load().([]string)
+		if len(files) != 1 || files[0] != envFile {
+			t.Errorf("expected [%s], got %v", envFile, files)
+		}
+	})
+
 	t.Run("handles non-existent directory", func(t *testing.T) {
 		_, err := NewWatcher("/nonexistent/path/12345", func(changedFiles []string) {})
 		if err == nil {
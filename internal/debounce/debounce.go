@@ -0,0 +1,62 @@
+// Package debounce batches file changes that come in bursts, like an editor
+// saving several files or a build rewriting a directory.
+package debounce
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Files collects changed files and reports them together once no change has
+// come in for a while
+type Files struct {
+	delay    time.Duration
+	onChange func(files []string)
+
+	mu      sync.Mutex
+	pending []string
+	timer   *time.Timer
+}
+
+// NewFiles returns a Files that calls onChange with the files changed once
+// delay has passed without another change
+func NewFiles(delay time.Duration, onChange func(files []string)) *Files {
+	return &Files{delay: delay, onChange: onChange}
+}
+
+// Add records a change to name and restarts the wait. Each file is reported
+// once, in the order it first changed.
+func (f *Files) Add(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !slices.Contains(f.pending, name) {
+		f.pending = append(f.pending, name)
+	}
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	f.timer = time.AfterFunc(f.delay, f.flush)
+}
+
+// Stop drops the changes not reported yet
+func (f *Files) Stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	f.pending = nil
+}
+
+// flush reports the pending changes, if any
+func (f *Files) flush() {
+	f.mu.Lock()
+	files := f.pending
+	f.pending = nil
+	f.mu.Unlock()
+
+	if len(files) > 0 {
+		f.onChange(files)
+	}
+}
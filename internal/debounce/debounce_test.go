@@ -0,0 +1,62 @@
+package debounce
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFiles(t *testing.T) {
+	t.Run("reports a burst of changes once", func(t *testing.T) {
+		got := make(chan []string, 2)
+		f := NewFiles(50*time.Millisecond, func(files []string) { got <- files })
+		f.Add("b.go")
+		f.Add("a.go")
+		f.Add("b.go")
+
+		select {
+		case files := <-got:
+			if want := []string{"b.go", "a.go"}; !slices.Equal(files, want) {
+				t.Errorf("expected %v, got %v", want, files)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the changes to be reported")
+		}
+		select {
+		case files := <-got:
+			t.Errorf("expected a single report, got another: %v", files)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("waits for changes to settle", func(t *testing.T) {
+		got := make(chan []string, 1)
+		f := NewFiles(100*time.Millisecond, func(files []string) { got <- files })
+		f.Add("a.go")
+		time.Sleep(60 * time.Millisecond)
+		f.Add("b.go")
+		time.Sleep(60 * time.Millisecond)
+
+		select {
+		case files := <-got:
+			t.Fatalf("expected no report while changes keep coming, got %v", files)
+		default:
+		}
+		if files := <-got; !slices.Equal(files, []string{"a.go", "b.go"}) {
+			t.Errorf("expected both changes, got %v", files)
+		}
+	})
+
+	t.Run("stop drops pending changes", func(t *testing.T) {
+		got := make(chan []string, 1)
+		f := NewFiles(20*time.Millisecond, func(files []string) { got <- files })
+		f.Add("a.go")
+		f.Stop()
+
+		select {
+		case files := <-got:
+			t.Errorf("expected nothing reported after Stop, got %v", files)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package process
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// MarkerEnv is set to the process name in every process roost-dev starts, so
+// processes left behind by an earlier run can be told apart from others that
+// reused their pids
+const MarkerEnv = "ROOST_DEV_PROCESS"
+
+// essentialEnv are inherited even with Options.CleanEnv, since most tools
+// misbehave without them
+var essentialEnv = []string{"HOME", "USER", "LOGNAME", "SHELL", "PATH", "TMPDIR", "LANG"}
+
+// inheritedEnv returns the part of roost-dev's (or the login) environment a
+// process inherits: all of it, or with CleanEnv only essentialEnv and EnvAllow
+func (o Options) inheritedEnv() []string {
+	base := o.baseEnv()
+	if !o.CleanEnv {
+		return base
+	}
+	allow := slices.Concat(essentialEnv, o.EnvAllow)
+	var env []string
+	for _, kv := range base {
+		name, _, _ := strings.Cut(kv, "=")
+		if envAllowed(name, allow) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// envAllowed returns true if name is in allow, where an entry ending in *
+// matches names with that prefix (LC_*)
+func envAllowed(name string, allow []string) bool {
+	for _, a := range allow {
+		if prefix, ok := strings.CutSuffix(a, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == a {
+			return true
+		}
+	}
+	return false
+}
+
+// buildEnv returns a process's environment, later entries overriding earlier
+// ones: inherited (opts.inheritedEnv()), sibling variables, PORT (unless it
+// has no port) and PORT_<NAME>, FORCE_COLOR and MarkerEnv, then env.
+// References in env values are filled in by the caller before the process
+// starts.
+func buildEnv(name string, inherited []string, env map[string]string, opts Options, port int, ports map[string]int) []string {
+	procEnv := inherited
+	for _, k := range slices.Sorted(maps.Keys(opts.SiblingEnv)) {
+		procEnv = append(procEnv, fmt.Sprintf("%s=%s", k, opts.SiblingEnv[k]))
+	}
+	if port != 0 {
+		procEnv = append(procEnv, fmt.Sprintf("PORT=%d", port))
+	}
+	for _, portName := range slices.Sorted(maps.Keys(ports)) {
+		procEnv = append(procEnv, fmt.Sprintf("%s=%d", PortEnvName(portName), ports[portName]))
+	}
+	procEnv = append(procEnv, "FORCE_COLOR=1", MarkerEnv+"="+name)
+
+	for _, k := range slices.Sorted(maps.Keys(env)) {
+		procEnv = append(procEnv, fmt.Sprintf("%s=%s", k, env[k]))
+	}
+	return procEnv
+}
+
+// Environment returns the environment a process would start with, sorted by
+// name with later duplicates winning as they do for the real process, given
+// ports from AssignPorts or PredictPorts ("<process>:<name>" for named ports)
+func Environment(name string, env map[string]string, opts Options, ports map[string]int) []string {
+	var port int
+	if !opts.NoPort {
+		port = ports[name]
+	}
+	named := make(map[string]int, len(opts.NamedPorts))
+	for _, portName := range opts.NamedPorts {
+		named[portName] = ports[name+":"+portName]
+	}
+
+	procEnv := buildEnv(name, opts.inheritedEnv(), env, opts, port, named)
+	byName := make(map[string]string, len(procEnv))
+	for _, kv := range procEnv {
+		k, _, _ := strings.Cut(kv, "=")
+		byName[k] = kv
+	}
+	effective := make([]string, 0, len(byName))
+	for _, k := range slices.Sorted(maps.Keys(byName)) {
+		effective = append(effective, byName[k])
+	}
+	return effective
+}
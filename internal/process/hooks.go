@@ -0,0 +1,110 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// Phases of a starting process, reported by Process.Phase. Hook names match
+// the phase they run in.
+const (
+	PhaseBeforeStart = "before_start" // running the before_start hook
+	PhaseBoot        = "boot"         // command started, waiting for it to be ready
+	PhaseAfterReady  = "after_ready"  // ready, running the after_ready hook
+	PhaseAfterStop   = "after_stop"   // exited, running the after_stop hook
+)
+
+// afterStopTimeout bounds the after_stop hook, which runs after the process
+// context has been cancelled
+const afterStopTimeout = 5 * time.Minute
+
+// Hooks are commands run around a process's lifetime, in its directory and
+// environment, with their output going to its logs. A failing hook marks the
+// process failed.
+type Hooks struct {
+	BeforeStart string // Before the command starts (e.g., bundle install)
+	AfterReady  string // Once the readiness check passes; the process stays starting until it finishes
+	AfterStop   string // After the command exits, whether stopped or crashed
+}
+
+// runHook runs a hook command and waits for it, streaming its output into
+// the process logs. Cancelling ctx kills the hook and everything it started.
+func (p *Process) runHook(ctx context.Context, phase, command string) error {
+	p.logs.Write([]byte(fmt.Sprintf("[roost-dev] Running %s hook: %s\n", phase, command)))
+	fmt.Printf("[roost-dev] %s: running %s hook\n", p.Name, phase)
+
+	cmd := shellCommand(ctx, p.opts.hookShell(), command)
+	cmd.Dir = p.Dir
+	cmd.Env = p.cmd.Env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Read everything before Wait, which closes the pipe
+	streamLogs(out, p.logs, p.Name)
+	if err := cmd.Wait(); err != nil {
+		p.logs.Write([]byte(fmt.Sprintf("[roost-dev] %s\n", hookError(phase, err))))
+		return err
+	}
+	return nil
+}
+
+// hookError describes a failed hook, with its exit code when it has one
+func hookError(phase string, err error) string {
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+		return fmt.Sprintf("%s hook failed: exit code %d", phase, exitErr.ExitCode())
+	}
+	return fmt.Sprintf("%s hook failed: %v", phase, err)
+}
+
+// Phase returns what a starting process is doing (PhaseBeforeStart, PhaseBoot
+// or PhaseAfterReady), or "" once it is no longer starting
+func (p *Process) Phase() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.starting {
+		return ""
+	}
+	return p.phase
+}
+
+// finishStarting ends the starting phase. A non-empty failure marks the
+// process failed with it, unless the process was stopped on purpose.
+func (p *Process) finishStarting(failure string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.starting = false
+	p.phase = ""
+	if failure != "" && !p.stopped {
+		p.failed = true
+		p.exitError = failure
+	}
+}
+
+// fail records why roost-dev is failing the process; handleExit reports it
+// once the process exits. The first reason wins.
+func (p *Process) fail(reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failReason == "" {
+		p.failReason = reason
+	}
+}
+
+// isStopped returns true once Kill has been called
+func (p *Process) isStopped() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopped
+}
@@ -0,0 +1,82 @@
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// I/O scheduling classes for Limits.IOClass (Linux only)
+const (
+	IOClassIdle       = "idle"        // Only gets disk time nothing else wants
+	IOClassBestEffort = "best-effort" // The default class, at Limits.IOLevel
+)
+
+// Limits caps the resources of a process group. Zero values leave a
+// resource unlimited (or as roost-dev has it).
+type Limits struct {
+	Memory    int64  // Bytes; a cgroup's memory.max on Linux when one can be set up, else RLIMIT_DATA
+	OpenFiles int    // RLIMIT_NOFILE
+	Nice      int    // Added to the CPU niceness, -20 to 19 (negative needs root)
+	IOClass   string // IOClassIdle or IOClassBestEffort ("" = unchanged; Linux only)
+	IOLevel   int    // Priority within IOClassBestEffort, 0 (highest) to 7
+}
+
+// wrap makes cmd set its rlimits and niceness before running, by starting it
+// through /bin/sh, which execs the real program so it keeps the pid and
+// process group. memoryRlimit is false when a cgroup enforces Memory instead.
+func (l Limits) wrap(cmd *exec.Cmd, memoryRlimit bool) {
+	var steps []string
+	if l.OpenFiles > 0 {
+		steps = append(steps, fmt.Sprintf("ulimit -n %d", l.OpenFiles))
+	}
+	if l.Memory > 0 && memoryRlimit {
+		steps = append(steps, fmt.Sprintf("ulimit -d %d", l.Memory/1024))
+	}
+	run := `exec "$0" "$@"`
+	if l.Nice != 0 {
+		run = fmt.Sprintf(`exec nice -n %d "$0" "$@"`, l.Nice)
+	}
+	if cmd.Err != nil || (len(steps) == 0 && l.Nice == 0) {
+		return // Nothing to set, or Start will report the lookup error
+	}
+	script := strings.Join(append(steps, run), " && ")
+	cmd.Args = append([]string{"sh", "-c", script, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+}
+
+// formatBytes formats a size the way limits are written (512M, 1.5G)
+func formatBytes(n int64) string {
+	for i, unit := range []string{"T", "G", "M", "K"} {
+		size := int64(1) << (10 * (4 - i))
+		if n >= size {
+			return strconv.FormatFloat(float64(n)/float64(size), 'f', -1, 64) + unit
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// parseCgroupPath returns the cgroup v2 path in /proc/self/cgroup contents
+func parseCgroupPath(data string) (string, bool) {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// parseOOMKills returns the oom_kill count in a cgroup's memory.events
+func parseOOMKills(data string) int {
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		if count, ok := strings.CutPrefix(scanner.Text(), "oom_kill "); ok {
+			n, _ := strconv.Atoi(count)
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,201 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// cgroupFS is where the cgroup v2 hierarchy is mounted
+const cgroupFS = "/sys/fs/cgroup"
+
+var (
+	cgroupOnce   sync.Once
+	cgroupParent string // Directory process cgroups are created in, if set up
+	cgroupErr    error  // Why cgroups aren't used
+)
+
+// cgroup is a cgroup v2 group a process runs in, so its memory limit covers
+// the whole tree and an OOM kill can be told apart from other failures
+type cgroup struct {
+	path string
+	fd   int // Open directory, passed to clone so the process starts inside
+}
+
+// setupCgroups prepares the cgroup roost-dev runs in to hold a child group
+// per process. cgroup v2 only lets a group with no processes of its own
+// distribute memory, so roost-dev moves itself into a "roost-dev" leaf and
+// creates process groups under "procs". This only works when roost-dev has
+// its delegated cgroup to itself (systemd-run --user --scope roost-dev ...).
+func setupCgroups() (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupFS, "cgroup.controllers")); err != nil {
+		return "", errors.New("no cgroup v2 hierarchy")
+	}
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	rel, ok := parseCgroupPath(string(data))
+	if !ok {
+		return "", errors.New("not in a cgroup v2 group")
+	}
+	base := filepath.Join(cgroupFS, rel)
+	if syscall.Access(filepath.Join(base, "cgroup.subtree_control"), 2) != nil {
+		return "", fmt.Errorf("cgroup %s isn't writable (not delegated)", base)
+	}
+	controllers, err := os.ReadFile(filepath.Join(base, "cgroup.controllers"))
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(strings.Fields(string(controllers)), "memory") {
+		return "", fmt.Errorf("memory controller not available in %s", base)
+	}
+	procs, err := os.ReadFile(filepath.Join(base, "cgroup.procs"))
+	if err != nil {
+		return "", err
+	}
+	for _, pid := range strings.Fields(string(procs)) {
+		if pid != strconv.Itoa(os.Getpid()) {
+			return "", fmt.Errorf("cgroup %s has other processes besides roost-dev", base)
+		}
+	}
+
+	self := filepath.Join(base, "roost-dev")
+	parent := filepath.Join(base, "procs")
+	steps := []func() error{
+		func() error { return mkdirIfMissing(self) },
+		func() error { return writeCgroupFile(self, "cgroup.procs", strconv.Itoa(os.Getpid())) },
+		func() error { return writeCgroupFile(base, "cgroup.subtree_control", "+memory") },
+		func() error { return mkdirIfMissing(parent) },
+		func() error { return writeCgroupFile(parent, "cgroup.subtree_control", "+memory") },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return "", err
+		}
+	}
+	return parent, nil
+}
+
+// newCgroup creates a cgroup named name (unique per run) with the memory
+// limit set, or returns nil if the limit should fall back to an rlimit
+func newCgroup(name string, l Limits) *cgroup {
+	if l.Memory <= 0 {
+		return nil
+	}
+	cgroupOnce.Do(func() {
+		cgroupParent, cgroupErr = setupCgroups()
+		if cgroupErr != nil {
+			fmt.Printf("[roost-dev] Memory limits use RLIMIT_DATA: %v\n", cgroupErr)
+		}
+	})
+	if cgroupErr != nil {
+		return nil
+	}
+
+	path := filepath.Join(cgroupParent, name)
+	if err := mkdirIfMissing(path); err != nil {
+		fmt.Printf("[roost-dev] %s: couldn't create cgroup, using RLIMIT_DATA: %v\n", name, err)
+		return nil
+	}
+	if err := writeCgroupFile(path, "memory.max", strconv.FormatInt(l.Memory, 10)); err != nil {
+		fmt.Printf("[roost-dev] %s: couldn't set memory.max, using RLIMIT_DATA: %v\n", name, err)
+		os.Remove(path)
+		return nil
+	}
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		os.Remove(path)
+		return nil
+	}
+	return &cgroup{path: path, fd: fd}
+}
+
+// attach makes cmd start inside the cgroup
+func (c *cgroup) attach(cmd *exec.Cmd) {
+	if c == nil {
+		return
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = c.fd
+}
+
+// started closes the directory handle once the process is running in the group
+func (c *cgroup) started() {
+	if c != nil && c.fd >= 0 {
+		syscall.Close(c.fd)
+		c.fd = -1
+	}
+}
+
+// oomKilled returns true if the kernel killed a process in the group for
+// going over the memory limit
+func (c *cgroup) oomKilled() bool {
+	if c == nil {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(c.path, "memory.events"))
+	return err == nil && parseOOMKills(string(data)) > 0
+}
+
+// remove deletes the cgroup, killing anything left in it first
+func (c *cgroup) remove() {
+	if c == nil {
+		return
+	}
+	c.started()
+	if os.Remove(c.path) == nil {
+		return
+	}
+	writeCgroupFile(c.path, "cgroup.kill", "1")
+	for range 10 {
+		time.Sleep(50 * time.Millisecond)
+		if os.Remove(c.path) == nil {
+			return
+		}
+	}
+}
+
+// setIOPriority sets the I/O scheduling priority of a process group
+func (l Limits) setIOPriority(pgid int) error {
+	const (
+		ioprioWhoPgrp   = 2
+		ioprioClassBE   = 2
+		ioprioClassIdle = 3
+		ioprioClassLen  = 13
+	)
+	var prio int
+	switch l.IOClass {
+	case "":
+		return nil
+	case IOClassIdle:
+		prio = ioprioClassIdle << ioprioClassLen
+	case IOClassBestEffort:
+		prio = ioprioClassBE<<ioprioClassLen | l.IOLevel
+	default:
+		return fmt.Errorf("unknown I/O class %q", l.IOClass)
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoPgrp, uintptr(pgid), uintptr(prio)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func mkdirIfMissing(path string) error {
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	return os.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package process
+
+import "os/exec"
+
+// cgroup is only available on Linux; elsewhere memory limits use RLIMIT_DATA
+type cgroup struct{}
+
+func newCgroup(name string, l Limits) *cgroup { return nil }
+
+func (c *cgroup) attach(cmd *exec.Cmd) {}
+
+func (c *cgroup) started() {}
+
+func (c *cgroup) oomKilled() bool { return false }
+
+func (c *cgroup) remove() {}
+
+// setIOPriority does nothing outside Linux, which has no ioprio_set
+func (l Limits) setIOPriority(pgid int) error { return nil }
@@ -0,0 +1,178 @@
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxLogSize is the size at which a log file is rotated
+	maxLogSize = 5 * 1024 * 1024
+
+	// maxLogBackups is how many rotated files (<name>.log.1, .2, ...) are kept
+	maxLogBackups = 3
+
+	// logTimeFormat prefixes every line written to a log file
+	logTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+	// runMarker starts the line written to a log file each time the process starts
+	runMarker = "[roost-dev] Started "
+)
+
+// LogFile appends timestamped log lines to a file on disk, rotating it when it grows too large
+type LogFile struct {
+	mu      sync.Mutex
+	path    string
+	f       *os.File
+	size    int64
+	maxSize int64
+}
+
+// OpenLogFile opens (or creates) the log file at path for appending
+func OpenLogFile(path string) (*LogFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	l := &LogFile{path: path, maxSize: maxLogSize}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *LogFile) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f = f
+	l.size = info.Size()
+	return nil
+}
+
+// WriteLine appends a line with the given timestamp
+func (l *LogFile) WriteLine(t time.Time, line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return
+	}
+	if l.size >= l.maxSize {
+		l.rotate()
+	}
+	n, _ := fmt.Fprintf(l.f, "%s %s\n", t.Format(logTimeFormat), line)
+	l.size += int64(n)
+}
+
+// rotate shifts <name>.log to <name>.log.1 (and so on), dropping the oldest.
+// Caller must hold l.mu.
+func (l *LogFile) rotate() {
+	l.f.Close()
+	l.f = nil
+	os.Remove(fmt.Sprintf("%s.%d", l.path, maxLogBackups))
+	for i := maxLogBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	os.Rename(l.path, l.path+".1")
+	if err := l.open(); err != nil {
+		fmt.Printf("[roost-dev] Failed to reopen log file %s: %v\n", l.path, err)
+	}
+}
+
+// Close closes the underlying file
+func (l *LogFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// LogQuery selects lines when reading a log file back
+type LogQuery struct {
+	Since         time.Time // Only lines logged at or after this time (zero = all)
+	BeforeRestart bool      // Only the run before the most recent start
+}
+
+// ReadLogFile returns the lines of the log file at path, including its rotated
+// predecessors, oldest first. Lines keep their timestamp prefix.
+func ReadLogFile(path string, q LogQuery) ([]string, error) {
+	var lines []string
+	found := false
+	for i := maxLogBackups; i >= 0; i-- {
+		name := path
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", path, i)
+		}
+		f, err := os.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, os.ErrNotExist
+	}
+
+	if q.BeforeRestart {
+		lines = previousRun(lines)
+	}
+	if !q.Since.IsZero() {
+		filtered := lines[:0:0]
+		for _, line := range lines {
+			if t, ok := lineTime(line); ok && !t.Before(q.Since) {
+				filtered = append(filtered, line)
+			}
+		}
+		lines = filtered
+	}
+	return lines, nil
+}
+
+// previousRun returns the lines from the second-to-last run marker up to the
+// last one, i.e. the output of the run before the most recent start
+func previousRun(lines []string) []string {
+	last, prev := -1, -1
+	for i, line := range lines {
+		if _, rest, ok := strings.Cut(line, " "); ok && strings.HasPrefix(rest, runMarker) {
+			prev, last = last, i
+		}
+	}
+	if prev < 0 {
+		return nil
+	}
+	return lines[prev:last]
+}
+
+// lineTime parses the timestamp a log file line starts with
+func lineTime(line string) (time.Time, bool) {
+	stamp, _, _ := strings.Cut(line, " ")
+	t, err := time.Parse(logTimeFormat, stamp)
+	return t, err == nil
+}
@@ -5,13 +5,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"maps"
 	"net"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
 	"runtime"
-	"strconv"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -50,23 +51,74 @@ type Process struct {
 	Command string
 	Dir     string
 	Port    int
+	Ports   map[string]int // Named extra ports (Options.NamedPorts)
 	Env     map[string]string
 
-	cmd       *exec.Cmd
-	cancel    context.CancelFunc
-	logs      *LogBuffer
-	started   time.Time
-	starting  bool // true while waiting for port to be ready
-	failed    bool
-	exitError string
-	mu        sync.Mutex
+	opts           Options
+	cmd            *exec.Cmd
+	cancel         context.CancelFunc
+	logs           *LogBuffer
+	started        time.Time
+	lastRequest    time.Time // last time a request was proxied to this process
+	starting       bool      // true while waiting for port to be ready
+	failed         bool
+	exitError      string
+	stopped        bool          // true once Kill has been called
+	stopCh         chan struct{} // closed by Kill to cancel pending restarts
+	restarts       int           // automatic restarts so far
+	crashes        int           // consecutive crashes, reset after a stable run
+	restartPending bool          // waiting out the backoff before an automatic restart
+	crashLooping   bool          // gave up restarting after too many crashes
+	failReason     string        // why roost-dev failed the process itself (readiness timeout, hook failure)
+	phase          string        // what the process is doing while starting (Phase* constants)
+	exited         chan struct{} // closed once the command has exited and after_stop has run
+	cgroup         *cgroup       // cgroup enforcing the memory limit (nil = none)
+	oomKilled      bool          // the kernel killed the process for going over its memory limit
+	metrics        Metrics       // resource use at the last sample
+	cpuTime        time.Duration // total CPU time of the tree at the last sample
+	adopted        bool          // left running by a previous roost-dev run and adopted by Recover
+	gone           bool          // an adopted process has exited (there's no ProcessState for it)
+	mu             sync.Mutex
+}
+
+// Options holds optional per-process settings
+type Options struct {
+	Restart       RestartPolicy
+	Ready         ReadyCheck     // When the process counts as ready (default: port accepts connections)
+	StopSignal    syscall.Signal // Sent to the process group on stop (0 = SIGTERM)
+	StopTimeout   time.Duration  // How long to wait for exit before SIGKILL (0 = default)
+	StopCommand   string         // Run instead of sending StopSignal, if set
+	NoPort        bool           // Don't allocate a port (workers); ready once started unless Ready says otherwise
+	PreferredPort int            // Port to use if free, before the last used or a random one
+	NamedPorts    []string       // Extra ports to reserve, passed as PORT_<NAME>
+	Hooks         Hooks          // Commands run before start, after ready and after stop
+	Watch         *WatchSpec     // Restart when files under the dir change (nil = don't watch)
+	Exec          []string       // Argv to run instead of the command
+	Shell         string         // ShellLogin, ShellPlain, ShellNone or a shell path ("" = login, or none for Exec)
+	CleanEnv      bool           // Inherit only essentialEnv and EnvAllow from roost-dev's environment
+	EnvAllow      []string       // With CleanEnv, more variables to inherit (NAME, or PREFIX* for a prefix)
+	DependsOn     []string       // Processes this one needs, which StopAll and StopGroup stop after it
+	Limits        Limits         // Memory, open files and CPU/IO priority caps
+	PIDFile       string         // Pidfile the command writes, relative to the dir; see cleanupPIDFiles
+
+	// Port and Ports (by name) are the ports the process must get, because
+	// its env and exec args were filled in with them (see AssignPorts). It
+	// fails to start if one is taken. Unset, ports are allocated on start.
+	Port  int
+	Ports map[string]int
+
+	// SiblingEnv describes related processes (PORT_API, URL_API, ...). It is
+	// set before env, whose values can reference it as $NAME.
+	SiblingEnv map[string]string
 }
 
 // LogBuffer stores recent log output
 type LogBuffer struct {
-	mu    sync.RWMutex
-	lines []string
-	max   int
+	mu      sync.RWMutex
+	lines   []string
+	max     int
+	written int      // total lines ever stored, so callers can mark a position
+	file    *LogFile // optional on-disk copy of every line
 }
 
 // NewLogBuffer creates a new log buffer
@@ -92,6 +144,10 @@ func (b *LogBuffer) Write(p []byte) (n int, err error) {
 			continue
 		}
 		b.lines = append(b.lines, line)
+		b.written++
+		if b.file != nil {
+			b.file.WriteLine(time.Now(), line)
+		}
 		if len(b.lines) > b.max {
 			b.lines = b.lines[1:]
 		}
@@ -108,6 +164,36 @@ func (b *LogBuffer) Lines() []string {
 	return result
 }
 
+// Mark returns a position that LinesSince can read from
+func (b *LogBuffer) Mark() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.written
+}
+
+// LinesSince returns the stored lines written after mark
+func (b *LogBuffer) LinesSince(mark int) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	start := mark - (b.written - len(b.lines))
+	if start < 0 {
+		start = 0
+	}
+	if start > len(b.lines) {
+		start = len(b.lines)
+	}
+	result := make([]string, len(b.lines)-start)
+	copy(result, b.lines[start:])
+	return result
+}
+
+// SetFile makes the buffer also append every line to f
+func (b *LogBuffer) SetFile(f *LogFile) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.file = f
+}
+
 // Clear clears the log buffer
 func (b *LogBuffer) Clear() {
 	b.mu.Lock()
@@ -119,7 +205,16 @@ func (b *LogBuffer) Clear() {
 type Manager struct {
 	mu            sync.RWMutex
 	processes     map[string]*Process
-	reservedPorts map[int]bool // ports allocated but not yet bound
+	reservedPorts map[int]bool            // ports allocated but not yet bound
+	idleStopped   map[string]bool         // processes last stopped for inactivity
+	logDir        string                  // where per-process log files go ("" = memory only)
+	logFiles      map[string]*LogFile     // open log files by process name
+	portsFile     string                  // where sticky port assignments are saved ("" = not saved)
+	stateFile     string                  // where running processes are recorded for Recover ("" = not saved)
+	stickyPorts   map[string]int          // last port used by each process name
+	watchers      map[string]*fileWatcher // restart processes when their files change
+	triggers      map[string]string       // file whose change caused the last restart
+	watchRestart  func(name string)       // restarts a process for a file change (nil = RestartAsync)
 	portStart     int
 	portEnd       int
 	nextPort      int
@@ -134,12 +229,53 @@ func NewManager() *Manager {
 	return &Manager{
 		processes:     make(map[string]*Process),
 		reservedPorts: make(map[int]bool),
+		idleStopped:   make(map[string]bool),
+		logFiles:      make(map[string]*LogFile),
+		stickyPorts:   make(map[string]int),
+		watchers:      make(map[string]*fileWatcher),
+		triggers:      make(map[string]string),
 		portStart:     portStart,
 		portEnd:       portEnd,
 		nextPort:      nextPort,
 	}
 }
 
+// SetLogDir enables writing each process's output to <dir>/<name>.log
+func (m *Manager) SetLogDir(dir string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logDir = dir
+}
+
+// LogPath returns the path of the log file for a process name, or "" if
+// log files are disabled. The file may not exist if the process never ran.
+func (m *Manager) LogPath(name string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.logDir == "" {
+		return ""
+	}
+	return filepath.Join(m.logDir, name+".log")
+}
+
+// logFile returns the open log file for a process, opening it on first use.
+// Caller must hold m.mu.
+func (m *Manager) logFile(name string) *LogFile {
+	if m.logDir == "" {
+		return nil
+	}
+	if lf, ok := m.logFiles[name]; ok {
+		return lf
+	}
+	lf, err := OpenLogFile(filepath.Join(m.logDir, name+".log"))
+	if err != nil {
+		fmt.Printf("[roost-dev] Failed to open log file for %s: %v\n", name, err)
+		return nil
+	}
+	m.logFiles[name] = lf
+	return lf
+}
+
 // findFreePort finds an available port and reserves it.
 // Caller must call releasePort if the port won't be used.
 func (m *Manager) findFreePort() (int, error) {
@@ -150,51 +286,75 @@ func (m *Manager) findFreePort() (int, error) {
 			m.nextPort = m.portStart
 		}
 
-		// Skip ports we've already reserved for other processes
-		if m.reservedPorts[port] {
-			fmt.Printf("[roost-dev] Port %d is reserved, skipping\n", port)
+		// Leave ports that other processes used last time for them
+		if owner := m.stickyOwner(port); owner != "" {
+			fmt.Printf("[roost-dev] Port %d was last used by %s, skipping\n", port, owner)
 			continue
 		}
 
-		// First check if anything is already LISTENING on this port
-		// This catches processes bound to 0.0.0.0 that wouldn't block our 127.0.0.1 bind
-		conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), 50*time.Millisecond)
-		if err == nil {
-			conn.Close()
-			fmt.Printf("[roost-dev] Port %d has something listening, skipping\n", port)
-			continue
+		if m.reservePort(port) {
+			return port, nil
 		}
+	}
+	return 0, fmt.Errorf("no free ports available in range %d-%d", m.portStart, m.portEnd)
+}
 
-		// Also check 0.0.0.0 binding to be thorough
-		conn, err = net.DialTimeout("tcp", fmt.Sprintf("0.0.0.0:%d", port), 50*time.Millisecond)
-		if err == nil {
-			conn.Close()
-			fmt.Printf("[roost-dev] Port %d has something listening on 0.0.0.0, skipping\n", port)
-			continue
-		}
+// reservePort reserves port if nothing is using it. Caller must hold m.mu.
+func (m *Manager) reservePort(port int) bool {
+	// Skip ports we've already reserved for other processes
+	if m.reservedPorts[port] {
+		fmt.Printf("[roost-dev] Port %d is reserved, skipping\n", port)
+		return false
+	}
 
-		// Now check if we can bind to it
-		ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
-		if err == nil {
-			ln.Close()
-			// Reserve this port until the process binds or fails
-			m.reservedPorts[port] = true
-			fmt.Printf("[roost-dev] Allocated and reserved port %d\n", port)
-			return port, nil
-		}
+	// First check if anything is already LISTENING on this port
+	// This catches processes bound to 0.0.0.0 that wouldn't block our 127.0.0.1 bind
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), 50*time.Millisecond)
+	if err == nil {
+		conn.Close()
+		fmt.Printf("[roost-dev] Port %d has something listening, skipping\n", port)
+		return false
+	}
+
+	// Also check 0.0.0.0 binding to be thorough
+	conn, err = net.DialTimeout("tcp", fmt.Sprintf("0.0.0.0:%d", port), 50*time.Millisecond)
+	if err == nil {
+		conn.Close()
+		fmt.Printf("[roost-dev] Port %d has something listening on 0.0.0.0, skipping\n", port)
+		return false
+	}
+
+	// Now check if we can bind to it
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
 		fmt.Printf("[roost-dev] Port %d bind failed: %v\n", port, err)
+		return false
 	}
-	return 0, fmt.Errorf("no free ports available in range %d-%d", m.portStart, m.portEnd)
+	ln.Close()
+	// Reserve this port until the process binds or fails
+	m.reservedPorts[port] = true
+	fmt.Printf("[roost-dev] Allocated and reserved port %d\n", port)
+	return true
 }
 
 // releasePort removes a port reservation
 func (m *Manager) releasePort(port int) {
+	if port == 0 {
+		return // Nothing reserved (a process without a port)
+	}
 	fmt.Printf("[roost-dev] Released port reservation: %d\n", port)
 	delete(m.reservedPorts, port)
 }
 
 // Start starts a process
 func (m *Manager) Start(name, command, dir string, env map[string]string) (*Process, error) {
+	return m.StartWithOptions(name, command, dir, env, Options{})
+}
+
+// StartWithOptions starts a process and waits up to 30s for it to be ready
+func (m *Manager) StartWithOptions(name, command, dir string, env map[string]string, opts Options) (*Process, error) {
+	// Resolving the login environment can take a while, so not under m.mu
+	inherited := opts.inheritedEnv()
 	m.mu.Lock()
 
 	// Check if already running
@@ -203,309 +363,450 @@ func (m *Manager) Start(name, command, dir string, env map[string]string) (*Proc
 		return p, nil
 	}
 
-	// Clean up stale Rails PID file if this looks like a Rails server
-	if strings.Contains(command, "rails server") || strings.Contains(command, "rails s") {
-		cleanupRailsPID(dir)
+	proc, err := m.spawn(name, command, dir, env, opts, inherited, nil)
+
+	// Release lock BEFORE waiting for port - this can take a while and would block all requests
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
 	}
 
+	// Wait up to 30s for initial startup, then return
+	// Process stays in "starting" state until its readiness check passes
+	proc.waitReady(30 * time.Second)
+
+	return proc, nil
+}
+
+// StartAsync starts a process without waiting for the port to be ready.
+// Returns immediately after the process is spawned.
+func (m *Manager) StartAsync(name, command, dir string, env map[string]string) (*Process, error) {
+	return m.StartAsyncWithOptions(name, command, dir, env, Options{})
+}
+
+// StartAsyncWithOptions is StartAsync with per-process options
+func (m *Manager) StartAsyncWithOptions(name, command, dir string, env map[string]string, opts Options) (*Process, error) {
+	// Resolving the login environment can take a while, so not under m.mu
+	inherited := opts.inheritedEnv()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Check if already running or starting
+	if p, exists := m.processes[name]; exists && (p.IsRunning() || p.IsStarting()) {
+		return p, nil
+	}
+
+	return m.spawn(name, command, dir, env, opts, inherited, nil)
+}
+
+// spawn launches a process, registers it under name and watches for its port.
+// inherited is opts.inheritedEnv(), resolved before taking m.mu. If prev is
+// set, this is an automatic restart of prev and its logs and restart counters
+// carry over. Caller must hold m.mu.
+func (m *Manager) spawn(name, command, dir string, env map[string]string, opts Options, inherited []string, prev *Process) (*Process, error) {
 	// Check if working directory exists
 	if dir != "" {
 		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			m.mu.Unlock()
 			return nil, fmt.Errorf("working directory does not exist: %s", dir)
 		}
 	}
+	if opts.NoPort && opts.Ready.Type == "" {
+		// Nothing to connect to, so it's ready once started
+		opts.Ready.Type = ReadyNone
+	}
 
-	// Find a free port
-	port, err := m.findFreePort()
-	if err != nil {
-		m.mu.Unlock()
-		return nil, err
+	var port int
+	var ports map[string]int
+	var err error
+	if opts.Port != 0 || len(opts.Ports) > 0 {
+		// Env and exec args refer to these, so no other port will do
+		if port, ports, err = m.reserveAssignedPorts(opts.Port, opts.Ports); err != nil {
+			return nil, err
+		}
+	} else {
+		// Find a free port, preferring the configured or last used one
+		if !opts.NoPort {
+			if port, err = m.allocatePort(name, opts.PreferredPort); err != nil {
+				return nil, err
+			}
+		}
+		// Reserve the named ports along with it, all or nothing
+		if ports, err = m.allocateNamedPorts(name, opts.NamedPorts); err != nil {
+			m.releasePort(port)
+			return nil, err
+		}
+	}
+	// Released once the process binds or exits, whichever is noticed first
+	var releaseOnce sync.Once
+	releasePorts := func() {
+		releaseOnce.Do(func() {
+			m.releasePort(port)
+			for _, p := range ports {
+				m.releasePort(p)
+			}
+		})
+	}
+	if opts.NoPort {
+		fmt.Printf("[roost-dev] Starting %s (no port)\n", name)
+	} else {
+		fmt.Printf("[roost-dev] Starting %s on port %d\n", name, port)
 	}
-	fmt.Printf("[roost-dev] Starting %s on port %d\n", name, port)
 
 	// Create process
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// Build environment
-	procEnv := os.Environ()
-	procEnv = append(procEnv, fmt.Sprintf("PORT=%d", port))
-	procEnv = append(procEnv, "FORCE_COLOR=1")
-	portStr := fmt.Sprintf("%d", port)
-	for k, v := range env {
-		// Expand $PORT in env values
-		v = strings.ReplaceAll(v, "$PORT", portStr)
-		procEnv = append(procEnv, fmt.Sprintf("%s=%s", k, v))
-	}
-
-	// Parse command (handle shell execution)
-	// Use interactive login shell to ensure user's environment (rvm, rbenv, nvm, etc.) is loaded
-	// -l (login) sources .zprofile; -i (interactive) sources .zshrc/.bashrc
-	shell := getUserShell()
-	cmd := exec.CommandContext(ctx, shell, "-i", "-l", "-c", command)
+	procEnv := buildEnv(name, inherited, env, opts, port, ports)
+
+	// By default the command runs in an interactive login shell so the user's
+	// environment (rvm, rbenv, nvm, etc.) is loaded; see Options.Shell
+	var cmd *exec.Cmd
+	if len(opts.Exec) > 0 {
+		if opts.shell() == ShellNone {
+			cmd = execCommand(ctx, opts.Exec, procEnv)
+		} else {
+			cmd = shellCommand(ctx, opts.shell(), shellJoin(opts.Exec))
+		}
+	} else {
+		cmd = shellCommand(ctx, opts.shell(), command)
+	}
 	cmd.Dir = dir
 	cmd.Env = procEnv
 	// Run in own process group so we can kill the entire tree
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
-	// Set up logging
+	// Cap memory with a cgroup where possible, which covers the whole tree,
+	// and the rest with rlimits set just before the command runs
+	cg := newCgroup(fmt.Sprintf("%s-%d", name, time.Now().UnixNano()), opts.Limits)
+	cg.attach(cmd)
+	opts.Limits.wrap(cmd, cg == nil)
+
+	// Set up logging (automatic restarts keep the previous output for context)
 	logs := NewLogBuffer(1000)
+	if prev != nil {
+		logs = prev.logs
+	}
+	logMark := logs.Mark()
 
-	stdout, err := cmd.StdoutPipe()
+	now := time.Now()
+	proc := &Process{
+		Name:        name,
+		Command:     command,
+		Dir:         dir,
+		Port:        port,
+		Ports:       ports,
+		Env:         env,
+		opts:        opts,
+		cmd:         cmd,
+		cancel:      cancel,
+		logs:        logs,
+		started:     now,
+		lastRequest: now,
+		starting:    true,
+		stopCh:      make(chan struct{}),
+		exited:      make(chan struct{}),
+		cgroup:      cg,
+	}
+	if prev != nil {
+		proc.restarts = prev.restarts + 1
+		proc.crashes = prev.crashes
+	}
+
+	// Keep a copy of the output on disk, marking where each run starts
+	if lf := m.logFile(name); lf != nil {
+		logs.SetFile(lf)
+		lf.WriteLine(now, fmt.Sprintf("%s%s on port %d", runMarker, name, port))
+	}
+
+	// Clear out pidfiles a previous run left behind (Rails, Puma, ...)
+	orphans := m.cleanupPIDFiles(proc)
+
+	if opts.Hooks.BeforeStart == "" && len(orphans) == 0 {
+		if err := m.launch(proc, ctx, logMark, releasePorts); err != nil {
+			cancel()
+			releasePorts()
+			cg.remove()
+			return nil, err
+		}
+	} else {
+		// Stop orphans and run the hook in the background, without holding
+		// m.mu; the process shows as starting meanwhile
+		if opts.Hooks.BeforeStart != "" {
+			proc.phase = PhaseBeforeStart
+		}
+		go m.runBeforeStart(proc, ctx, logMark, releasePorts, orphans)
+	}
+
+	m.processes[name] = proc
+	delete(m.idleStopped, name)
+	if opts.Watch != nil {
+		m.watch(name, dir, opts.Watch.withOwnFiles(proc))
+	}
+	m.saveState()
+	return proc, nil
+}
+
+// runBeforeStart stops the orphans left holding the process's pidfiles and
+// runs the before_start hook, if any, then launches the process unless the
+// hook fails or the process is stopped or replaced meanwhile
+func (m *Manager) runBeforeStart(proc *Process, ctx context.Context, logMark int, releasePorts func(), orphans []orphan) {
+	proc.stopOrphans(orphans)
+	var err error
+	if hook := proc.opts.Hooks.BeforeStart; hook != "" {
+		err = proc.runHook(ctx, PhaseBeforeStart, hook)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current, ok := m.processes[proc.Name]; !ok || current != proc || proc.isStopped() {
+		releasePorts()
+		proc.cgroup.remove()
+		proc.finishStarting("")
+		return
+	}
 	if err != nil {
-		cancel()
-		m.releasePort(port)
-		m.mu.Unlock()
-		return nil, fmt.Errorf("stdout pipe: %w", err)
+		releasePorts()
+		proc.cgroup.remove()
+		proc.finishStarting(hookError(PhaseBeforeStart, err))
+		return
 	}
+	if err := m.launch(proc, ctx, logMark, releasePorts); err != nil {
+		releasePorts()
+		proc.cgroup.remove()
+		proc.finishStarting(err.Error())
+		return
+	}
+	m.saveState()
+}
 
-	stderr, err := cmd.StderrPipe()
+// launch starts the process's command, streams its output and watches for
+// exit and readiness. Caller must hold m.mu.
+func (m *Manager) launch(proc *Process, ctx context.Context, logMark int, releasePorts func()) error {
+	cmd := proc.cmd
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		cancel()
-		m.releasePort(port)
-		m.mu.Unlock()
-		return nil, fmt.Errorf("stderr pipe: %w", err)
+		return fmt.Errorf("stdout pipe: %w", err)
 	}
 
-	proc := &Process{
-		Name:    name,
-		Command: command,
-		Dir:     dir,
-		Port:    port,
-		Env:     env,
-		cmd:     cmd,
-		cancel:  cancel,
-		logs:    logs,
-		started: time.Now(),
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("stderr pipe: %w", err)
 	}
 
 	// Start process
 	if err := cmd.Start(); err != nil {
-		cancel()
-		m.releasePort(port)
-		m.mu.Unlock()
-		return nil, fmt.Errorf("start process: %w", err)
+		return fmt.Errorf("start process: %w", err)
+	}
+	proc.cgroup.started()
+	// The process leads its own group, so its pid is the group id
+	if err := proc.opts.Limits.setIOPriority(cmd.Process.Pid); err != nil {
+		fmt.Printf("[roost-dev] %s: couldn't set I/O priority: %v\n", proc.Name, err)
 	}
+	proc.mu.Lock()
+	proc.phase = PhaseBoot
+	proc.mu.Unlock()
 
 	// Stream logs
-	go streamLogs(stdout, logs, name)
-	go streamLogs(stderr, logs, name)
+	go streamLogs(stdout, proc.logs, proc.Name)
+	go streamLogs(stderr, proc.logs, proc.Name)
 
 	// Monitor for exit
 	go func() {
+		defer close(proc.exited)
 		err := cmd.Wait()
+		oom := proc.cgroup.oomKilled()
+		proc.cgroup.remove()
 		// Write log BEFORE setting failed flag to avoid race condition
 		// where status shows "failed" but logs are empty
-		if err != nil {
+		if oom {
+			proc.logs.Write([]byte(fmt.Sprintf("[roost-dev] Process killed: out of memory (limit %s)\n", formatBytes(proc.opts.Limits.Memory))))
+		} else if err != nil {
 			proc.logs.Write([]byte("[roost-dev] Process exited\n"))
 		}
 		proc.mu.Lock()
-		if err != nil {
-			proc.failed = true
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				proc.exitError = fmt.Sprintf("exit code %d", exitErr.ExitCode())
-			} else {
-				proc.exitError = err.Error()
+		proc.oomKilled = oom
+		proc.mu.Unlock()
+		if hook := proc.opts.Hooks.AfterStop; hook != "" {
+			hookCtx, cancel := context.WithTimeout(context.Background(), afterStopTimeout)
+			if hookErr := proc.runHook(hookCtx, PhaseAfterStop, hook); hookErr != nil {
+				proc.fail(hookError(PhaseAfterStop, hookErr))
 			}
+			cancel()
 		}
-		proc.mu.Unlock()
+		// Free its ports before a restart reserves them again, rather than
+		// whenever the readiness check notices the exit
+		m.mu.Lock()
+		releasePorts()
+		m.mu.Unlock()
 		// Don't delete failed processes so we can show their status
 		// They'll be replaced if started again
-	}()
+		m.handleExit(proc, err)
 
-	proc.starting = true
-	m.processes[name] = proc
-
-	// Release lock BEFORE waiting for port - this can take a while and would block all requests
-	m.mu.Unlock()
+		m.mu.Lock()
+		m.saveState()
+		m.mu.Unlock()
+	}()
 
-	// Wait for port to be ready (keep checking until port ready or process exits)
+	// Check readiness in background (keep checking until ready, timed out or exited)
 	go func() {
 		// Release port reservation when done (process bound or exited)
 		defer func() {
 			m.mu.Lock()
-			m.releasePort(port)
+			releasePorts()
 			m.mu.Unlock()
 		}()
 
+		check := proc.opts.Ready
+		var deadline time.Time
+		if check.Timeout > 0 {
+			deadline = time.Now().Add(check.Timeout)
+		}
+
 		for {
 			// Check if process has exited
-			if proc.cmd.ProcessState != nil {
-				proc.mu.Lock()
-				proc.starting = false
-				proc.mu.Unlock()
+			select {
+			case <-proc.exited:
+				proc.finishStarting("")
 				return
+			default:
 			}
 
-			// Check if port is ready
-			conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), 100*time.Millisecond)
+			err := check.check(proc, logMark)
 			if err == nil {
-				conn.Close()
-				proc.mu.Lock()
-				proc.starting = false
-				proc.mu.Unlock()
+				break
+			}
+
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				msg := fmt.Sprintf("not ready after %s: %v", check.Timeout, err)
+				proc.fail(msg)
+				proc.logs.Write([]byte("[roost-dev] Readiness check failed, " + msg + "\n"))
+				fmt.Printf("[roost-dev] %s %s, stopping\n", proc.Name, msg)
+				proc.Kill()
 				return
 			}
 
-			time.Sleep(500 * time.Millisecond)
+			time.Sleep(readyInterval)
 		}
-	}()
 
-	// Wait up to 30s for initial startup, then return
-	// Process stays in "starting" state until port is actually ready
-	waitForPort(port, 30*time.Second)
+		if hook := proc.opts.Hooks.AfterReady; hook != "" {
+			proc.mu.Lock()
+			proc.phase = PhaseAfterReady
+			proc.mu.Unlock()
+			if err := proc.runHook(ctx, PhaseAfterReady, hook); err != nil {
+				if !proc.isStopped() {
+					proc.fail(hookError(PhaseAfterReady, err))
+					proc.Kill()
+				}
+				return
+			}
+		}
+		proc.finishStarting("")
+	}()
 
-	return proc, nil
+	return nil
 }
 
-// StartAsync starts a process without waiting for the port to be ready.
-// Returns immediately after the process is spawned.
-func (m *Manager) StartAsync(name, command, dir string, env map[string]string) (*Process, error) {
-	m.mu.Lock()
-
-	// Check if already running or starting
-	if p, exists := m.processes[name]; exists && (p.IsRunning() || p.IsStarting()) {
-		m.mu.Unlock()
-		return p, nil
-	}
-
-	// Clean up stale Rails PID file if this looks like a Rails server
-	if strings.Contains(command, "rails server") || strings.Contains(command, "rails s") {
-		cleanupRailsPID(dir)
+// handleExit records how a process exited and applies its restart policy
+func (m *Manager) handleExit(proc *Process, err error) {
+	proc.mu.Lock()
+	if proc.failReason != "" {
+		// Killed because the readiness check timed out or a hook failed
+		proc.exitError = proc.failReason
+		proc.failed = true
+		proc.mu.Unlock()
+		return
 	}
-
-	// Check if working directory exists
-	if dir != "" {
-		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			m.mu.Unlock()
-			return nil, fmt.Errorf("working directory does not exist: %s", dir)
+	if proc.oomKilled {
+		proc.exitError = fmt.Sprintf("out of memory (limit %s)", formatBytes(proc.opts.Limits.Memory))
+		if err == nil {
+			// A child was killed but the process exited cleanly; still a failure
+			err = fmt.Errorf("%s", proc.exitError)
+		}
+	} else if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			proc.exitError = fmt.Sprintf("exit code %d", exitErr.ExitCode())
+		} else {
+			proc.exitError = err.Error()
 		}
 	}
 
-	// Find a free port
-	port, err := m.findFreePort()
-	if err != nil {
-		m.mu.Unlock()
-		return nil, err
+	policy := proc.opts.Restart
+	if proc.stopped || !policy.shouldRestart(err) {
+		proc.failed = err != nil
+		proc.mu.Unlock()
+		return
 	}
-	fmt.Printf("[roost-dev] Starting %s on port %d\n", name, port)
-
-	// Create process
-	ctx, cancel := context.WithCancel(context.Background())
-
-	// Build environment
-	procEnv := os.Environ()
-	procEnv = append(procEnv, fmt.Sprintf("PORT=%d", port))
-	procEnv = append(procEnv, "FORCE_COLOR=1")
-	portStr := fmt.Sprintf("%d", port)
-	for k, v := range env {
-		// Expand $PORT in env values
-		v = strings.ReplaceAll(v, "$PORT", portStr)
-		procEnv = append(procEnv, fmt.Sprintf("%s=%s", k, v))
-	}
-
-	// Parse command (handle shell execution)
-	// Use interactive login shell to ensure user's environment (rvm, rbenv, nvm, etc.) is loaded
-	// -l (login) sources .zprofile; -i (interactive) sources .zshrc/.bashrc
-	shell := getUserShell()
-	cmd := exec.CommandContext(ctx, shell, "-i", "-l", "-c", command)
-	cmd.Dir = dir
-	cmd.Env = procEnv
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-
-	// Set up logging
-	logs := NewLogBuffer(1000)
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		cancel()
-		m.releasePort(port)
-		m.mu.Unlock()
-		return nil, fmt.Errorf("stdout pipe: %w", err)
+	// A process that stayed up for a while has recovered; start counting afresh
+	if time.Since(proc.started) >= crashLoopWindow {
+		proc.crashes = 0
 	}
+	m.countCrash(proc)
+}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		cancel()
-		m.releasePort(port)
-		m.mu.Unlock()
-		return nil, fmt.Errorf("stderr pipe: %w", err)
-	}
+// restartFailed handles an automatic restart that couldn't start the
+// process. It counts as another crash, but the process didn't run again, so
+// how long it was up last time doesn't reset the count.
+func (m *Manager) restartFailed(proc *Process, err error) {
+	proc.mu.Lock()
+	proc.exitError = err.Error()
+	m.countCrash(proc)
+}
 
-	proc := &Process{
-		Name:    name,
-		Command: command,
-		Dir:     dir,
-		Port:    port,
-		Env:     env,
-		cmd:     cmd,
-		cancel:  cancel,
-		logs:    logs,
-		started: time.Now(),
-	}
+// countCrash counts a crash and restarts the process after a delay, or gives
+// up on it once it has crashed too often. Caller must hold proc.mu, which is
+// released.
+func (m *Manager) countCrash(proc *Process) {
+	policy := proc.opts.Restart
+	proc.crashes++
 
-	// Start process
-	if err := cmd.Start(); err != nil {
-		cancel()
-		m.releasePort(port)
-		m.mu.Unlock()
-		return nil, fmt.Errorf("start process: %w", err)
+	reason := proc.exitError
+	if reason == "" {
+		reason = "exit code 0"
+	}
+	maxRetries := policy.maxRetries()
+	if proc.crashes > maxRetries {
+		proc.failed = true
+		proc.crashLooping = true
+		proc.exitError = fmt.Sprintf("crash-looping: %s (gave up after %d restarts)", reason, maxRetries)
+		msg := fmt.Sprintf("[roost-dev] %s keeps crashing, giving up after %d restarts\n", proc.Name, maxRetries)
+		proc.mu.Unlock()
+		proc.logs.Write([]byte(msg))
+		fmt.Print(msg)
+		return
 	}
 
-	// Stream logs
-	go streamLogs(stdout, logs, name)
-	go streamLogs(stderr, logs, name)
+	delay := policy.delay(proc.crashes)
+	proc.restartPending = true
+	stopCh := proc.stopCh
+	msg := fmt.Sprintf("[roost-dev] %s (%s), restarting in %s (attempt %d/%d)\n",
+		proc.Name, reason, delay, proc.crashes, maxRetries)
+	proc.mu.Unlock()
+	proc.logs.Write([]byte(msg))
+	fmt.Print(msg)
 
-	// Monitor for exit
 	go func() {
-		err := cmd.Wait()
-		// Write log BEFORE setting failed flag to avoid race condition
-		// where status shows "failed" but logs are empty
-		if err != nil {
-			proc.logs.Write([]byte("[roost-dev] Process exited\n"))
-		}
-		proc.mu.Lock()
-		if err != nil {
-			proc.failed = true
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				proc.exitError = fmt.Sprintf("exit code %d", exitErr.ExitCode())
-			} else {
-				proc.exitError = err.Error()
-			}
+		select {
+		case <-time.After(delay):
+		case <-stopCh:
+			return
 		}
-		proc.mu.Unlock()
-	}()
 
-	proc.starting = true
-	m.processes[name] = proc
-	m.mu.Unlock()
+		opts := proc.restartOptions()
+		inherited := opts.inheritedEnv()
+		m.mu.Lock()
+		defer m.mu.Unlock()
 
-	// Wait for port in background (keep checking until port ready or process exits)
-	go func() {
-		for {
-			// Check if process has exited
-			if proc.cmd.ProcessState != nil {
-				proc.mu.Lock()
-				proc.starting = false
-				proc.mu.Unlock()
-				return
-			}
-
-			// Check if port is ready
-			conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), 100*time.Millisecond)
-			if err == nil {
-				conn.Close()
-				proc.mu.Lock()
-				proc.starting = false
-				proc.mu.Unlock()
-				return
-			}
+		// Stopped or replaced while we were waiting
+		if current, ok := m.processes[proc.Name]; !ok || current != proc {
+			return
+		}
 
-			time.Sleep(500 * time.Millisecond)
+		// If it can't get its ports back (say an orphan of the crashed
+		// process still holds one), that counts as another crash
+		if _, err := m.spawn(proc.Name, proc.Command, proc.Dir, proc.Env, opts, inherited, proc); err != nil {
+			m.restartFailed(proc, err)
 		}
 	}()
-
-	return proc, nil
 }
 
 // streamLogs reads from a reader and writes to the log buffer
@@ -519,85 +820,84 @@ func streamLogs(r io.Reader, logs *LogBuffer, name string) {
 	}
 }
 
-// cleanupRailsPID removes Rails PID files and kills orphaned processes
-func cleanupRailsPID(dir string) {
-	pidFile := filepath.Join(dir, "tmp", "pids", "server.pid")
-	data, err := os.ReadFile(pidFile)
-	if err != nil {
-		return // No PID file, nothing to clean up
-	}
-
-	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
-	if err != nil {
-		// Invalid PID file, remove it
-		os.Remove(pidFile)
-		fmt.Printf("[roost-dev] Removed invalid PID file: %s\n", pidFile)
-		return
-	}
-
-	// Check if process is still running
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		os.Remove(pidFile)
-		fmt.Printf("[roost-dev] Removed stale PID file (pid %d): %s\n", pid, pidFile)
-		return
-	}
-
-	// On Unix, FindProcess always succeeds, so we need to send signal 0 to check
-	err = process.Signal(syscall.Signal(0))
-	if err != nil {
-		// Process doesn't exist, remove the stale PID file
-		os.Remove(pidFile)
-		fmt.Printf("[roost-dev] Removed stale PID file (pid %d not running): %s\n", pid, pidFile)
-		return
-	}
-
-	// Process IS running - this is likely an orphaned process from a previous roost-dev
-	// Kill it so we can start fresh
-	fmt.Printf("[roost-dev] Killing orphaned Rails process (pid %d)\n", pid)
-	process.Signal(syscall.SIGTERM)
-	time.Sleep(100 * time.Millisecond)
-	process.Signal(syscall.SIGKILL)
-	os.Remove(pidFile)
-}
-
-// waitForPort waits for a port to become available
-func waitForPort(port int, timeout time.Duration) error {
+// waitReady waits until the process is ready, has failed or exited, or the timeout expires
+func (p *Process) waitReady(timeout time.Duration) {
 	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), 100*time.Millisecond)
-		if err == nil {
-			conn.Close()
-			return nil
-		}
+	for time.Now().Before(deadline) && p.IsStarting() {
 		time.Sleep(100 * time.Millisecond)
 	}
-	return fmt.Errorf("timeout waiting for port %d", port)
 }
 
-// Stop stops a process
+// Stop stops a process and its file watcher
 func (m *Manager) Stop(name string) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.unwatch(name)
+	m.mu.Unlock()
+	return m.stop(name)
+}
 
+// stop stops a process, leaving its file watcher running for a restart
+func (m *Manager) stop(name string) error {
+	m.mu.Lock()
 	proc, exists := m.processes[name]
 	if !exists {
+		m.mu.Unlock()
 		return fmt.Errorf("process not found: %s", name)
 	}
+	delete(m.processes, name)
+	delete(m.idleStopped, name)
+	m.saveState()
+	m.mu.Unlock()
 
+	// Kill outside the lock since a graceful stop can take a while
 	proc.Kill()
+	return nil
+}
+
+// StopIdle stops a process that has not received requests for a while.
+// Unlike Stop, it remembers why so status can report "stopped (idle)".
+func (m *Manager) StopIdle(name string) error {
+	m.mu.Lock()
+	proc, exists := m.processes[name]
+	if !exists {
+		m.mu.Unlock()
+		return fmt.Errorf("process not found: %s", name)
+	}
 	delete(m.processes, name)
+	m.idleStopped[name] = true
+	m.unwatch(name)
+	m.saveState()
+	m.mu.Unlock()
+
+	fmt.Printf("[roost-dev] Stopping %s (idle since %s)\n", name, proc.LastRequest().Format("15:04:05"))
+	proc.Kill()
 	return nil
 }
 
-// Kill terminates the process and all its children
+// IsIdleStopped returns true if the process was stopped for inactivity
+// and has not been started since
+func (m *Manager) IsIdleStopped(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.idleStopped[name]
+}
+
+// Kill terminates the process and all its children. It runs the stop command
+// (or sends the stop signal) to the process group, waits up to the stop
+// timeout for the group to exit, and only then escalates to SIGKILL.
 func (p *Process) Kill() {
 	p.mu.Lock()
+	if !p.stopped && p.stopCh != nil {
+		close(p.stopCh)
+	}
+	p.stopped = true
 	var pid int
 	var pgid int
 	var hasPid bool
 	var hasPgid bool
+	var env []string
 	if p.cmd != nil && p.cmd.Process != nil {
+		env = p.cmd.Env
 		pid = p.cmd.Process.Pid
 		hasPid = true
 		var err error
@@ -610,54 +910,78 @@ func (p *Process) Kill() {
 	}
 	p.mu.Unlock()
 
+	// Note the whole tree first, since children are reparented once their
+	// parent exits and can't be found from it any more
+	var tree *ProcTree
+	if hasPid {
+		if table, err := snapshotProcs(); err == nil {
+			tree = table.tree(pid)
+		}
+	}
+
+	sig := p.opts.stopSignal()
+	timeout := p.opts.stopTimeout()
+
 	if hasPgid {
-		// Kill the entire process group
-		fmt.Printf("[roost-dev] Kill %s: sending SIGTERM to process group -%d\n", p.Name, pgid)
-		if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
-			fmt.Printf("[roost-dev] Kill %s: SIGTERM to group -%d failed: %v\n", p.Name, pgid, err)
+		// Ask the entire process group to stop
+		if p.opts.StopCommand == "" || !p.runStopCommand(env, timeout) {
+			fmt.Printf("[roost-dev] Kill %s: sending %s to process group -%d\n", p.Name, signalName(sig), pgid)
+			if err := syscall.Kill(-pgid, sig); err != nil {
+				fmt.Printf("[roost-dev] Kill %s: %s to group -%d failed: %v\n", p.Name, signalName(sig), pgid, err)
+			}
 		}
-		// Give it a moment to clean up, then force kill
-		time.Sleep(100 * time.Millisecond)
-		fmt.Printf("[roost-dev] Kill %s: sending SIGKILL to process group -%d\n", p.Name, pgid)
-		if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil {
-			fmt.Printf("[roost-dev] Kill %s: SIGKILL to group -%d failed: %v\n", p.Name, pgid, err)
+		// Give it until the timeout to exit, then force kill
+		if !waitForExit(-pgid, timeout) {
+			fmt.Printf("[roost-dev] Kill %s: still running after %s, sending SIGKILL to process group -%d\n", p.Name, timeout, pgid)
+			if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil {
+				fmt.Printf("[roost-dev] Kill %s: SIGKILL to group -%d failed: %v\n", p.Name, pgid, err)
+			}
 		}
 	} else if hasPid {
 		// Fallback: kill by PID if we couldn't get PGID
 		fmt.Printf("[roost-dev] Kill %s: falling back to PID kill for %d\n", p.Name, pid)
-		syscall.Kill(pid, syscall.SIGTERM)
-		time.Sleep(100 * time.Millisecond)
-		syscall.Kill(pid, syscall.SIGKILL)
+		syscall.Kill(pid, sig)
+		if !waitForExit(pid, timeout) {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
 	}
 
-	// Also kill any child processes we can find (belt and suspenders)
-	if hasPid {
-		killChildProcesses(pid, p.Name)
+	// Also kill anything in the tree that left the process group (belt and
+	// suspenders), checked against a fresh snapshot now the group is gone
+	if tree != nil {
+		killChildProcesses(tree, p.Name)
 	}
 
 	p.cancel()
+
+	// Let the after_stop hook finish so a restart doesn't race its clean-up
+	if hasPid && p.opts.Hooks.AfterStop != "" && p.exited != nil {
+		<-p.exited
+	}
 }
 
-// killChildProcesses finds and kills all child processes of the given PID
-func killChildProcesses(parentPid int, name string) {
-	// Use pgrep to find children (works on macOS and Linux)
-	cmd := exec.Command("pgrep", "-P", strconv.Itoa(parentPid))
-	output, err := cmd.Output()
+// killChildProcesses kills whatever is still running from a process tree
+// noted before its group was stopped, children before their parents. By now
+// pids may have been reused, so only processes with the same parent and
+// group as in the tree, or roost-dev's marker for name, are killed.
+func killChildProcesses(tree *ProcTree, name string) {
+	table, err := snapshotProcs()
 	if err != nil {
-		// No children found or pgrep failed - that's fine
 		return
 	}
-
-	childPids := strings.Fields(string(output))
-	for _, pidStr := range childPids {
-		childPid, err := strconv.Atoi(pidStr)
-		if err != nil {
-			continue
+	noted := make(map[int]ProcInfo)
+	tree.Walk(func(p *ProcTree, depth int) {
+		noted[p.PID] = p.ProcInfo
+	})
+	for _, childPid := range tree.pids() {
+		current, running := table[childPid]
+		if childPid == tree.PID || !running {
+			continue // The main process is handled by Kill; others already exited
+		}
+		if (current.PPID != noted[childPid].PPID || current.PGID != noted[childPid].PGID) && !hasMarker(childPid, name) {
+			continue // Exited, and the pid now belongs to something else
 		}
 		fmt.Printf("[roost-dev] Kill %s: killing orphaned child PID %d\n", name, childPid)
-		// Recursively kill grandchildren first
-		killChildProcesses(childPid, name)
-		// Then kill this child
 		syscall.Kill(childPid, syscall.SIGTERM)
 		time.Sleep(50 * time.Millisecond)
 		syscall.Kill(childPid, syscall.SIGKILL)
@@ -678,15 +1002,16 @@ func (m *Manager) Restart(name string) (*Process, error) {
 	command := proc.Command
 	dir := proc.Dir
 	env := proc.Env
+	opts := proc.restartOptions()
 
 	// Stop
-	m.Stop(name)
+	m.stop(name)
 
 	// Brief wait for port release
 	time.Sleep(100 * time.Millisecond)
 
 	// Start again
-	return m.Start(name, command, dir, env)
+	return m.StartWithOptions(name, command, dir, env, opts)
 }
 
 // RestartAsync restarts a process without blocking
@@ -703,17 +1028,28 @@ func (m *Manager) RestartAsync(name string) {
 	command := proc.Command
 	dir := proc.Dir
 	env := proc.Env
+	opts := proc.restartOptions()
 
 	// Stop
-	m.Stop(name)
+	m.stop(name)
 
 	// Start again asynchronously after brief delay
 	go func() {
 		time.Sleep(100 * time.Millisecond)
-		m.Start(name, command, dir, env)
+		if _, err := m.StartWithOptions(name, command, dir, env, opts); err != nil {
+			fmt.Printf("[roost-dev] Failed to restart %s: %v\n", name, err)
+		}
 	}()
 }
 
+// restartOptions returns the options to start p again with: the ones it was
+// started with, on the ports it got, which its env and exec args refer to
+func (p *Process) restartOptions() Options {
+	opts := p.opts
+	opts.Port, opts.Ports = p.Port, p.Ports
+	return opts
+}
+
 // Get returns a process by name
 func (m *Manager) Get(name string) (*Process, bool) {
 	m.mu.RLock()
@@ -737,22 +1073,111 @@ func (m *Manager) All() []*Process {
 // StopAll stops all running processes
 func (m *Manager) StopAll() {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	procs := m.processes
+	m.processes = make(map[string]*Process)
+	for name := range m.watchers {
+		m.unwatch(name)
+	}
+	m.saveState()
+	m.mu.Unlock()
 
-	if len(m.processes) == 0 {
+	if len(procs) == 0 {
 		fmt.Println("[roost-dev] StopAll: no processes to stop")
 		return
 	}
 
-	fmt.Printf("[roost-dev] StopAll: stopping %d processes\n", len(m.processes))
-	for name, proc := range m.processes {
-		fmt.Printf("[roost-dev] StopAll: stopping %s\n", name)
-		proc.Kill()
-		delete(m.processes, name)
+	// Stop dependents before their dependencies, and each wave in parallel
+	// so shutdown takes as long as the slowest process at each level, not
+	// the sum of every grace period
+	fmt.Printf("[roost-dev] StopAll: stopping %d processes\n", len(procs))
+	for _, wave := range stopWaves(procs) {
+		var wg sync.WaitGroup
+		for _, name := range wave {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fmt.Printf("[roost-dev] StopAll: stopping %s\n", name)
+				procs[name].Kill()
+			}()
+		}
+		wg.Wait()
 	}
 	fmt.Println("[roost-dev] StopAll: all processes stopped")
 }
 
+// StopGroup stops the named processes that exist, like Stop, with
+// dependents stopping before the processes they depend on
+func (m *Manager) StopGroup(names []string) {
+	m.stopGroup(names, m.stop)
+}
+
+// StopIdleGroup is StopGroup for processes stopped for inactivity, which
+// IsIdleStopped reports as it does after StopIdle
+func (m *Manager) StopIdleGroup(names []string) {
+	m.stopGroup(names, m.StopIdle)
+}
+
+// stopGroup stops the named processes that exist with stop, in stopWaves
+// order, after stopping their file watchers
+func (m *Manager) stopGroup(names []string, stop func(name string) error) {
+	m.mu.Lock()
+	procs := make(map[string]*Process, len(names))
+	for _, name := range names {
+		if proc, exists := m.processes[name]; exists {
+			procs[name] = proc
+		}
+		m.unwatch(name)
+	}
+	m.mu.Unlock()
+
+	for _, wave := range stopWaves(procs) {
+		var wg sync.WaitGroup
+		for _, name := range wave {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				stop(name)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// stopWaves orders processes for stopping in waves, each holding the ones no
+// process still to stop depends on (Options.DependsOn). A dependency cycle,
+// which config loading rejects, would end up in one last wave.
+func stopWaves(procs map[string]*Process) [][]string {
+	remaining := make(map[string]bool, len(procs))
+	for name := range procs {
+		remaining[name] = true
+	}
+
+	var waves [][]string
+	for len(remaining) > 0 {
+		needed := make(map[string]bool)
+		for name := range remaining {
+			for _, dep := range procs[name].opts.DependsOn {
+				needed[dep] = true
+			}
+		}
+		var wave []string
+		for name := range remaining {
+			if !needed[name] {
+				wave = append(wave, name)
+			}
+		}
+		if len(wave) == 0 {
+			wave = slices.Collect(maps.Keys(remaining))
+		}
+		slices.Sort(wave)
+		for _, name := range wave {
+			delete(remaining, name)
+		}
+		waves = append(waves, wave)
+	}
+	return waves
+}
+
 // IsRunning returns true if the process is still running
 func (p *Process) IsRunning() bool {
 	p.mu.Lock()
@@ -768,7 +1193,7 @@ func (p *Process) IsRunning() bool {
 	}
 
 	// Check if process has exited
-	if p.cmd.ProcessState != nil {
+	if p.cmd.ProcessState != nil || p.gone {
 		return false
 	}
 
@@ -786,6 +1211,21 @@ func (p *Process) Uptime() time.Duration {
 	return time.Since(p.started)
 }
 
+// Touch records that a request was just proxied to the process
+func (p *Process) Touch() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastRequest = time.Now()
+}
+
+// LastRequest returns when a request was last proxied to the process
+// (or when it started, if it has not received any)
+func (p *Process) LastRequest() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastRequest
+}
+
 // HasFailed returns true if the process exited with an error
 func (p *Process) HasFailed() bool {
 	p.mu.Lock()
@@ -793,16 +1233,41 @@ func (p *Process) HasFailed() bool {
 	return p.failed
 }
 
-// IsStarting returns true if the process is starting but port not yet ready
+// IsStarting returns true if the process is starting but port not yet ready,
+// including while it waits to be restarted after a crash
 func (p *Process) IsStarting() bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	return p.starting && !p.failed
+	return (p.starting || p.restartPending) && !p.failed
+}
+
+// IsCrashLooping returns true if the restart policy gave up after repeated crashes
+func (p *Process) IsCrashLooping() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.crashLooping
 }
 
-// ExitError returns the exit error message if the process failed
+// Restarts returns how many times the process was restarted automatically
+func (p *Process) Restarts() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.restarts
+}
+
+// ExitError returns the exit error message if the process failed. A process
+// killed for going over its memory limit reports "out of memory (limit ...)"
+// instead of its exit code.
 func (p *Process) ExitError() string {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	return p.exitError
 }
+
+// OOMKilled returns true if the process (or one of its children) was killed
+// for going over its memory limit
+func (p *Process) OOMKilled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.oomKilled
+}
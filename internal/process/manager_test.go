@@ -1,8 +1,20 @@
 package process
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -433,3 +445,1727 @@ func TestPortReservation(t *testing.T) {
 		}
 	})
 }
+
+func TestRestartPolicy(t *testing.T) {
+	t.Run("delay doubles up to max backoff", func(t *testing.T) {
+		p := RestartPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+		want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+		for i, w := range want {
+			if got := p.delay(i + 1); got != w {
+				t.Errorf("delay(%d) = %v, want %v", i+1, got, w)
+			}
+		}
+	})
+
+	t.Run("shouldRestart follows mode", func(t *testing.T) {
+		exitErr := fmt.Errorf("exit code 1")
+		tests := []struct {
+			mode string
+			err  error
+			want bool
+		}{
+			{"", exitErr, false},
+			{RestartNever, exitErr, false},
+			{RestartOnFailure, exitErr, true},
+			{RestartOnFailure, nil, false},
+			{RestartAlways, nil, true},
+		}
+		for _, tc := range tests {
+			if got := (RestartPolicy{Mode: tc.mode}).shouldRestart(tc.err); got != tc.want {
+				t.Errorf("shouldRestart(mode=%q, err=%v) = %v, want %v", tc.mode, tc.err, got, tc.want)
+			}
+		}
+	})
+
+	t.Run("crashing process is restarted then marked crash-looping", func(t *testing.T) {
+		m := NewManager()
+		opts := Options{Restart: RestartPolicy{Mode: RestartOnFailure, MaxRetries: 2, Backoff: 10 * time.Millisecond}}
+		_, err := m.StartAsyncWithOptions("test-crash", "exit 3", "/tmp", nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsyncWithOptions failed: %v", err)
+		}
+		defer m.Stop("test-crash")
+
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			if proc, found := m.Get("test-crash"); found && proc.IsCrashLooping() {
+				if proc.Restarts() != 2 {
+					t.Errorf("expected 2 restarts, got %d", proc.Restarts())
+				}
+				if !proc.HasFailed() {
+					t.Error("expected crash-looping process to be failed")
+				}
+				if !strings.Contains(proc.ExitError(), "crash-looping") {
+					t.Errorf("expected crash-looping error, got %q", proc.ExitError())
+				}
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Error("expected process to be marked crash-looping")
+	})
+
+	t.Run("restart waits for the port its env was filled in with", func(t *testing.T) {
+		m := NewManager()
+		dir := t.TempDir()
+		opts := Options{
+			Ready:   ReadyCheck{Type: ReadyNone},
+			Restart: RestartPolicy{Mode: RestartOnFailure, MaxRetries: 5, Backoff: 300 * time.Millisecond},
+		}
+		proc, err := m.StartAsyncWithOptions("test-sameport", "[ -f crashed ] && exec sleep 30; touch crashed; exit 1", dir, nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsyncWithOptions failed: %v", err)
+		}
+		defer m.Stop("test-sameport")
+		port := proc.Port
+
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			proc.mu.Lock()
+			pending := proc.restartPending
+			proc.mu.Unlock()
+			if pending {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		// Something else takes the port before the restart
+		ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			t.Fatalf("listen on %d: %v", port, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+		if current, _ := m.Get("test-sameport"); current != proc {
+			t.Fatalf("expected no restart while port %d is in use, got one on port %d", port, current.Port)
+		}
+		if !strings.Contains(proc.ExitError(), "in use") {
+			t.Errorf("expected the busy port to be the exit error, got %q", proc.ExitError())
+		}
+
+		ln.Close()
+		deadline = time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			if current, _ := m.Get("test-sameport"); current != proc {
+				if current.Port != port {
+					t.Errorf("expected restart on port %d, got %d", port, current.Port)
+				}
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Error("expected process to be restarted once the port was free")
+	})
+
+	t.Run("failed restarts of a long-running process count towards crash-looping", func(t *testing.T) {
+		m := NewManager()
+		opts := Options{
+			Ready:   ReadyCheck{Type: ReadyNone},
+			Restart: RestartPolicy{Mode: RestartOnFailure, MaxRetries: 2, Backoff: 10 * time.Millisecond},
+		}
+		proc, err := m.StartAsyncWithOptions("test-longrun", "sleep 1; exit 1", "/tmp", nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsyncWithOptions failed: %v", err)
+		}
+		defer m.Stop("test-longrun")
+		// It has been up for a while, and something holds its port when it
+		// crashes
+		proc.mu.Lock()
+		proc.started = time.Now().Add(-2 * crashLoopWindow)
+		proc.mu.Unlock()
+		ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", proc.Port))
+		if err != nil {
+			t.Fatalf("listen on %d: %v", proc.Port, err)
+		}
+		defer ln.Close()
+
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) && !proc.IsCrashLooping() {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if !proc.IsCrashLooping() {
+			t.Fatalf("expected process to be marked crash-looping, got %q", proc.ExitError())
+		}
+		if !strings.Contains(proc.ExitError(), "in use") {
+			t.Errorf("expected the busy port in the exit error, got %q", proc.ExitError())
+		}
+	})
+
+	t.Run("never policy leaves failed process alone", func(t *testing.T) {
+		m := NewManager()
+		proc, err := m.StartAsync("test-never", "exit 3", "/tmp", nil)
+		if err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		defer m.Stop("test-never")
+
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) && !proc.HasFailed() {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if !proc.HasFailed() {
+			t.Fatal("expected process to fail")
+		}
+		time.Sleep(200 * time.Millisecond)
+		if current, _ := m.Get("test-never"); current != proc || proc.Restarts() != 0 {
+			t.Error("expected process not to be restarted")
+		}
+	})
+
+	t.Run("stop cancels a pending restart", func(t *testing.T) {
+		m := NewManager()
+		opts := Options{Restart: RestartPolicy{Mode: RestartAlways, Backoff: time.Second}}
+		proc, err := m.StartAsyncWithOptions("test-cancel", "exit 0", "/tmp", nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsyncWithOptions failed: %v", err)
+		}
+
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) && proc.cmd.ProcessState == nil {
+			time.Sleep(50 * time.Millisecond)
+		}
+		m.Stop("test-cancel")
+		time.Sleep(1500 * time.Millisecond)
+
+		if _, found := m.Get("test-cancel"); found {
+			t.Error("expected stopped process not to be restarted")
+		}
+	})
+}
+
+func TestStopIdle(t *testing.T) {
+	t.Run("marks process as idle-stopped until started again", func(t *testing.T) {
+		m := NewManager()
+		if _, err := m.StartAsync("test-idle", "sleep 10", "/tmp", nil); err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+
+		if err := m.StopIdle("test-idle"); err != nil {
+			t.Fatalf("StopIdle failed: %v", err)
+		}
+		if _, found := m.Get("test-idle"); found {
+			t.Error("expected process to be removed after StopIdle")
+		}
+		if !m.IsIdleStopped("test-idle") {
+			t.Error("expected process to be marked as idle-stopped")
+		}
+
+		if _, err := m.StartAsync("test-idle", "sleep 10", "/tmp", nil); err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		defer m.Stop("test-idle")
+		if m.IsIdleStopped("test-idle") {
+			t.Error("expected idle-stopped mark to be cleared on start")
+		}
+	})
+
+	t.Run("Touch updates last request time", func(t *testing.T) {
+		p := &Process{}
+		before := time.Now()
+		p.Touch()
+		if p.LastRequest().Before(before) {
+			t.Error("expected LastRequest to be updated by Touch")
+		}
+	})
+}
+
+// waitForLog polls until a process has logged a line containing want
+func waitForLog(t *testing.T, proc *Process, want string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, line := range proc.Logs().Lines() {
+			if strings.Contains(line, want) {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %q in logs: %v", want, proc.Logs().Lines())
+}
+
+func TestGracefulStop(t *testing.T) {
+	t.Run("sends configured stop signal and returns once the group exits", func(t *testing.T) {
+		dir := t.TempDir()
+		m := NewManager()
+		opts := Options{StopSignal: syscall.SIGINT, StopTimeout: 5 * time.Second}
+		proc, err := m.StartAsyncWithOptions("test-sigint", "trap 'touch got-int; exit 0' INT; echo ready; while true; do sleep 0.05; done", dir, nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		waitForLog(t, proc, "ready")
+
+		start := time.Now()
+		m.Stop("test-sigint")
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("expected stop to return before the timeout, took %v", elapsed)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "got-int")); err != nil {
+			t.Error("expected process to handle SIGINT")
+		}
+	})
+
+	t.Run("escalates to SIGKILL after stop timeout", func(t *testing.T) {
+		m := NewManager()
+		opts := Options{StopTimeout: 300 * time.Millisecond}
+		proc, err := m.StartAsyncWithOptions("test-ignore", "trap '' TERM; echo ready; while true; do sleep 0.05; done", "/tmp", nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		waitForLog(t, proc, "ready")
+		pid := proc.cmd.Process.Pid
+
+		start := time.Now()
+		m.Stop("test-ignore")
+		if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+			t.Errorf("expected stop to wait for the timeout, took %v", elapsed)
+		}
+		if !waitForExit(-pid, time.Second) {
+			t.Error("expected process group to be gone after SIGKILL")
+		}
+	})
+
+	t.Run("runs stop command instead of sending a signal", func(t *testing.T) {
+		dir := t.TempDir()
+		m := NewManager()
+		opts := Options{StopCommand: "echo stopping; touch stop-requested", StopTimeout: 20 * time.Second}
+		proc, err := m.StartAsyncWithOptions("test-stopcmd", "echo ready; while [ ! -f stop-requested ]; do sleep 0.05; done", dir, nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		waitForLog(t, proc, "ready")
+
+		start := time.Now()
+		m.Stop("test-stopcmd")
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("expected stop command to end the process before the timeout, took %v", elapsed)
+		}
+		waitForLog(t, proc, "stopping")
+	})
+
+	t.Run("StopAll stops processes in parallel", func(t *testing.T) {
+		m := NewManager()
+		opts := Options{StopTimeout: 400 * time.Millisecond}
+		for _, name := range []string{"test-slow-a", "test-slow-b", "test-slow-c"} {
+			proc, err := m.StartAsyncWithOptions(name, "trap '' TERM; echo ready; while true; do sleep 0.05; done", "/tmp", nil, opts)
+			if err != nil {
+				t.Fatalf("StartAsync failed: %v", err)
+			}
+			waitForLog(t, proc, "ready")
+		}
+
+		start := time.Now()
+		m.StopAll()
+		if elapsed := time.Since(start); elapsed > 1100*time.Millisecond {
+			t.Errorf("expected grace periods to overlap, took %v", elapsed)
+		}
+		if len(m.All()) != 0 {
+			t.Error("expected no processes after StopAll")
+		}
+	})
+}
+
+func TestReadyCheck(t *testing.T) {
+	t.Run("http check compares status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/up" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+		port := srv.Listener.Addr().(*net.TCPAddr).Port
+		p := &Process{Port: port, logs: NewLogBuffer(10)}
+
+		if err := (ReadyCheck{Type: ReadyHTTP, Path: "/up", Status: 200}).check(p, 0); err != nil {
+			t.Errorf("expected /up to be ready, got %v", err)
+		}
+		if err := (ReadyCheck{Type: ReadyHTTP, Path: "/booting"}).check(p, 0); err == nil {
+			t.Error("expected 503 to not be ready")
+		}
+		if err := (ReadyCheck{Type: ReadyHTTP, Path: "/up", Status: 204}).check(p, 0); err == nil {
+			t.Error("expected unexpected status to not be ready")
+		}
+	})
+
+	t.Run("tcp check dials the configured port", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		defer ln.Close()
+		p := &Process{Port: 1, logs: NewLogBuffer(10)}
+
+		if err := (ReadyCheck{Port: ln.Addr().(*net.TCPAddr).Port}).check(p, 0); err != nil {
+			t.Errorf("expected listening port to be ready, got %v", err)
+		}
+	})
+
+	t.Run("file check requires a file touched after start", func(t *testing.T) {
+		dir := t.TempDir()
+		stale := filepath.Join(dir, "stale")
+		os.WriteFile(stale, nil, 0644)
+		old := time.Now().Add(-time.Hour)
+		os.Chtimes(stale, old, old)
+		p := &Process{Dir: dir, started: time.Now(), logs: NewLogBuffer(10)}
+
+		if err := (ReadyCheck{Type: ReadyFile, Path: "ready"}).check(p, 0); err == nil {
+			t.Error("expected missing file to not be ready")
+		}
+		if err := (ReadyCheck{Type: ReadyFile, Path: "stale"}).check(p, 0); err == nil {
+			t.Error("expected stale file to not be ready")
+		}
+		os.WriteFile(filepath.Join(dir, "ready"), nil, 0644)
+		if err := (ReadyCheck{Type: ReadyFile, Path: "ready"}).check(p, 0); err != nil {
+			t.Errorf("expected touched file to be ready, got %v", err)
+		}
+	})
+
+	t.Run("exec check runs command in process dir", func(t *testing.T) {
+		dir := t.TempDir()
+		p := &Process{Dir: dir, cmd: exec.Command("true"), logs: NewLogBuffer(10), opts: Options{Shell: "/bin/sh"}}
+		check := ReadyCheck{Type: ReadyExec, Command: "test -f ready"}
+
+		if err := check.check(p, 0); err == nil {
+			t.Error("expected failing command to not be ready")
+		}
+		os.WriteFile(filepath.Join(dir, "ready"), nil, 0644)
+		if err := check.check(p, 0); err != nil {
+			t.Errorf("expected succeeding command to be ready, got %v", err)
+		}
+	})
+
+	t.Run("exec check runs under the process's shell", func(t *testing.T) {
+		dir := t.TempDir()
+		shell := filepath.Join(dir, "shell")
+		os.WriteFile(shell, []byte("#!/bin/sh\ntouch \"$(dirname \"$0\")/used\"\nexec /bin/sh \"$@\"\n"), 0755)
+		p := &Process{Dir: dir, cmd: exec.Command("true"), logs: NewLogBuffer(10), opts: Options{Shell: shell}}
+
+		if err := (ReadyCheck{Type: ReadyExec, Command: "true"}).check(p, 0); err != nil {
+			t.Fatalf("expected succeeding command to be ready, got %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "used")); err != nil {
+			t.Error("expected the check to run under the configured shell")
+		}
+	})
+
+	t.Run("log check only matches lines after the mark", func(t *testing.T) {
+		logs := NewLogBuffer(10)
+		logs.Write([]byte("Listening on 3000\n"))
+		p := &Process{logs: logs}
+		check := ReadyCheck{Type: ReadyLog, Pattern: regexp.MustCompile(`Listening on \d+`)}
+
+		mark := logs.Mark()
+		if err := check.check(p, mark); err == nil {
+			t.Error("expected line from a previous run to not count")
+		}
+		logs.Write([]byte("\x1b[32mListening on\x1b[0m 4000\n"))
+		if err := check.check(p, mark); err != nil {
+			t.Errorf("expected colored line to match, got %v", err)
+		}
+	})
+
+	t.Run("process becomes running once log pattern appears", func(t *testing.T) {
+		m := NewManager()
+		opts := Options{Ready: ReadyCheck{Type: ReadyLog, Pattern: regexp.MustCompile("booted")}}
+		proc, err := m.StartAsyncWithOptions("test-logready", "echo booted; sleep 10", "/tmp", nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		defer m.Stop("test-logready")
+
+		deadline := time.Now().Add(10 * time.Second)
+		for !proc.IsRunning() && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if !proc.IsRunning() {
+			t.Error("expected process to be running after log line")
+		}
+	})
+
+	t.Run("readiness timeout marks process failed", func(t *testing.T) {
+		m := NewManager()
+		opts := Options{
+			Ready:       ReadyCheck{Type: ReadyFile, Path: "never-created", Timeout: 300 * time.Millisecond},
+			StopTimeout: 100 * time.Millisecond,
+		}
+		proc, err := m.StartAsyncWithOptions("test-notready", "sleep 10", t.TempDir(), nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		defer m.Stop("test-notready")
+
+		deadline := time.Now().Add(5 * time.Second)
+		for !proc.HasFailed() && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if !proc.HasFailed() {
+			t.Fatal("expected process to fail after readiness timeout")
+		}
+		if !strings.Contains(proc.ExitError(), "not ready after 300ms") {
+			t.Errorf("expected readiness error, got %q", proc.ExitError())
+		}
+	})
+}
+
+func TestLogBufferMark(t *testing.T) {
+	buf := NewLogBuffer(3)
+	buf.Write([]byte("a\nb\n"))
+	mark := buf.Mark()
+	buf.Write([]byte("c\nd\ne\n"))
+
+	lines := buf.LinesSince(mark)
+	if strings.Join(lines, ",") != "c,d,e" {
+		t.Errorf("expected lines after mark, got %v", lines)
+	}
+	if got := buf.LinesSince(buf.Mark()); len(got) != 0 {
+		t.Errorf("expected no lines after latest mark, got %v", got)
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	t.Run("rotates and keeps a limited number of backups", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "web-myapp.log")
+		lf, err := OpenLogFile(path)
+		if err != nil {
+			t.Fatalf("OpenLogFile failed: %v", err)
+		}
+		defer lf.Close()
+		lf.maxSize = 100
+
+		for i := 0; i < 50; i++ {
+			lf.WriteLine(time.Now(), fmt.Sprintf("line %d", i))
+		}
+
+		for i := 1; i <= maxLogBackups; i++ {
+			if _, err := os.Stat(fmt.Sprintf("%s.%d", path, i)); err != nil {
+				t.Errorf("expected backup %d to exist", i)
+			}
+		}
+		if _, err := os.Stat(fmt.Sprintf("%s.%d", path, maxLogBackups+1)); err == nil {
+			t.Error("expected backups beyond the limit to be removed")
+		}
+
+		lines, err := ReadLogFile(path, LogQuery{})
+		if err != nil {
+			t.Fatalf("ReadLogFile failed: %v", err)
+		}
+		if !strings.HasSuffix(lines[len(lines)-1], " line 49") {
+			t.Errorf("expected newest line last, got %q", lines[len(lines)-1])
+		}
+	})
+
+	t.Run("filters by time and previous run", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "web-myapp.log")
+		lf, err := OpenLogFile(path)
+		if err != nil {
+			t.Fatalf("OpenLogFile failed: %v", err)
+		}
+		defer lf.Close()
+
+		base := time.Now().Add(-time.Hour)
+		lf.WriteLine(base, runMarker+"web-myapp on port 50001")
+		lf.WriteLine(base.Add(time.Minute), "booting")
+		lf.WriteLine(base.Add(2*time.Minute), "panic: boom")
+		lf.WriteLine(base.Add(30*time.Minute), runMarker+"web-myapp on port 50002")
+		lf.WriteLine(base.Add(31*time.Minute), "booting again")
+
+		lines, _ := ReadLogFile(path, LogQuery{BeforeRestart: true})
+		if len(lines) != 3 || !strings.HasSuffix(lines[2], "panic: boom") {
+			t.Errorf("expected the crashed run, got %v", lines)
+		}
+
+		lines, _ = ReadLogFile(path, LogQuery{Since: base.Add(10 * time.Minute)})
+		if len(lines) != 2 || !strings.HasSuffix(lines[1], "booting again") {
+			t.Errorf("expected lines since the restart, got %v", lines)
+		}
+	})
+
+	t.Run("manager writes process output to log dir", func(t *testing.T) {
+		dir := t.TempDir()
+		m := NewManager()
+		m.SetLogDir(dir)
+		proc, err := m.StartAsync("test-logfile", "echo to-disk; sleep 10", "/tmp", nil)
+		if err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		defer m.Stop("test-logfile")
+		waitForLog(t, proc, "to-disk")
+
+		lines, err := ReadLogFile(m.LogPath("test-logfile"), LogQuery{})
+		if err != nil {
+			t.Fatalf("ReadLogFile failed: %v", err)
+		}
+		joined := strings.Join(lines, "\n")
+		if !strings.Contains(joined, runMarker+"test-logfile") || !strings.Contains(joined, "to-disk") {
+			t.Errorf("expected run marker and output in log file, got %v", lines)
+		}
+	})
+}
+
+// freePort returns a port that nothing is listening on
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func TestStickyPorts(t *testing.T) {
+	t.Run("reuses last port across managers", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ports.json")
+		m1 := NewManager()
+		if err := m1.SetPortsFile(path); err != nil {
+			t.Fatalf("SetPortsFile failed: %v", err)
+		}
+		port, err := m1.allocatePort("web-myapp", 0)
+		if err != nil {
+			t.Fatalf("allocatePort failed: %v", err)
+		}
+
+		m2 := NewManager()
+		if err := m2.SetPortsFile(path); err != nil {
+			t.Fatalf("SetPortsFile failed: %v", err)
+		}
+		got, err := m2.allocatePort("web-myapp", 0)
+		if err != nil {
+			t.Fatalf("allocatePort failed: %v", err)
+		}
+		if got != port {
+			t.Errorf("expected sticky port %d, got %d", port, got)
+		}
+	})
+
+	t.Run("prefers configured port when free", func(t *testing.T) {
+		m := NewManager()
+		preferred := freePort(t)
+		got, err := m.allocatePort("web-myapp", preferred)
+		if err != nil {
+			t.Fatalf("allocatePort failed: %v", err)
+		}
+		if got != preferred {
+			t.Errorf("expected preferred port %d, got %d", preferred, got)
+		}
+	})
+
+	t.Run("falls back when last port is taken", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		defer ln.Close()
+		busy := ln.Addr().(*net.TCPAddr).Port
+
+		m := NewManager()
+		m.stickyPorts["web-myapp"] = busy
+		got, err := m.allocatePort("web-myapp", 0)
+		if err != nil {
+			t.Fatalf("allocatePort failed: %v", err)
+		}
+		if got == busy {
+			t.Error("expected a different port while the last one is in use")
+		}
+		if m.stickyPorts["web-myapp"] != got {
+			t.Errorf("expected new port to be remembered, got %d", m.stickyPorts["web-myapp"])
+		}
+	})
+
+	t.Run("reserves distinct sticky named ports", func(t *testing.T) {
+		m := NewManager()
+		main, err := m.allocatePort("web-myapp", 0)
+		if err != nil {
+			t.Fatalf("allocatePort failed: %v", err)
+		}
+		ports, err := m.allocateNamedPorts("web-myapp", []string{"livereload", "debug"})
+		if err != nil {
+			t.Fatalf("allocateNamedPorts failed: %v", err)
+		}
+		if len(ports) != 2 || ports["livereload"] == ports["debug"] || ports["livereload"] == main || ports["debug"] == main {
+			t.Errorf("expected two distinct ports besides %d, got %v", main, ports)
+		}
+		if m.stickyPorts["web-myapp:livereload"] != ports["livereload"] {
+			t.Errorf("expected livereload port to be remembered, got %v", m.stickyPorts)
+		}
+	})
+
+	t.Run("random allocation skips ports other processes used last", func(t *testing.T) {
+		m := NewManager()
+		owned := freePort(t)
+		m.stickyPorts["api-myapp"] = owned
+		m.nextPort = owned
+
+		got, err := m.findFreePort()
+		if err != nil {
+			t.Fatalf("findFreePort failed: %v", err)
+		}
+		if got == owned {
+			t.Errorf("expected port %d to be left for api-myapp", owned)
+		}
+	})
+
+	t.Run("assigned ports are used exactly or not at all", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		defer ln.Close()
+		busy := ln.Addr().(*net.TCPAddr).Port
+
+		m := NewManager()
+		opts := Options{Ready: ReadyCheck{Type: ReadyNone}, PreferredPort: freePort(t), Port: busy}
+		if _, err := m.StartAsyncWithOptions("web-myapp", "sleep 10", "/tmp", nil, opts); err == nil || !strings.Contains(err.Error(), "in use") {
+			t.Errorf("expected start to fail while port %d is taken, got %v", busy, err)
+		}
+		if _, found := m.Get("web-myapp"); found {
+			t.Error("expected no process on another port")
+		}
+
+		port, debug := freePort(t), freePort(t)
+		opts = Options{Ready: ReadyCheck{Type: ReadyNone}, NamedPorts: []string{"debug"}, Port: port, Ports: map[string]int{"debug": debug}}
+		proc, err := m.StartAsyncWithOptions("web-myapp", "sleep 10", "/tmp", nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsyncWithOptions failed: %v", err)
+		}
+		defer m.Stop("web-myapp")
+		if proc.Port != port || proc.Ports["debug"] != debug {
+			t.Errorf("expected ports %d and debug %d, got %d and %v", port, debug, proc.Port, proc.Ports)
+		}
+	})
+}
+
+func TestAssignPorts(t *testing.T) {
+	t.Run("assigns the ports processes get when they start", func(t *testing.T) {
+		m := NewManager()
+		ports, err := m.AssignPorts(map[string]int{"web-myapp": 0, "api-myapp": 0})
+		if err != nil {
+			t.Fatalf("AssignPorts failed: %v", err)
+		}
+		if ports["web-myapp"] == 0 || ports["web-myapp"] == ports["api-myapp"] {
+			t.Fatalf("expected two distinct ports, got %v", ports)
+		}
+		if len(m.reservedPorts) != 0 {
+			t.Errorf("expected assigned ports not to stay reserved, got %v", m.reservedPorts)
+		}
+
+		m.mu.Lock()
+		got, err := m.allocatePort("api-myapp", 0)
+		m.mu.Unlock()
+		if err != nil {
+			t.Fatalf("allocatePort failed: %v", err)
+		}
+		if got != ports["api-myapp"] {
+			t.Errorf("expected api-myapp to start on %d, got %d", ports["api-myapp"], got)
+		}
+	})
+
+	t.Run("keeps the port of a running process", func(t *testing.T) {
+		m := NewManager()
+		defer m.StopAll()
+		opts := Options{StopSignal: syscall.SIGKILL, NamedPorts: []string{"debug"}}
+		proc, err := m.StartAsyncWithOptions("web-myapp", "sleep 30", t.TempDir(), nil, opts)
+		if err != nil {
+			t.Fatalf("start failed: %v", err)
+		}
+		ports, err := m.AssignPorts(map[string]int{"web-myapp": 0, "web-myapp:debug": 0})
+		if err != nil {
+			t.Fatalf("AssignPorts failed: %v", err)
+		}
+		if ports["web-myapp"] != proc.Port {
+			t.Errorf("expected running port %d, got %d", proc.Port, ports["web-myapp"])
+		}
+		if ports["web-myapp:debug"] != proc.Ports["debug"] {
+			t.Errorf("expected running debug port %d, got %d", proc.Ports["debug"], ports["web-myapp:debug"])
+		}
+	})
+
+	t.Run("predicting ports doesn't claim them", func(t *testing.T) {
+		m := NewManager()
+		m.mu.Lock()
+		last, err := m.allocatePort("api-myapp", 0)
+		m.releasePort(last)
+		m.mu.Unlock()
+		if err != nil {
+			t.Fatalf("allocatePort failed: %v", err)
+		}
+
+		ports, err := m.PredictPorts(map[string]int{"web-myapp": 0, "api-myapp": 0})
+		if err != nil {
+			t.Fatalf("PredictPorts failed: %v", err)
+		}
+		if ports["web-myapp"] == 0 || ports["web-myapp"] == ports["api-myapp"] {
+			t.Fatalf("expected two distinct ports, got %v", ports)
+		}
+		if ports["api-myapp"] != last {
+			t.Errorf("expected api-myapp's last port %d, got %d", last, ports["api-myapp"])
+		}
+		if got := m.LastPort("web-myapp"); got != 0 {
+			t.Errorf("expected web-myapp's port not to be remembered, got %d", got)
+		}
+		if len(m.reservedPorts) != 0 {
+			t.Errorf("expected predicted ports not to stay reserved, got %v", m.reservedPorts)
+		}
+	})
+}
+
+func TestHooks(t *testing.T) {
+	waitFor := func(t *testing.T, what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for !cond() && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if !cond() {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+
+	t.Run("runs before_start and after_ready around the command", func(t *testing.T) {
+		dir := t.TempDir()
+		m := NewManager()
+		opts := Options{
+			Ready: ReadyCheck{Type: ReadyFile, Path: "started"},
+			Hooks: Hooks{
+				BeforeStart: "echo installing; touch before",
+				AfterReady:  "test -f started && touch after",
+			},
+			StopTimeout: 100 * time.Millisecond,
+		}
+		proc, err := m.StartAsyncWithOptions("test-hooks", "test -f before && touch started && sleep 10", dir, nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		defer m.Stop("test-hooks")
+
+		waitFor(t, "process to be running", proc.IsRunning)
+		if _, err := os.Stat(filepath.Join(dir, "after")); err != nil {
+			t.Error("expected after_ready hook to run before the process counts as running")
+		}
+		waitForLog(t, proc, "installing")
+	})
+
+	t.Run("failing before_start marks process failed without starting it", func(t *testing.T) {
+		dir := t.TempDir()
+		m := NewManager()
+		opts := Options{Hooks: Hooks{BeforeStart: "exit 3"}}
+		proc, err := m.StartAsyncWithOptions("test-hookfail", "touch started; sleep 10", dir, nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		defer m.Stop("test-hookfail")
+		if proc.Phase() != PhaseBeforeStart {
+			t.Errorf("expected phase %q while the hook runs, got %q", PhaseBeforeStart, proc.Phase())
+		}
+
+		waitFor(t, "process to fail", proc.HasFailed)
+		if got := proc.ExitError(); got != "before_start hook failed: exit code 3" {
+			t.Errorf("unexpected exit error: %q", got)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "started")); err == nil {
+			t.Error("expected command not to run after a failed hook")
+		}
+	})
+
+	t.Run("failing after_ready stops the process", func(t *testing.T) {
+		m := NewManager()
+		opts := Options{
+			Ready:       ReadyCheck{Type: ReadyNone},
+			Hooks:       Hooks{AfterReady: "exit 2"},
+			StopSignal:  syscall.SIGKILL,
+			StopTimeout: 100 * time.Millisecond,
+		}
+		proc, err := m.StartAsyncWithOptions("test-afterready", "sleep 10", t.TempDir(), nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		defer m.Stop("test-afterready")
+
+		waitFor(t, "process to fail", proc.HasFailed)
+		if got := proc.ExitError(); got != "after_ready hook failed: exit code 2" {
+			t.Errorf("unexpected exit error: %q", got)
+		}
+	})
+
+	t.Run("stop waits for after_stop", func(t *testing.T) {
+		dir := t.TempDir()
+		m := NewManager()
+		opts := Options{
+			Ready:       ReadyCheck{Type: ReadyNone},
+			Hooks:       Hooks{AfterStop: "sleep 0.2; touch cleaned"},
+			StopSignal:  syscall.SIGKILL,
+			StopTimeout: 100 * time.Millisecond,
+		}
+		proc, err := m.StartAsyncWithOptions("test-afterstop", "sleep 10", dir, nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		waitFor(t, "process to be running", proc.IsRunning)
+
+		m.Stop("test-afterstop")
+		if _, err := os.Stat(filepath.Join(dir, "cleaned")); err != nil {
+			t.Error("expected after_stop hook to have run when Stop returns")
+		}
+	})
+}
+
+func TestWatchSpec(t *testing.T) {
+	tests := []struct {
+		spec WatchSpec
+		path string
+		want bool
+	}{
+		{WatchSpec{}, "main.go", true},
+		{WatchSpec{}, ".git/index", false},
+		{WatchSpec{}, "web/node_modules/react/index.js", false},
+		{WatchSpec{}, "src/.main.go.swp", false},
+		{WatchSpec{Include: []string{"*.go"}}, "cmd/api/main.go", true},
+		{WatchSpec{Include: []string{"*.go"}}, "README.md", false},
+		{WatchSpec{Include: []string{"lib/**/*.ex"}}, "lib/app.ex", true},
+		{WatchSpec{Include: []string{"lib/**/*.ex"}}, "lib/app/web/router.ex", true},
+		{WatchSpec{Include: []string{"lib/**/*.ex"}}, "test/app_test.ex", false},
+		{WatchSpec{Include: []string{"**/*.py"}, Exclude: []string{"migrations/**"}}, "migrations/0001.py", false},
+		{WatchSpec{Exclude: []string{"*_test.go"}}, "pkg/x_test.go", false},
+	}
+	for _, tt := range tests {
+		if got := tt.spec.matches(tt.path); got != tt.want {
+			t.Errorf("%+v matches(%q) = %v, want %v", tt.spec, tt.path, got, tt.want)
+		}
+	}
+
+	if !(WatchSpec{Exclude: []string{"vendor/**"}}).skipDir("vendor") {
+		t.Error("expected excluded directory to be skipped")
+	}
+	if (WatchSpec{}).skipDir("src") {
+		t.Error("expected src to be watched")
+	}
+}
+
+func TestWatchRestart(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	m := NewManager()
+	defer m.StopAll()
+
+	opts := Options{
+		Ready:       ReadyCheck{Type: ReadyNone},
+		StopSignal:  syscall.SIGKILL,
+		StopTimeout: 100 * time.Millisecond,
+		Watch:       &WatchSpec{Include: []string{"*.go"}, Debounce: 50 * time.Millisecond},
+	}
+	first, err := m.StartAsyncWithOptions("test-watch", "sleep 30", dir, nil, opts)
+	if err != nil {
+		t.Fatalf("StartAsync failed: %v", err)
+	}
+
+	// Ignored by the include globs
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644)
+	time.Sleep(300 * time.Millisecond)
+	if proc, _ := m.Get("test-watch"); proc != first {
+		t.Fatal("expected change to a non-matching file not to restart")
+	}
+
+	os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main"), 0644)
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if proc, ok := m.Get("test-watch"); ok && proc != first {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if proc, _ := m.Get("test-watch"); proc == first {
+		t.Fatal("expected change to src/main.go to restart the process")
+	}
+	if got := m.RestartTrigger("test-watch"); got != "src/main.go" {
+		t.Errorf("expected trigger src/main.go, got %q", got)
+	}
+
+	m.Stop("test-watch")
+	if got := m.RestartTrigger("test-watch"); got != "" {
+		t.Errorf("expected stop to clear the trigger, got %q", got)
+	}
+}
+
+func TestSetWatchRestart(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager()
+	defer m.StopAll()
+
+	opts := Options{
+		NoPort:      true,
+		StopSignal:  syscall.SIGKILL,
+		StopTimeout: 100 * time.Millisecond,
+		Watch:       &WatchSpec{Debounce: 50 * time.Millisecond},
+	}
+	restarted := make(chan string, 1)
+	m.SetWatchRestart(func(name string) {
+		// Restart the way a caller would, through Stop and start
+		m.Stop(name)
+		m.StartAsyncWithOptions(name, "sleep 30", dir, nil, opts)
+		restarted <- name
+	})
+	if _, err := m.StartAsyncWithOptions("test-watch-fn", "sleep 30", dir, nil, opts); err != nil {
+		t.Fatalf("StartAsync failed: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0644)
+	select {
+	case name := <-restarted:
+		if name != "test-watch-fn" {
+			t.Errorf("expected test-watch-fn to be restarted, got %s", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the change to go through the restart function")
+	}
+	deadline := time.Now().Add(time.Second)
+	for m.RestartTrigger("test-watch-fn") == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := m.RestartTrigger("test-watch-fn"); got != "main.go" {
+		t.Errorf("expected trigger main.go to survive the restart, got %q", got)
+	}
+}
+
+func TestWatchIgnoresOwnFiles(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager()
+	defer m.StopAll()
+
+	opts := Options{
+		NoPort:      true,
+		Ready:       ReadyCheck{Type: ReadyFile, Path: "ready.txt"},
+		PIDFile:     "server.pid",
+		StopSignal:  syscall.SIGKILL,
+		StopTimeout: 100 * time.Millisecond,
+		Watch:       &WatchSpec{Debounce: 50 * time.Millisecond},
+	}
+	first, err := m.StartAsyncWithOptions("test-own-files", "echo $$ > server.pid; touch ready.txt; sleep 30", dir, nil, opts)
+	if err != nil {
+		t.Fatalf("StartAsync failed: %v", err)
+	}
+	first.waitReady(5 * time.Second)
+	if first.IsStarting() {
+		t.Fatal("expected the process to be ready")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if proc, _ := m.Get("test-own-files"); proc != first {
+		t.Errorf("expected writing the ready file and pidfile not to restart the process (trigger %q)", m.RestartTrigger("test-own-files"))
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	got := shellJoin([]string{"bin/rails", "server", "-p", "3000", "hello world", "it's", ""})
+	want := `bin/rails server -p 3000 'hello world' 'it'\''s' ''`
+	if got != want {
+		t.Errorf("shellJoin = %s, want %s", got, want)
+	}
+}
+
+func TestLookPath(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	os.Mkdir(bin, 0755)
+	os.WriteFile(filepath.Join(bin, "mytool"), []byte("#!/bin/sh\n"), 0755)
+	os.WriteFile(filepath.Join(bin, "notexec"), []byte("data"), 0644)
+
+	env := []string{"PATH=/nonexistent", "HOME=/tmp", "PATH=/nonexistent:" + bin}
+	if got, err := lookPath("mytool", env); err != nil || got != filepath.Join(bin, "mytool") {
+		t.Errorf("expected mytool from the last PATH, got %q, %v", got, err)
+	}
+	if _, err := lookPath("notexec", env); err == nil {
+		t.Error("expected non-executable file to be skipped")
+	}
+}
+
+func TestParseEnv0(t *testing.T) {
+	got := parseEnv0([]byte("PATH=/usr/bin\x00PWD=/home\x00MULTI=a\nb\x00SHLVL=2\x00EMPTY=\x00"))
+	want := []string{"PATH=/usr/bin", "MULTI=a\nb", "EMPTY="}
+	if !slices.Equal(got, want) {
+		t.Errorf("parseEnv0 = %q, want %q", got, want)
+	}
+}
+
+func TestLoginEnvOutsideLock(t *testing.T) {
+	// A login shell that's slow to print its environment
+	loginEnvOnce = sync.Once{}
+	entered, release := make(chan struct{}), make(chan struct{})
+	go loginEnvOnce.Do(func() {
+		close(entered)
+		<-release
+		loginEnv = os.Environ()
+	})
+	<-entered
+
+	m := NewManager()
+	started := make(chan error, 1)
+	go func() {
+		_, err := m.StartAsyncWithOptions("test-loginenv", "sleep 10", "/tmp", nil, Options{Shell: ShellPlain, Ready: ReadyCheck{Type: ReadyNone}})
+		started <- err
+	}()
+	defer m.Stop("test-loginenv")
+	time.Sleep(100 * time.Millisecond)
+
+	listed := make(chan struct{})
+	go func() {
+		m.All()
+		close(listed)
+	}()
+	select {
+	case <-listed:
+	case <-time.After(time.Second):
+		t.Error("expected the manager to answer while the login environment resolves")
+	}
+
+	close(release)
+	if err := <-started; err != nil {
+		t.Errorf("StartAsyncWithOptions failed: %v", err)
+	}
+}
+
+func TestExec(t *testing.T) {
+	for _, shell := range []string{ShellNone, ShellPlain} {
+		t.Run("runs argv with shell "+shell, func(t *testing.T) {
+			dir := t.TempDir()
+			m := NewManager()
+			opts := Options{
+				Exec:        []string{"sh", "-c", `echo "$1 $GREETING $PORT" > out; sleep 10`, "sh", "a b"},
+				Shell:       shell,
+				Ready:       ReadyCheck{Type: ReadyFile, Path: "out"},
+				StopSignal:  syscall.SIGKILL,
+				StopTimeout: 100 * time.Millisecond,
+			}
+			proc, err := m.StartAsyncWithOptions("test-exec", "sh -c ...", dir, map[string]string{"GREETING": "hi"}, opts)
+			if err != nil {
+				t.Fatalf("StartAsync failed: %v", err)
+			}
+			defer m.Stop("test-exec")
+
+			deadline := time.Now().Add(10 * time.Second)
+			for !proc.IsRunning() && time.Now().Before(deadline) {
+				time.Sleep(50 * time.Millisecond)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "out"))
+			if err != nil {
+				t.Fatalf("expected command to write out: %v", err)
+			}
+			if want := fmt.Sprintf("a b hi %d\n", proc.Port); string(data) != want {
+				t.Errorf("expected %q (the arg passed as one word), got %q", want, data)
+			}
+		})
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	t.Setenv("ROOST_TEST_STALE", "stale")
+	t.Setenv("LC_ROOST_TEST", "C")
+
+	has := func(env []string, kv string) bool { return slices.Contains(env, kv) }
+
+	t.Run("inherits everything by default", func(t *testing.T) {
+		env := Environment("test-env", map[string]string{"URL": "http://localhost"}, Options{}, map[string]int{"test-env": 51234})
+		if !has(env, "ROOST_TEST_STALE=stale") || !has(env, "FORCE_COLOR=1") {
+			t.Errorf("expected inherited and roost-dev variables, got %v", env)
+		}
+		if !has(env, "PORT=51234") || !has(env, "URL=http://localhost") {
+			t.Errorf("expected the process's port and env, got %v", env)
+		}
+	})
+
+	t.Run("clean env keeps essentials and the allowlist", func(t *testing.T) {
+		opts := Options{CleanEnv: true, EnvAllow: []string{"LC_*"}, NamedPorts: []string{"debug"}}
+		env := Environment("test-env", map[string]string{"PATH": "/custom"}, opts, map[string]int{"test-env": 51234, "test-env:debug": 51235})
+		if has(env, "ROOST_TEST_STALE=stale") || !has(env, "LC_ROOST_TEST=C") || !has(env, "HOME="+os.Getenv("HOME")) {
+			t.Errorf("expected only essentials and allowlisted variables, got %v", env)
+		}
+		if !has(env, "PATH=/custom") || slices.ContainsFunc(env, func(kv string) bool { return kv == "PATH="+os.Getenv("PATH") }) {
+			t.Errorf("expected inline env to override the inherited PATH, got %v", env)
+		}
+		if !has(env, "PORT_DEBUG=51235") {
+			t.Errorf("expected named port, got %v", env)
+		}
+	})
+
+	t.Run("running process gets the clean env and reports its own ports", func(t *testing.T) {
+		dir := t.TempDir()
+		m := NewManager()
+		opts := Options{CleanEnv: true, Ready: ReadyCheck{Type: ReadyFile, Path: "env.txt"}, StopSignal: syscall.SIGKILL, StopTimeout: 100 * time.Millisecond}
+		proc, err := m.StartAsyncWithOptions("test-env", "env > env.tmp && mv env.tmp env.txt; sleep 10", dir, nil, opts)
+		if err != nil {
+			t.Fatalf("StartAsync failed: %v", err)
+		}
+		defer m.Stop("test-env")
+
+		deadline := time.Now().Add(10 * time.Second)
+		for !proc.IsRunning() && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "env.txt"))
+		if err != nil {
+			t.Fatalf("expected the process to write its env: %v", err)
+		}
+		if strings.Contains(string(data), "ROOST_TEST_STALE") {
+			t.Error("expected the process not to inherit ROOST_TEST_STALE")
+		}
+
+		ports, err := m.PredictPorts(map[string]int{"test-env": 0})
+		if err != nil {
+			t.Fatalf("PredictPorts failed: %v", err)
+		}
+		env := Environment("test-env", nil, opts, ports)
+		if !has(env, fmt.Sprintf("PORT=%d", proc.Port)) {
+			t.Errorf("expected running port %d, got %v", proc.Port, env)
+		}
+	})
+}
+
+func TestWorker(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager()
+	opts := Options{NoPort: true, StopSignal: syscall.SIGKILL, StopTimeout: 100 * time.Millisecond}
+	proc, err := m.StartAsyncWithOptions("jobs-test", "env > env.tmp && mv env.tmp env.txt; sleep 10", dir, nil, opts)
+	if err != nil {
+		t.Fatalf("StartAsync failed: %v", err)
+	}
+	defer m.Stop("jobs-test")
+
+	// Without a ready check a worker is running as soon as it starts
+	deadline := time.Now().Add(5 * time.Second)
+	for !proc.IsRunning() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !proc.IsRunning() {
+		t.Fatal("expected worker to be running without waiting on a port")
+	}
+	if proc.Port != 0 {
+		t.Errorf("expected no port, got %d", proc.Port)
+	}
+
+	var data []byte
+	for time.Now().Before(deadline) {
+		if data, err = os.ReadFile(filepath.Join(dir, "env.txt")); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("expected the worker to write its env: %v", err)
+	}
+	if slices.ContainsFunc(strings.Split(string(data), "\n"), func(kv string) bool { return strings.HasPrefix(kv, "PORT=") }) {
+		t.Error("expected no PORT in a worker's environment")
+	}
+
+	env := Environment("jobs-test", nil, opts, map[string]int{"jobs-test": 51234})
+	if slices.ContainsFunc(env, func(kv string) bool { return strings.HasPrefix(kv, "PORT=") }) {
+		t.Errorf("expected no PORT, got %v", env)
+	}
+}
+
+func TestStopWaves(t *testing.T) {
+	proc := func(deps ...string) *Process { return &Process{opts: Options{DependsOn: deps}} }
+
+	t.Run("dependents stop first", func(t *testing.T) {
+		procs := map[string]*Process{
+			"db":     proc(),
+			"api":    proc("db"),
+			"web":    proc("api"),
+			"jobs":   proc("db"),
+			"docs":   proc(),
+			"absent": proc("gone"), // a dependency that isn't running doesn't hold anything up
+		}
+		got := stopWaves(procs)
+		want := [][]string{{"absent", "docs", "jobs", "web"}, {"api"}, {"db"}}
+		if !slices.EqualFunc(got, want, slices.Equal) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("a cycle ends up in one wave", func(t *testing.T) {
+		got := stopWaves(map[string]*Process{"a": proc("b"), "b": proc("a")})
+		if want := [][]string{{"a", "b"}}; !slices.EqualFunc(got, want, slices.Equal) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+}
+
+func TestStopGroup(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager()
+	// Each process records when it's asked to stop, then exits
+	start := func(name string, deps ...string) {
+		opts := Options{
+			NoPort:      true,
+			DependsOn:   deps,
+			StopCommand: "echo " + name + " >> stopped.txt; kill $(cat " + name + ".pid)",
+			StopTimeout: 2 * time.Second,
+		}
+		if _, err := m.StartAsyncWithOptions(name, "echo $$ > "+name+".pid; exec sleep 10", dir, nil, opts); err != nil {
+			t.Fatalf("StartAsync %s failed: %v", name, err)
+		}
+	}
+	start("db")
+	start("api", "db")
+	start("web", "api")
+	defer m.StopAll()
+
+	for _, name := range []string{"db", "api", "web"} {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, err := os.Stat(filepath.Join(dir, name+".pid")); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	m.StopGroup([]string{"db", "api", "web", "missing"})
+	data, err := os.ReadFile(filepath.Join(dir, "stopped.txt"))
+	if err != nil {
+		t.Fatalf("expected stop commands to run: %v", err)
+	}
+	if got := strings.Fields(string(data)); !slices.Equal(got, []string{"web", "api", "db"}) {
+		t.Errorf("expected web, api, db to stop in that order, got %v", got)
+	}
+	if len(m.All()) != 0 {
+		t.Errorf("expected every process to be gone, got %d", len(m.All()))
+	}
+}
+
+func TestLimits(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager()
+	opts := Options{
+		NoPort:      true,
+		Limits:      Limits{OpenFiles: 256, Nice: 5},
+		Ready:       ReadyCheck{Type: ReadyFile, Path: "limits.txt"},
+		StopSignal:  syscall.SIGKILL,
+		StopTimeout: 100 * time.Millisecond,
+	}
+	proc, err := m.StartAsyncWithOptions("limits-test", `echo "$(ulimit -n) $(nice)" > limits.tmp && mv limits.tmp limits.txt; sleep 10`, dir, nil, opts)
+	if err != nil {
+		t.Fatalf("StartAsync failed: %v", err)
+	}
+	defer m.Stop("limits-test")
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !proc.IsRunning() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "limits.txt"))
+	if err != nil {
+		t.Fatalf("expected the command to write its limits: %v", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || fields[0] != "256" {
+		t.Errorf("expected 256 open files, got %q", data)
+	}
+	if nice, _ := strconv.Atoi(fields[len(fields)-1]); nice < 5 {
+		t.Errorf("expected niceness of at least 5, got %q", data)
+	}
+
+	t.Run("keeps the pid of the real command", func(t *testing.T) {
+		cmd := exec.Command("/bin/echo", "hi", "there")
+		Limits{OpenFiles: 64}.wrap(cmd, true)
+		out, err := cmd.Output()
+		if err != nil || string(out) != "hi there\n" {
+			t.Errorf("expected wrapped echo to print its args, got %q, %v", out, err)
+		}
+	})
+}
+
+func TestCgroupParsing(t *testing.T) {
+	if path, ok := parseCgroupPath("1:name=systemd:/legacy\n0::/user.slice/user-1000.slice/app.scope\n"); !ok || path != "/user.slice/user-1000.slice/app.scope" {
+		t.Errorf("parseCgroupPath = %q, %v", path, ok)
+	}
+	if _, ok := parseCgroupPath("1:name=systemd:/legacy\n"); ok {
+		t.Error("expected no cgroup v2 path")
+	}
+	if n := parseOOMKills("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\noom_group_kill 0\n"); n != 1 {
+		t.Errorf("parseOOMKills = %d, want 1", n)
+	}
+	for n, want := range map[int64]string{512 << 20: "512M", 3 << 29: "1.5G", 1 << 40: "1T", 100: "100"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+func TestProcTree(t *testing.T) {
+	table := procTable{
+		1:   {PID: 1, PPID: 0, PGID: 1, Command: "init"},
+		100: {PID: 100, PPID: 1, PGID: 100, Command: "bash"},
+		101: {PID: 101, PPID: 100, PGID: 100, Command: "node"},
+		102: {PID: 102, PPID: 101, PGID: 100, Command: "esbuild"},
+		103: {PID: 103, PPID: 100, PGID: 103, Command: "setsid child"},
+		104: {PID: 104, PPID: 1, PGID: 100, Command: "orphan"},
+		200: {PID: 200, PPID: 1, PGID: 200, Command: "unrelated"},
+	}
+	tree := table.tree(100)
+	var got []string
+	tree.Walk(func(p *ProcTree, depth int) {
+		got = append(got, fmt.Sprintf("%d:%s", depth, p.Command))
+	})
+	want := []string{"0:bash", "1:node", "2:esbuild", "1:setsid child", "1:orphan"}
+	if !slices.Equal(got, want) {
+		t.Errorf("tree = %v, want %v", got, want)
+	}
+	if pids := tree.pids(); !slices.Equal(pids, []int{102, 101, 103, 104, 100}) {
+		t.Errorf("pids = %v, want children before parents", pids)
+	}
+	if table.tree(999) != nil {
+		t.Error("expected no tree for a pid that isn't running")
+	}
+}
+
+func TestKillChildProcesses(t *testing.T) {
+	start := func(t *testing.T) (*exec.Cmd, ProcInfo) {
+		t.Helper()
+		cmd := exec.Command("sleep", "30")
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("start failed: %v", err)
+		}
+		t.Cleanup(func() {
+			cmd.Process.Kill()
+			cmd.Wait()
+		})
+		table, err := snapshotProcs()
+		if err != nil {
+			t.Fatalf("snapshotProcs failed: %v", err)
+		}
+		return cmd, table[cmd.Process.Pid]
+	}
+	treeOf := func(child ProcInfo) *ProcTree {
+		return &ProcTree{ProcInfo: ProcInfo{PID: -1}, Children: []*ProcTree{{ProcInfo: child}}}
+	}
+	exited := func(cmd *exec.Cmd) bool {
+		done := make(chan struct{})
+		go func() {
+			cmd.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return true
+		case <-time.After(time.Second):
+			return false
+		}
+	}
+
+	t.Run("kills a child still in the tree", func(t *testing.T) {
+		cmd, info := start(t)
+		killChildProcesses(treeOf(info), "test-kill")
+		if !exited(cmd) {
+			t.Error("expected the child to be killed")
+		}
+	})
+
+	t.Run("leaves a reused pid alone", func(t *testing.T) {
+		cmd, info := start(t)
+		// Noted with another parent and group, as if the child had exited
+		// and something else got its pid
+		info.PPID, info.PGID = info.PPID+1, info.PGID+1
+		killChildProcesses(treeOf(info), "test-kill")
+		if err := syscall.Kill(cmd.Process.Pid, 0); err != nil {
+			t.Errorf("expected the unrelated process to keep running: %v", err)
+		}
+	})
+}
+
+func TestParseProcStat(t *testing.T) {
+	stat := "4242 (my (weird) cmd) S 4200 4242 4242 0 -1 4194560 1000 0 0 0 250 50 0 0 20 0 7 0 123456 100000000 2560 18446744073709551615 1 1 0 0 0 0 0 0 0 0 0 0 17 3 0 0 0 0 0"
+	info, ok := parseProcStat(stat, 100, 4096)
+	if !ok {
+		t.Fatal("expected stat to parse")
+	}
+	want := ProcInfo{PID: 4242, PPID: 4200, PGID: 4242, Command: "my (weird) cmd", Threads: 7, RSS: 2560 * 4096, CPUTime: 3 * time.Second}
+	if info != want {
+		t.Errorf("parseProcStat = %+v, want %+v", info, want)
+	}
+	if _, ok := parseProcStat("garbage", 100, 4096); ok {
+		t.Error("expected garbage not to parse")
+	}
+}
+
+func TestParsePS(t *testing.T) {
+	out := "  100     1   100  2048   0:01.50 /bin/bash -c bin/dev\n  101   100   100 153600 1:02:03 node server.js\nbad line\n"
+	table := parsePS(out)
+	if len(table) != 2 {
+		t.Fatalf("expected 2 processes, got %v", table)
+	}
+	if p := table[100]; p.RSS != 2048*1024 || p.CPUTime != 1500*time.Millisecond || p.Command != "/bin/bash -c bin/dev" {
+		t.Errorf("unexpected process 100: %+v", p)
+	}
+	if p := table[101]; p.PPID != 100 || p.CPUTime != time.Hour+2*time.Minute+3*time.Second {
+		t.Errorf("unexpected process 101: %+v", p)
+	}
+	if d := parsePSTime("2-03:00:00"); d != 51*time.Hour {
+		t.Errorf("parsePSTime with days = %s, want 51h", d)
+	}
+}
+
+func TestSampleMetrics(t *testing.T) {
+	m := NewManager()
+	opts := Options{NoPort: true, StopSignal: syscall.SIGKILL, StopTimeout: 100 * time.Millisecond}
+	proc, err := m.StartAsyncWithOptions("metrics-test", "sleep 30 & sleep 30 & wait", t.TempDir(), nil, opts)
+	if err != nil {
+		t.Fatalf("StartAsync failed: %v", err)
+	}
+	defer m.Stop("metrics-test")
+
+	// The login shell may run other commands from its profile first
+	sleeps := func(tree *ProcTree) int {
+		n := 0
+		tree.Walk(func(p *ProcTree, depth int) {
+			if depth > 0 && strings.HasPrefix(p.Command, "sleep 30") {
+				n++
+			}
+		})
+		return n
+	}
+	var tree *ProcTree
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		trees, err := m.Trees()
+		if err != nil {
+			t.Fatalf("Trees failed: %v", err)
+		}
+		if tree = trees["metrics-test"]; tree != nil && sleeps(tree) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if pid, _ := proc.Pid(); tree == nil || tree.PID != pid || sleeps(tree) != 2 {
+		t.Fatalf("expected the shell with two sleeps under it, got %+v", tree)
+	}
+
+	if err := m.SampleMetrics(); err != nil {
+		t.Fatalf("SampleMetrics failed: %v", err)
+	}
+	metrics := proc.Metrics()
+	if metrics.Children < 2 || metrics.RSS <= 0 || metrics.Sampled.IsZero() {
+		t.Errorf("expected the sleeps to count as children and some memory, got %+v", metrics)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, "processes.json")
+	opts := Options{NoPort: true, StopSignal: syscall.SIGKILL, StopTimeout: 100 * time.Millisecond}
+
+	// A previous run that went away without stopping its processes
+	prev := NewManager()
+	prev.SetStateFile(stateFile)
+	for _, name := range []string{"keep-test", "changed-test", "removed-test"} {
+		if _, err := prev.StartWithOptions(name, "sleep 30", dir, nil, opts); err != nil {
+			t.Fatalf("Start %s failed: %v", name, err)
+		}
+	}
+	saved, err := LoadState(stateFile)
+	if err != nil || len(saved) != 3 {
+		t.Fatalf("expected 3 saved processes, got %v, %v", saved, err)
+	}
+	prev.SetStateFile("")
+	keepPid, _ := prev.processes["keep-test"].Pid()
+	defer syscall.Kill(-keepPid, syscall.SIGKILL)
+
+	m := NewManager()
+	m.SetStateFile(stateFile)
+	adopted, killed := m.Recover(func(name string) (Spec, bool) {
+		switch name {
+		case "keep-test":
+			return Spec{Command: "sleep 30", Dir: dir, Opts: opts}, true
+		case "changed-test":
+			return Spec{Command: "sleep 60", Dir: dir, Opts: opts}, true
+		}
+		return Spec{}, false
+	})
+	if !slices.Equal(adopted, []string{"keep-test"}) {
+		t.Errorf("expected keep-test to be adopted, got %v", adopted)
+	}
+	slices.Sort(killed)
+	if !slices.Equal(killed, []string{"changed-test", "removed-test"}) {
+		t.Errorf("expected the others to be killed, got %v", killed)
+	}
+	for _, name := range killed {
+		if pid, ok := prev.processes[name].Pid(); ok && syscall.Kill(pid, 0) == nil && !waitForExit(pid, time.Second) {
+			t.Errorf("expected %s (pid %d) to be killed", name, pid)
+		}
+	}
+
+	proc, ok := m.Get("keep-test")
+	if !ok || !proc.IsRunning() {
+		t.Fatal("expected the adopted process to be running")
+	}
+	if pid, _ := proc.Pid(); pid != keepPid {
+		t.Errorf("expected pid %d, got %d", keepPid, pid)
+	}
+	if saved, _ := LoadState(stateFile); len(saved) != 1 || saved[0].Name != "keep-test" {
+		t.Errorf("expected only the adopted process in the state file, got %+v", saved)
+	}
+
+	m.Stop("keep-test")
+	if !waitForExit(-keepPid, 2*time.Second) {
+		t.Error("expected stopping the adopted process to kill its group")
+	}
+	if saved, _ := LoadState(stateFile); len(saved) != 0 {
+		t.Errorf("expected an empty state file, got %+v", saved)
+	}
+
+	t.Run("leaves processes without the marker alone", func(t *testing.T) {
+		cmd := exec.Command("sleep", "30")
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer cmd.Process.Kill()
+		data, _ := json.Marshal([]SavedProcess{{Name: "reused-test", PID: cmd.Process.Pid, PGID: cmd.Process.Pid, Command: "sleep 30"}})
+		os.WriteFile(stateFile, data, 0644)
+
+		adopted, killed := m.Recover(func(string) (Spec, bool) { return Spec{}, false })
+		if len(adopted) != 0 || len(killed) != 0 {
+			t.Errorf("expected an unmarked process to be ignored, got %v, %v", adopted, killed)
+		}
+		if syscall.Kill(cmd.Process.Pid, 0) != nil {
+			t.Error("expected the unmarked process to survive")
+		}
+	})
+}
+
+func TestDetectPIDFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	write("config/puma.rb", "port ENV.fetch(\"PORT\") { 3000 }\npidfile ENV.fetch(\"PIDFILE\") { \"tmp/pids/puma.pid\" }\n")
+	write("config/unicorn.rb", "worker_processes 2\npid \"/var/run/unicorn.pid\"\n")
+	write("Procfile.dev", "# web first\nweb: bin/rails server -p $PORT\ncss: bin/rails tailwindcss:watch\n")
+	write("bin/dev", "#!/bin/sh\nexec foreman start -f Procfile.dev \"$@\"\n")
+	write("_build/prod/rel/shop/releases/start_erl.data", "")
+
+	rel := func(p string) string { return filepath.Join(dir, p) }
+	cases := []struct {
+		command string
+		env     map[string]string
+		opts    Options
+		want    []string
+	}{
+		{command: "bin/rails s -p $PORT", want: []string{rel("tmp/pids/server.pid")}},
+		{command: "bundle exec rails server -P tmp/web.pid", want: []string{rel("tmp/web.pid")}},
+		{command: "bin/rails console"},
+		{command: "bundle exec puma -C config/puma.rb", want: []string{rel("tmp/pids/puma.pid")}},
+		{command: "puma", env: map[string]string{"PIDFILE": "/tmp/env.pid"}, want: []string{"/tmp/env.pid"}},
+		{command: "puma --pidfile=tmp/p.pid", want: []string{rel("tmp/p.pid")}},
+		{command: "bundle exec unicorn -p $PORT", want: []string{"/var/run/unicorn.pid"}},
+		{command: "bin/dev", want: []string{rel("tmp/pids/server.pid")}},
+		{command: "foreman start -f Procfile.dev", want: []string{rel("tmp/pids/server.pid")}},
+		{command: "_build/prod/rel/shop/bin/shop start", env: map[string]string{"PIDFILE": "tmp/shop.pid"}, want: []string{rel("tmp/shop.pid")}},
+		{command: "bin/shop start", env: map[string]string{"PIDFILE": "tmp/shop.pid"}},
+		{command: "python manage.py runserver_plus --pidfile /tmp/dj.pid", want: []string{"/tmp/dj.pid"}},
+		{command: "python manage.py runserver $PORT"},
+		{command: "gunicorn -p tmp/gunicorn.pid app.wsgi", want: []string{rel("tmp/gunicorn.pid")}},
+		{command: "bin/server", opts: Options{PIDFile: "tmp/server.pid"}, want: []string{rel("tmp/server.pid")}},
+		{command: "bin/rails s", opts: Options{PIDFile: "tmp/pids/server.pid"}, want: []string{rel("tmp/pids/server.pid")}},
+		{opts: Options{Exec: []string{"bin/rails", "server"}}, want: []string{rel("tmp/pids/server.pid")}},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, pf := range detectPIDFiles(c.command, dir, c.env, c.opts) {
+			got = append(got, pf.path)
+		}
+		if !slices.Equal(got, c.want) {
+			t.Errorf("%q: got pidfiles %q, want %q", c.command, got, c.want)
+		}
+	}
+}
+
+func TestCleanupPIDFile(t *testing.T) {
+	dir := t.TempDir()
+	var logged []string
+	log := func(format string, args ...any) { logged = append(logged, fmt.Sprintf(format, args...)) }
+	path := filepath.Join(dir, "server.pid")
+
+	t.Run("removes invalid and stale pidfiles", func(t *testing.T) {
+		for _, content := range []string{"garbage", "0", "999999999"} {
+			os.WriteFile(path, []byte(content), 0644)
+			cleanupPIDFile(pidfile{path, "Rails"}, "web-myapp", nil, log)
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("%q: expected pidfile removed", content)
+			}
+		}
+	})
+
+	t.Run("kills an orphan", func(t *testing.T) {
+		orphan := exec.Command("sleep", "30")
+		orphan.Env = []string{MarkerEnv + "=web-myapp"}
+		if err := orphan.Start(); err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan struct{})
+		go func() { orphan.Wait(); close(done) }()
+		os.WriteFile(path, []byte(strconv.Itoa(orphan.Process.Pid)+"\n"), 0644)
+
+		o, ok := cleanupPIDFile(pidfile{path, "Puma"}, "web-myapp", nil, log)
+		if !ok || o.pid != orphan.Process.Pid {
+			orphan.Process.Kill()
+			t.Fatalf("expected pid %d to be found as an orphan, got %+v", orphan.Process.Pid, o)
+		}
+		stopOrphan(o, log)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			orphan.Process.Kill()
+			t.Fatal("expected orphan to be killed")
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error("expected pidfile removed")
+		}
+		if last := logged[len(logged)-1]; !strings.Contains(last, "Killing orphaned Puma process") {
+			t.Errorf("expected kill to be logged, got %q", last)
+		}
+	})
+
+	t.Run("leaves managed processes alone", func(t *testing.T) {
+		proc := exec.Command("sleep", "30")
+		proc.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if err := proc.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer func() { proc.Process.Kill(); proc.Wait() }()
+		os.WriteFile(path, []byte(strconv.Itoa(proc.Process.Pid)), 0644)
+
+		if _, ok := cleanupPIDFile(pidfile{path, "Rails"}, "web-myapp", map[int]string{proc.Process.Pid: "web-myapp"}, log); ok {
+			t.Error("expected managed process not to be an orphan")
+		}
+		if syscall.Kill(proc.Process.Pid, 0) != nil {
+			t.Error("expected managed process to keep running")
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Error("expected pidfile kept")
+		}
+	})
+
+	t.Run("leaves processes roost-dev didn't start alone", func(t *testing.T) {
+		for _, env := range [][]string{nil, {MarkerEnv + "=api-myapp"}} {
+			other := exec.Command("sleep", "30")
+			other.Env = env
+			if err := other.Start(); err != nil {
+				t.Fatal(err)
+			}
+			defer func() { other.Process.Kill(); other.Wait() }()
+			os.WriteFile(path, []byte(strconv.Itoa(other.Process.Pid)), 0644)
+
+			if _, ok := cleanupPIDFile(pidfile{path, "Rails"}, "web-myapp", nil, log); ok {
+				t.Errorf("%v: expected pid %d not to be an orphan", env, other.Process.Pid)
+			}
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("%v: expected pidfile kept", env)
+			}
+			if last := logged[len(logged)-1]; !strings.Contains(last, "not started by roost-dev") {
+				t.Errorf("%v: expected it to be logged, got %q", env, last)
+			}
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package process
+
+import "time"
+
+// Metrics is the resource use of a process and everything it started, as of
+// the last Manager.SampleMetrics
+type Metrics struct {
+	CPU      float64   // Percent of one core used since the previous sample
+	RSS      int64     // Resident memory in bytes
+	Threads  int       // 0 where the platform doesn't report it
+	Children int       // Processes besides the main one
+	Sampled  time.Time // Zero if the process hasn't been sampled yet
+}
+
+// SampleMetrics updates the metrics of every running process from one
+// snapshot of the process table
+func (m *Manager) SampleMetrics() error {
+	table, err := snapshotProcs()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, proc := range m.All() {
+		pid, ok := proc.Pid()
+		if !ok {
+			continue
+		}
+		tree := table.tree(pid)
+		if tree == nil {
+			continue
+		}
+		proc.recordMetrics(tree, now)
+	}
+	return nil
+}
+
+// recordMetrics sums up tree and works out CPU use since the last sample
+func (p *Process) recordMetrics(tree *ProcTree, now time.Time) {
+	var sample Metrics
+	var cpuTime time.Duration
+	tree.Walk(func(node *ProcTree, depth int) {
+		sample.RSS += node.RSS
+		sample.Threads += node.Threads
+		cpuTime += node.CPUTime
+		if depth > 0 {
+			sample.Children++
+		}
+	})
+	sample.Sampled = now
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if prev := p.metrics.Sampled; !prev.IsZero() {
+		// Children that exited take their CPU time with them, so the total
+		// can go down; count that as idle rather than negative
+		if used := cpuTime - p.cpuTime; used > 0 {
+			sample.CPU = float64(used) / float64(now.Sub(prev)) * 100
+		}
+	}
+	p.metrics = sample
+	p.cpuTime = cpuTime
+}
+
+// Metrics returns the resource use at the last sample
+func (p *Process) Metrics() Metrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.metrics
+}
+
+// Pid returns the pid of the running command, which leads its process group
+func (p *Process) Pid() (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil || p.cmd.Process == nil || p.cmd.ProcessState != nil || p.gone {
+		return 0, false
+	}
+	return p.cmd.Process.Pid, true
+}
+
+// Trees returns the process tree of every running process, by name
+func (m *Manager) Trees() (map[string]*ProcTree, error) {
+	table, err := snapshotProcs()
+	if err != nil {
+		return nil, err
+	}
+	trees := make(map[string]*ProcTree)
+	for _, proc := range m.All() {
+		if pid, ok := proc.Pid(); ok {
+			if tree := table.tree(pid); tree != nil {
+				trees[proc.Name] = tree
+			}
+		}
+	}
+	return trees, nil
+}
@@ -0,0 +1,380 @@
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// orphanStopTimeout is how long a process left holding a pidfile gets to
+// exit after SIGTERM before it's killed
+const orphanStopTimeout = 2 * time.Second
+
+// pidfile is a file a server writes its pid to, which a crash or an unclean
+// roost-dev exit leaves behind
+type pidfile struct {
+	path      string // Relative to the process's dir, or absolute
+	framework string // For log messages
+}
+
+// pidfileDetector finds the pidfiles a framework's server writes, given the
+// words of its command, its directory and its environment
+type pidfileDetector func(args []string, dir string, env map[string]string) []pidfile
+
+// pidfileDetectors are checked for every command, in order, and for each
+// command in a Procfile that foreman runs
+var pidfileDetectors = []pidfileDetector{
+	railsPIDFiles,
+	pumaPIDFiles,
+	unicornPIDFiles,
+	phoenixPIDFiles,
+	djangoPIDFiles,
+	gunicornPIDFiles,
+}
+
+// detectPIDFiles returns the absolute paths of the pidfiles command may
+// write: the configured one first, then whatever the detectors find
+func detectPIDFiles(command, dir string, env map[string]string, opts Options) []pidfile {
+	if command == "" {
+		command = strings.Join(opts.Exec, " ")
+	}
+	args := strings.Fields(command)
+
+	var found []pidfile
+	if opts.PIDFile != "" {
+		found = append(found, pidfile{opts.PIDFile, "configured"})
+	}
+	for _, detect := range pidfileDetectors {
+		found = append(found, detect(args, dir, env)...)
+	}
+	found = append(found, foremanPIDFiles(args, dir, env)...)
+
+	var unique []pidfile
+	seen := make(map[string]bool)
+	for _, pf := range found {
+		pf.path = filepath.Clean(resolvePath(dir, pf.path))
+		if !seen[pf.path] {
+			seen[pf.path] = true
+			unique = append(unique, pf)
+		}
+	}
+	return unique
+}
+
+// railsPIDFiles: rails server writes tmp/pids/server.pid unless told otherwise
+func railsPIDFiles(args []string, dir string, env map[string]string) []pidfile {
+	i := programIndex(args, "rails")
+	if i < 0 || i+1 >= len(args) || (args[i+1] != "s" && args[i+1] != "server") {
+		return nil
+	}
+	path := flagValue(args[i+2:], "-P", "--pid")
+	if path == "" {
+		path = env["PIDFILE"]
+	}
+	if path == "" {
+		path = filepath.Join("tmp", "pids", "server.pid")
+	}
+	return []pidfile{{path, "Rails"}}
+}
+
+// pumaPIDFiles: puma -P/--pidfile, or the pidfile directive in its config
+func pumaPIDFiles(args []string, dir string, env map[string]string) []pidfile {
+	i := programIndex(args, "puma")
+	if i < 0 {
+		return nil
+	}
+	if path := flagValue(args[i+1:], "-P", "--pidfile"); path != "" {
+		return []pidfile{{path, "Puma"}}
+	}
+	config := flagValue(args[i+1:], "-C", "--config")
+	if config == "" {
+		config = filepath.Join("config", "puma.rb")
+	}
+	if path := rubyDirective(filepath.Join(dir, config), "pidfile", env); path != "" {
+		return []pidfile{{path, "Puma"}}
+	}
+	return nil
+}
+
+// unicornPIDFiles: the pid directive in unicorn's config
+func unicornPIDFiles(args []string, dir string, env map[string]string) []pidfile {
+	i := programIndex(args, "unicorn", "unicorn_rails")
+	if i < 0 {
+		return nil
+	}
+	config := flagValue(args[i+1:], "-c", "--config-file")
+	if config == "" {
+		config = filepath.Join("config", "unicorn.rb")
+	}
+	if path := rubyDirective(filepath.Join(dir, config), "pid", env); path != "" {
+		return []pidfile{{path, "Unicorn"}}
+	}
+	return nil
+}
+
+// phoenixPIDFiles: an Elixir release (bin/<app> next to releases/) started
+// with PIDFILE set writes its pid there
+func phoenixPIDFiles(args []string, dir string, env map[string]string) []pidfile {
+	if env["PIDFILE"] == "" {
+		return nil
+	}
+	for i, arg := range args[:max(len(args)-1, 0)] {
+		switch args[i+1] {
+		case "start", "start_iex", "daemon", "daemon_iex", "foreground":
+		default:
+			continue
+		}
+		binDir := filepath.Dir(arg)
+		if filepath.Base(binDir) != "bin" {
+			continue
+		}
+		releases := filepath.Join(filepath.Dir(binDir), "releases")
+		if !filepath.IsAbs(releases) {
+			releases = filepath.Join(dir, releases)
+		}
+		if info, err := os.Stat(releases); err == nil && info.IsDir() {
+			return []pidfile{{env["PIDFILE"], "Phoenix"}}
+		}
+	}
+	return nil
+}
+
+// djangoPIDFiles: manage.py commands that take --pidfile (runserver_plus,
+// runfcgi and friends), or runfcgi's pidfile=
+func djangoPIDFiles(args []string, dir string, env map[string]string) []pidfile {
+	i := programIndex(args, "manage.py", "django-admin")
+	if i < 0 {
+		return nil
+	}
+	path := flagValue(args[i+1:], "--pidfile")
+	for _, arg := range args[i+1:] {
+		if value, ok := strings.CutPrefix(arg, "pidfile="); ok && path == "" {
+			path = value
+		}
+	}
+	if path == "" {
+		return nil
+	}
+	return []pidfile{{path, "Django"}}
+}
+
+// gunicornPIDFiles: gunicorn -p/--pid
+func gunicornPIDFiles(args []string, dir string, env map[string]string) []pidfile {
+	i := programIndex(args, "gunicorn")
+	if i < 0 {
+		return nil
+	}
+	if path := flagValue(args[i+1:], "-p", "--pid"); path != "" {
+		return []pidfile{{path, "Gunicorn"}}
+	}
+	return nil
+}
+
+// foremanPIDFiles runs the other detectors over each command in the Procfile
+// that foreman start (or a bin/dev that runs foreman) starts
+func foremanPIDFiles(args []string, dir string, env map[string]string) []pidfile {
+	var procfile string
+	if i := programIndex(args, "foreman"); i >= 0 && i+1 < len(args) && args[i+1] == "start" {
+		procfile = flagValue(args[i+2:], "-f", "--procfile")
+		if procfile == "" {
+			procfile = "Procfile"
+		}
+		if root := flagValue(args[i+2:], "-d", "--root"); root != "" {
+			dir = resolvePath(dir, root)
+		}
+	} else if slices.Contains(args, "bin/dev") {
+		script, err := os.ReadFile(filepath.Join(dir, "bin", "dev"))
+		if err != nil || !strings.Contains(string(script), "foreman") {
+			return nil
+		}
+		procfile = "Procfile.dev"
+	} else {
+		return nil
+	}
+
+	f, err := os.Open(resolvePath(dir, procfile))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var found []pidfile
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		_, command, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.HasPrefix(strings.TrimSpace(scanner.Text()), "#") {
+			continue
+		}
+		entryArgs := strings.Fields(command)
+		for _, detect := range pidfileDetectors {
+			for _, pf := range detect(entryArgs, dir, env) {
+				found = append(found, pidfile{resolvePath(dir, pf.path), pf.framework})
+			}
+		}
+	}
+	return found
+}
+
+// programIndex returns the index of the first word that runs one of names
+// (by base name, so bin/rails counts as rails), or -1
+func programIndex(args []string, names ...string) int {
+	for i, arg := range args {
+		if slices.Contains(names, filepath.Base(arg)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// flagValue returns the value given for any of names in args, as "-P path",
+// "--pid path", "--pid=path" or, for one-letter flags, "-Ppath"
+func flagValue(args []string, names ...string) string {
+	for i, arg := range args {
+		for _, name := range names {
+			switch {
+			case arg == name && i+1 < len(args):
+				return args[i+1]
+			case strings.HasPrefix(name, "--") && strings.HasPrefix(arg, name+"="):
+				return arg[len(name)+1:]
+			case len(name) == 2 && len(arg) > 2 && strings.HasPrefix(arg, name):
+				return arg[2:]
+			}
+		}
+	}
+	return ""
+}
+
+var (
+	rubyString = regexp.MustCompile(`["']([^"']+)["']`)
+	rubyEnvRef = regexp.MustCompile(`ENV(?:\.fetch\(|\[)\s*["'](\w+)["']`)
+)
+
+// rubyDirective returns the path given to a directive such as
+// `pidfile "tmp/pids/puma.pid"` in a Ruby config file. A default read from
+// ENV.fetch("NAME") { "..." } or ENV["NAME"] uses env when NAME is set.
+func rubyDirective(path, directive string, env map[string]string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	value := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		rest, ok := strings.CutPrefix(line, directive)
+		if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '(') {
+			continue
+		}
+		if ref := rubyEnvRef.FindStringSubmatch(rest); ref != nil && env[ref[1]] != "" {
+			value = env[ref[1]]
+			continue
+		}
+		strs := rubyString.FindAllStringSubmatch(rest, -1)
+		if len(strs) > 0 {
+			value = strs[len(strs)-1][1] // After the ENV name, if any
+		}
+	}
+	return value
+}
+
+// resolvePath returns path relative to dir unless it's absolute
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// orphan is a process a previous run left holding a pidfile
+type orphan struct {
+	pidfile
+	pid int
+}
+
+// cleanupPIDFiles removes the pidfiles a previous run of proc left behind,
+// which make servers like Rails refuse to start. It returns the processes
+// they name that roost-dev started as proc and are still running, for
+// stopOrphans to kill. Pidfiles naming a process the manager runs, or one
+// roost-dev didn't start, are left alone. Caller must hold m.mu.
+func (m *Manager) cleanupPIDFiles(proc *Process) []orphan {
+	pidfiles := detectPIDFiles(proc.Command, proc.Dir, proc.Env, proc.opts)
+	if len(pidfiles) == 0 {
+		return nil
+	}
+	managed := make(map[int]string)
+	for name, other := range m.processes {
+		if pid, ok := other.Pid(); ok {
+			managed[pid] = name // Each process leads its own group
+		}
+	}
+	var orphans []orphan
+	for _, pf := range pidfiles {
+		if o, ok := cleanupPIDFile(pf, proc.Name, managed, proc.pidfileLog); ok {
+			orphans = append(orphans, o)
+		}
+	}
+	return orphans
+}
+
+// stopOrphans kills the orphans cleanupPIDFiles found and removes their
+// pidfiles. It waits for them to exit, so the caller mustn't hold m.mu.
+func (p *Process) stopOrphans(orphans []orphan) {
+	for _, o := range orphans {
+		stopOrphan(o, p.pidfileLog)
+	}
+}
+
+// pidfileLog logs pidfile clean-up to the process's logs and stdout
+func (p *Process) pidfileLog(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	p.logs.Write([]byte("[roost-dev] " + msg + "\n"))
+	fmt.Printf("[roost-dev] %s: %s\n", p.Name, msg)
+}
+
+// cleanupPIDFile deals with one pidfile of the process name; see
+// Manager.cleanupPIDFiles. It returns the orphan to stop, if any.
+func cleanupPIDFile(pf pidfile, name string, managed map[int]string, log func(format string, args ...any)) (orphan, bool) {
+	data, err := os.ReadFile(pf.path)
+	if err != nil {
+		return orphan{}, false // No pidfile, nothing to clean up
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		os.Remove(pf.path)
+		log("Removed invalid %s pidfile %s", pf.framework, pf.path)
+		return orphan{}, false
+	}
+	if err := syscall.Kill(pid, 0); err == syscall.ESRCH {
+		os.Remove(pf.path)
+		log("Removed stale %s pidfile %s (pid %d not running)", pf.framework, pf.path, pid)
+		return orphan{}, false
+	}
+	if pgid, err := syscall.Getpgid(pid); err == nil {
+		if owner, ok := managed[pgid]; ok {
+			log("Leaving %s pidfile %s alone: pid %d belongs to %s", pf.framework, pf.path, pid, owner)
+			return orphan{}, false
+		}
+	}
+	// A server started by hand, or something that reused the pid
+	if !hasMarker(pid, name) {
+		log("%s pidfile %s names pid %d, not started by roost-dev; leaving it alone", pf.framework, pf.path, pid)
+		return orphan{}, false
+	}
+	return orphan{pf, pid}, true
+}
+
+// stopOrphan kills a process an earlier roost-dev left holding a pidfile, so
+// we can start fresh, and removes the pidfile
+func stopOrphan(o orphan, log func(format string, args ...any)) {
+	log("Killing orphaned %s process (pid %d from %s)", o.framework, o.pid, o.path)
+	syscall.Kill(o.pid, syscall.SIGTERM)
+	if !waitForExit(o.pid, orphanStopTimeout) {
+		syscall.Kill(o.pid, syscall.SIGKILL)
+	}
+	os.Remove(o.path)
+}
@@ -0,0 +1,230 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SetPortsFile loads sticky port assignments from path and saves them there
+// whenever a process gets a new port, so ports survive roost-dev restarts
+func (m *Manager) SetPortsFile(path string) error {
+	ports, err := LoadPortAssignments(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.portsFile = path
+	for name, port := range ports {
+		m.stickyPorts[name] = port
+	}
+	return nil
+}
+
+// LoadPortAssignments reads the last port used by each process name
+func LoadPortAssignments(path string) (map[string]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ports := make(map[string]int)
+	if err := json.Unmarshal(data, &ports); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return ports, nil
+}
+
+// allocatePort reserves a port for a process: the preferred port if set and
+// free, else the port it used last time if free, else any free port. The
+// port is remembered for next time. Caller must hold m.mu.
+func (m *Manager) allocatePort(name string, preferred int) (int, error) {
+	port, err := m.choosePort(name, preferred)
+	if err != nil {
+		return 0, err
+	}
+	m.rememberPort(name, port)
+	return port, nil
+}
+
+// choosePort is allocatePort without remembering the port. Caller must hold
+// m.mu.
+func (m *Manager) choosePort(name string, preferred int) (int, error) {
+	if preferred != 0 {
+		if m.reservePort(preferred) {
+			return preferred, nil
+		}
+		fmt.Printf("[roost-dev] Preferred port %d for %s is in use\n", preferred, name)
+	}
+
+	if last := m.stickyPorts[name]; last != 0 && last != preferred {
+		if m.reservePort(last) {
+			return last, nil
+		}
+		fmt.Printf("[roost-dev] Last port %d for %s is in use\n", last, name)
+	}
+
+	return m.findFreePort()
+}
+
+// allocateNamedPorts reserves one port per name for a process, remembering
+// each under "<process>:<name>" so they are sticky too. If any allocation
+// fails, the ports reserved so far are released. Caller must hold m.mu.
+func (m *Manager) allocateNamedPorts(name string, portNames []string) (map[string]int, error) {
+	if len(portNames) == 0 {
+		return nil, nil
+	}
+	ports := make(map[string]int, len(portNames))
+	for _, portName := range portNames {
+		port, err := m.allocatePort(name+":"+portName, 0)
+		if err != nil {
+			for _, p := range ports {
+				m.releasePort(p)
+			}
+			return nil, fmt.Errorf("allocating %s port: %w", portName, err)
+		}
+		ports[portName] = port
+	}
+	return ports, nil
+}
+
+// reserveAssignedPorts reserves exactly port and the named ports, for a
+// process whose env and exec args refer to them. Caller must hold m.mu.
+func (m *Manager) reserveAssignedPorts(port int, named map[string]int) (int, map[string]int, error) {
+	var held []int
+	release := func() {
+		for _, p := range held {
+			m.releasePort(p)
+		}
+	}
+	if port != 0 {
+		if !m.reservePort(port) {
+			return 0, nil, fmt.Errorf("port %d is in use, and the process's config refers to it", port)
+		}
+		held = append(held, port)
+	}
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var ports map[string]int
+	if len(named) > 0 {
+		ports = make(map[string]int, len(named))
+	}
+	for _, name := range names {
+		if !m.reservePort(named[name]) {
+			release()
+			return 0, nil, fmt.Errorf("%s port %d is in use, and the process's config refers to it", name, named[name])
+		}
+		held = append(held, named[name])
+		ports[name] = named[name]
+	}
+	return port, ports, nil
+}
+
+// PortEnvName returns the environment variable for a named port (livereload → PORT_LIVERELOAD)
+func PortEnvName(portName string) string {
+	return "PORT_" + strings.ToUpper(strings.ReplaceAll(portName, "-", "_"))
+}
+
+// AssignPorts returns the port each process (name → preferred port) has or
+// will get when it next starts, so processes can learn each other's ports
+// before they are all up. "<process>:<name>" names a named port. A running
+// process keeps its ports; others get their preferred, last or a free port,
+// remembered so spawn picks the same one.
+func (m *Manager) AssignPorts(preferred map[string]int) (map[string]int, error) {
+	return m.assignPorts(preferred, true)
+}
+
+// PredictPorts is AssignPorts without remembering the ports, for showing
+// what processes would get without claiming ports for them
+func (m *Manager) PredictPorts(preferred map[string]int) (map[string]int, error) {
+	return m.assignPorts(preferred, false)
+}
+
+func (m *Manager) assignPorts(preferred map[string]int, remember bool) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(preferred))
+	for name := range preferred {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ports := make(map[string]int, len(names))
+	var held []int
+	for _, name := range names {
+		procName, portName, named := strings.Cut(name, ":")
+		if p, exists := m.processes[procName]; exists && (p.IsRunning() || p.IsStarting()) {
+			if named {
+				ports[name] = p.Ports[portName]
+			} else {
+				ports[name] = p.Port
+			}
+			continue
+		}
+		allocate := m.choosePort
+		if remember {
+			allocate = m.allocatePort
+		}
+		port, err := allocate(name, preferred[name])
+		if err != nil {
+			for _, p := range held {
+				m.releasePort(p)
+			}
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		held = append(held, port)
+		ports[name] = port
+	}
+	// Only remembered, not held: spawn reserves them again when the processes
+	// start. Held until now so no two processes get the same one.
+	for _, p := range held {
+		m.releasePort(p)
+	}
+	return ports, nil
+}
+
+// LastPort returns the port the named process (or "<process>:<name>" for a
+// named port) last got, or 0, without assigning one
+func (m *Manager) LastPort(name string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.stickyPorts[name]
+}
+
+// rememberPort records the port a process got and saves the assignments.
+// Caller must hold m.mu.
+func (m *Manager) rememberPort(name string, port int) {
+	if m.stickyPorts[name] == port {
+		return
+	}
+	m.stickyPorts[name] = port
+	if m.portsFile == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(m.stickyPorts, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(m.portsFile, append(data, '\n'), 0644); err != nil {
+		fmt.Printf("[roost-dev] Failed to save port assignments: %v\n", err)
+	}
+}
+
+// stickyOwner returns the process name that last used port, if any.
+// Caller must hold m.mu.
+func (m *Manager) stickyOwner(port int) string {
+	for name, p := range m.stickyPorts {
+		if p == port {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,196 @@
+package process
+
+import (
+	"bufio"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProcInfo is one OS process as seen in a snapshot of the process table
+type ProcInfo struct {
+	PID     int           `json:"pid"`
+	PPID    int           `json:"ppid"`
+	PGID    int           `json:"pgid"`
+	Command string        `json:"command"`
+	RSS     int64         `json:"rss"`               // Resident memory in bytes
+	Threads int           `json:"threads,omitempty"` // 0 where the platform doesn't report it
+	CPUTime time.Duration `json:"-"`                 // User plus system time so far
+}
+
+// ProcTree is a process with the processes it started
+type ProcTree struct {
+	ProcInfo
+	Children []*ProcTree `json:"children,omitempty"`
+}
+
+// procTable is a snapshot of every process, by pid
+type procTable map[int]ProcInfo
+
+// tree returns the processes belonging to the group led by pid: its
+// descendants, plus anything left in its process group whose parent exited.
+// Returns nil if pid isn't running.
+func (t procTable) tree(pid int) *ProcTree {
+	root, ok := t[pid]
+	if !ok {
+		return nil
+	}
+	children := make(map[int][]int)
+	for _, p := range t {
+		children[p.PPID] = append(children[p.PPID], p.PID)
+	}
+
+	nodes := map[int]*ProcTree{pid: {ProcInfo: root}}
+	var walk func(node *ProcTree)
+	walk = func(node *ProcTree) {
+		kids := children[node.PID]
+		slices.Sort(kids)
+		for _, kid := range kids {
+			if _, seen := nodes[kid]; seen {
+				continue
+			}
+			child := &ProcTree{ProcInfo: t[kid]}
+			nodes[kid] = child
+			node.Children = append(node.Children, child)
+			walk(child)
+		}
+	}
+	walk(nodes[pid])
+
+	// Orphans reparented to init still share the group's pgid
+	var orphans []int
+	for _, p := range t {
+		if _, seen := nodes[p.PID]; !seen && p.PGID == root.PGID {
+			if _, parentInTree := nodes[p.PPID]; !parentInTree {
+				orphans = append(orphans, p.PID)
+			}
+		}
+	}
+	slices.Sort(orphans)
+	for _, orphan := range orphans {
+		if _, seen := nodes[orphan]; seen {
+			continue
+		}
+		child := &ProcTree{ProcInfo: t[orphan]}
+		nodes[orphan] = child
+		nodes[pid].Children = append(nodes[pid].Children, child)
+		walk(child)
+	}
+	return nodes[pid]
+}
+
+// Walk calls fn for every process in the tree, parents before children
+func (t *ProcTree) Walk(fn func(p *ProcTree, depth int)) {
+	var walk func(node *ProcTree, depth int)
+	walk = func(node *ProcTree, depth int) {
+		fn(node, depth)
+		for _, child := range node.Children {
+			walk(child, depth+1)
+		}
+	}
+	walk(t, 0)
+}
+
+// pids returns the pids in the tree, children before their parents
+func (t *ProcTree) pids() []int {
+	var pids []int
+	var walk func(node *ProcTree)
+	walk = func(node *ProcTree) {
+		for _, child := range node.Children {
+			walk(child)
+		}
+		pids = append(pids, node.PID)
+	}
+	walk(t)
+	return pids
+}
+
+// parseProcStat parses /proc/<pid>/stat. The command name is in parentheses
+// and may itself contain spaces and parentheses, so fields are counted from
+// the last ')'. clockTick is the kernel's USER_HZ.
+func parseProcStat(data string, clockTick int64, pageSize int64) (ProcInfo, bool) {
+	open := strings.IndexByte(data, '(')
+	end := strings.LastIndexByte(data, ')')
+	if open < 0 || end < open {
+		return ProcInfo{}, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(data[:open]))
+	if err != nil {
+		return ProcInfo{}, false
+	}
+	// Fields after the name, starting with state (field 3 in proc(5))
+	fields := strings.Fields(data[end+1:])
+	if len(fields) < 22 {
+		return ProcInfo{}, false
+	}
+	num := func(i int) int64 {
+		n, _ := strconv.ParseInt(fields[i], 10, 64)
+		return n
+	}
+	ticks := num(11) + num(12) // utime + stime
+	return ProcInfo{
+		PID:     pid,
+		PPID:    int(num(1)),
+		PGID:    int(num(2)),
+		Command: data[open+1 : end],
+		Threads: int(num(17)),
+		RSS:     num(21) * pageSize,
+		CPUTime: time.Duration(ticks) * time.Second / time.Duration(clockTick),
+	}, true
+}
+
+// parsePS parses the output of ps -axo pid=,ppid=,pgid=,rss=,time=,command=
+func parsePS(out string) procTable {
+	procs := make(procTable)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		var nums [4]int64
+		ok := true
+		for i := range nums {
+			n, err := strconv.ParseInt(fields[i], 10, 64)
+			if err != nil {
+				ok = false
+				break
+			}
+			nums[i] = n
+		}
+		if !ok {
+			continue
+		}
+		procs[int(nums[0])] = ProcInfo{
+			PID:     int(nums[0]),
+			PPID:    int(nums[1]),
+			PGID:    int(nums[2]),
+			RSS:     nums[3] * 1024,
+			CPUTime: parsePSTime(fields[4]),
+			Command: strings.Join(fields[5:], " "),
+		}
+	}
+	return procs
+}
+
+// parsePSTime parses a ps cumulative time: [[dd-]hh:]mm:ss[.cc]
+func parsePSTime(s string) time.Duration {
+	var total time.Duration
+	if days, rest, ok := strings.Cut(s, "-"); ok {
+		d, _ := strconv.Atoi(days)
+		total += time.Duration(d) * 24 * time.Hour
+		s = rest
+	}
+	parts := strings.Split(s, ":")
+	units := []time.Duration{time.Second, time.Minute, time.Hour}
+	for i := range parts {
+		part := parts[len(parts)-1-i]
+		if i >= len(units) {
+			break
+		}
+		n, _ := strconv.ParseFloat(part, 64)
+		total += time.Duration(n * float64(units[i]))
+	}
+	return total
+}
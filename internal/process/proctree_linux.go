@@ -0,0 +1,51 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// clockTick is USER_HZ, the unit of CPU times in /proc, which is 100 on
+// every architecture Go supports
+const clockTick = 100
+
+// snapshotProcs reads the process table from /proc
+func snapshotProcs() (procTable, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	pageSize := int64(os.Getpagesize())
+	procs := make(procTable)
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		dir := filepath.Join("/proc", entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, "stat"))
+		if err != nil {
+			continue // Exited since the directory was listed
+		}
+		info, ok := parseProcStat(string(data), clockTick, pageSize)
+		if !ok {
+			continue
+		}
+		// Prefer the full command line; kernel threads have none
+		if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(cmdline) > 0 {
+			info.Command = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+		}
+		procs[info.PID] = info
+	}
+	return procs, nil
+}
+
+// processEnviron returns the environment a process started with
+func processEnviron(pid int) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "environ"))
+	if err != nil {
+		return nil, err
+	}
+	return parseEnv0(data), nil
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+
+package process
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// snapshotProcs reads the process table with ps, which doesn't report
+// thread counts portably
+func snapshotProcs() (procTable, error) {
+	out, err := exec.Command("ps", "-axo", "pid=,ppid=,pgid=,rss=,time=,command=").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parsePS(string(out)), nil
+}
+
+// processEnviron returns the words of a process's command line and
+// environment, which ps -E appends for processes of the same user. Values
+// with spaces come out split, which is fine for matching MarkerEnv.
+func processEnviron(pid int) ([]string, error) {
+	out, err := exec.Command("ps", "-E", "-ww", "-o", "command=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
@@ -0,0 +1,132 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Readiness check types for ReadyCheck.Type
+const (
+	ReadyTCP  = "tcp"
+	ReadyHTTP = "http"
+	ReadyExec = "exec"
+	ReadyFile = "file"
+	ReadyLog  = "log"
+	ReadyNone = "none"
+)
+
+const (
+	// readyInterval is how often readiness is checked while a process starts
+	readyInterval = 500 * time.Millisecond
+
+	// readyAttemptTimeout bounds a single HTTP request or exec check
+	readyAttemptTimeout = 2 * time.Second
+)
+
+// ansiEscape matches terminal color codes, which FORCE_COLOR adds to many logs
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// ReadyCheck decides when a started process can receive traffic.
+// The zero value waits for the process to accept TCP connections on its port.
+type ReadyCheck struct {
+	Type    string         // ReadyTCP (default), ReadyHTTP, ReadyExec, ReadyFile, ReadyLog or ReadyNone
+	Path    string         // URL path (ReadyHTTP) or file to wait for (ReadyFile, relative to the process dir)
+	Status  int            // Expected HTTP status (0 = any status below 400)
+	Port    int            // Port to check instead of the assigned one (ReadyTCP, ReadyHTTP)
+	Command string         // Command that exits 0 once the process is ready (ReadyExec)
+	Pattern *regexp.Regexp // Log line that signals readiness (ReadyLog)
+	Timeout time.Duration  // Mark the process failed if it is not ready by then (0 = wait forever)
+}
+
+// check runs the readiness check once and returns nil if the process is ready.
+// logMark is the log position at spawn, so output from a previous run of the
+// process doesn't count.
+func (c ReadyCheck) check(p *Process, logMark int) error {
+	port := p.Port
+	if c.Port != 0 {
+		port = c.Port
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+
+	switch c.Type {
+	case ReadyNone:
+		return nil
+
+	case ReadyHTTP:
+		client := &http.Client{
+			Timeout: readyAttemptTimeout,
+			// A redirect means the app is serving; don't follow it off to https or another host
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		resp, err := client.Get("http://" + addr + c.httpPath())
+		if err != nil {
+			return fmt.Errorf("GET %s: %w", c.httpPath(), err)
+		}
+		resp.Body.Close()
+		if c.Status != 0 && resp.StatusCode != c.Status {
+			return fmt.Errorf("GET %s returned %d, expected %d", c.httpPath(), resp.StatusCode, c.Status)
+		}
+		if c.Status == 0 && resp.StatusCode >= 400 {
+			return fmt.Errorf("GET %s returned %d", c.httpPath(), resp.StatusCode)
+		}
+		return nil
+
+	case ReadyExec:
+		ctx, cancel := context.WithTimeout(context.Background(), readyAttemptTimeout)
+		defer cancel()
+		cmd := shellCommand(ctx, p.opts.hookShell(), c.Command)
+		cmd.Dir = p.Dir
+		cmd.Env = p.cmd.Env
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %w", c.Command, err)
+		}
+		return nil
+
+	case ReadyFile:
+		path := c.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.Dir, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("waiting for %s", path)
+		}
+		// A file left over from a previous run doesn't count
+		if info.ModTime().Before(p.started.Truncate(time.Second)) {
+			return fmt.Errorf("waiting for %s to be touched", path)
+		}
+		return nil
+
+	case ReadyLog:
+		for _, line := range p.logs.LinesSince(logMark) {
+			if c.Pattern.MatchString(ansiEscape.ReplaceAllString(line, "")) {
+				return nil
+			}
+		}
+		return fmt.Errorf("waiting for log line matching %q", c.Pattern)
+
+	default:
+		conn, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err != nil {
+			return fmt.Errorf("waiting for port %d", port)
+		}
+		conn.Close()
+		return nil
+	}
+}
+
+// httpPath returns the path for HTTP checks, defaulting to /
+func (c ReadyCheck) httpPath() string {
+	if c.Path == "" {
+		return "/"
+	}
+	return c.Path
+}
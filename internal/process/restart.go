@@ -0,0 +1,68 @@
+package process
+
+import "time"
+
+// Restart modes for RestartPolicy.Mode
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+const (
+	defaultMaxRetries = 5
+	defaultBackoff    = 1 * time.Second
+	defaultMaxBackoff = 30 * time.Second
+
+	// crashLoopWindow is how long a process must stay up before its
+	// consecutive crash count is reset
+	crashLoopWindow = 1 * time.Minute
+)
+
+// RestartPolicy controls whether a process is restarted after it exits on its own
+type RestartPolicy struct {
+	Mode       string        // RestartNever (default), RestartOnFailure, or RestartAlways
+	MaxRetries int           // Consecutive crashes tolerated before giving up (0 = default)
+	Backoff    time.Duration // Delay before the first restart, doubled on each attempt (0 = default)
+	MaxBackoff time.Duration // Upper bound for the delay (0 = default)
+}
+
+// shouldRestart reports whether a process that exited with err should be restarted
+func (p RestartPolicy) shouldRestart(err error) bool {
+	switch p.Mode {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+func (p RestartPolicy) maxRetries() int {
+	if p.MaxRetries > 0 {
+		return p.MaxRetries
+	}
+	return defaultMaxRetries
+}
+
+// delay returns the exponential backoff before the given restart attempt (1-based)
+func (p RestartPolicy) delay(attempt int) time.Duration {
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	d := backoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
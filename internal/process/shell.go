@@ -0,0 +1,192 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Shell modes for Options.Shell. Any other value is the path of a shell to
+// run commands with, like ShellPlain.
+const (
+	ShellLogin = "login" // $SHELL -i -l -c, so rc files set up rbenv, nvm, etc. (default for Command)
+	ShellPlain = "plain" // $SHELL -c with the login environment resolved at startup
+	ShellNone  = "none"  // Exec runs directly with the login environment (default for Exec)
+)
+
+// loginEnvTimeout bounds resolving the login environment; a shell profile
+// that waits for input shouldn't hang roost-dev
+const loginEnvTimeout = 10 * time.Second
+
+// loginEnvMarker separates shell startup noise from the env output
+const loginEnvMarker = "__ROOST_DEV_ENV__"
+
+var (
+	loginEnvOnce sync.Once
+	loginEnv     []string
+)
+
+// LoginEnv returns the environment an interactive login shell sets up
+// (PATH with version managers and so on), resolved once and reused, so
+// processes can skip starting a login shell each time. It falls back to
+// roost-dev's own environment if the shell fails.
+func LoginEnv() []string {
+	loginEnvOnce.Do(func() {
+		env, err := resolveLoginEnv(getUserShell())
+		if err != nil {
+			fmt.Printf("[roost-dev] Couldn't resolve login environment, using roost-dev's: %v\n", err)
+			env = os.Environ()
+		}
+		loginEnv = env
+	})
+	return slices.Clone(loginEnv)
+}
+
+// resolveLoginEnv runs shell as an interactive login shell and returns the
+// environment it ends up with
+func resolveLoginEnv(shell string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), loginEnvTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, shell, "-i", "-l", "-c", "printf '%s' "+loginEnvMarker+"; env -0")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	// rc files may print banners before the marker
+	_, out, ok := bytes.Cut(out, []byte(loginEnvMarker))
+	if !ok {
+		return nil, fmt.Errorf("no environment in %s output", shell)
+	}
+	return parseEnv0(out), nil
+}
+
+// parseEnv0 parses NUL-separated env -0 output, dropping the variables that
+// describe the shell that printed them rather than the environment
+func parseEnv0(out []byte) []string {
+	var env []string
+	for _, kv := range strings.Split(string(out), "\x00") {
+		name, _, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			continue
+		}
+		switch name {
+		case "PWD", "OLDPWD", "SHLVL", "_":
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
+
+// shell returns the shell mode for the process
+func (o Options) shell() string {
+	switch {
+	case o.Shell != "":
+		return o.Shell
+	case len(o.Exec) > 0:
+		return ShellNone
+	default:
+		return ShellLogin
+	}
+}
+
+// ShellMode returns the shell a process with these options runs under:
+// ShellLogin, ShellPlain, ShellNone or a shell path
+func (o Options) ShellMode() string {
+	return o.shell()
+}
+
+// hookShell returns the shell mode for hooks, the stop command and the exec
+// readiness check, which are always shell commands
+func (o Options) hookShell() string {
+	if s := o.shell(); s != ShellNone {
+		return s
+	}
+	return ShellPlain
+}
+
+// baseEnv returns the environment a process starts from: roost-dev's own for
+// a login shell, which sets up the rest itself, else the resolved login one
+func (o Options) baseEnv() []string {
+	if o.shell() == ShellLogin {
+		return os.Environ()
+	}
+	return LoginEnv()
+}
+
+// shellCommand returns a command running command with the given shell mode
+func shellCommand(ctx context.Context, shell, command string) *exec.Cmd {
+	switch shell {
+	case ShellLogin:
+		// -l (login) sources .zprofile; -i (interactive) sources .zshrc/.bashrc
+		return exec.CommandContext(ctx, getUserShell(), "-i", "-l", "-c", command)
+	case ShellPlain, ShellNone:
+		return exec.CommandContext(ctx, getUserShell(), "-c", command)
+	default:
+		return exec.CommandContext(ctx, shell, "-c", command)
+	}
+}
+
+// execCommand returns a command running argv directly, finding a bare
+// program name on the PATH in env, as the login shell would
+func execCommand(ctx context.Context, argv []string, env []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if !strings.Contains(argv[0], "/") {
+		if path, err := lookPath(argv[0], env); err == nil {
+			cmd.Path = path
+			cmd.Err = nil
+		}
+	}
+	return cmd
+}
+
+// lookPath finds an executable in the PATH of env
+func lookPath(file string, env []string) (string, error) {
+	for _, kv := range slices.Backward(env) {
+		path, ok := strings.CutPrefix(kv, "PATH=")
+		if !ok {
+			continue
+		}
+		for _, dir := range filepath.SplitList(path) {
+			if dir == "" {
+				dir = "."
+			}
+			p := filepath.Join(dir, file)
+			if info, err := os.Stat(p); err == nil && !info.IsDir() && info.Mode()&0111 != 0 {
+				return p, nil
+			}
+		}
+		break // Only the last PATH counts
+	}
+	return "", fmt.Errorf("%s: %w", file, exec.ErrNotFound)
+}
+
+// shellJoin quotes argv into a command line a POSIX shell runs as is
+func shellJoin(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote single-quotes s unless it only has characters a shell leaves alone
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=,+@%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,311 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// adoptedPollInterval is how often an adopted process, which roost-dev can't
+// wait on, is checked for having exited
+const adoptedPollInterval = 1 * time.Second
+
+// SavedProcess is what the state file records about a running process, so a
+// later roost-dev run can find it again
+type SavedProcess struct {
+	Name    string         `json:"name"`
+	PID     int            `json:"pid"`
+	PGID    int            `json:"pgid"`
+	Port    int            `json:"port,omitempty"`
+	Ports   map[string]int `json:"ports,omitempty"`
+	Command string         `json:"command"`
+	Exec    []string       `json:"exec,omitempty"`
+	Dir     string         `json:"dir"`
+	Started time.Time      `json:"started"`
+}
+
+// Spec is how a process would be started with the current config
+type Spec struct {
+	Command string
+	Dir     string
+	Env     map[string]string
+	Opts    Options
+}
+
+// SetStateFile makes the manager record its running processes in path, for
+// Recover to pick up after roost-dev restarts
+func (m *Manager) SetStateFile(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stateFile = path
+}
+
+// LoadState reads the processes recorded in a state file
+func LoadState(path string) ([]SavedProcess, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var saved []SavedProcess
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return saved, nil
+}
+
+// saveState writes every process that has a pid to the state file.
+// Caller must hold m.mu.
+func (m *Manager) saveState() {
+	if m.stateFile == "" {
+		return
+	}
+	saved := []SavedProcess{}
+	for _, proc := range m.processes {
+		pid, ok := proc.Pid()
+		if !ok {
+			continue
+		}
+		pgid, err := syscall.Getpgid(pid)
+		if err != nil {
+			continue
+		}
+		saved = append(saved, SavedProcess{
+			Name:    proc.Name,
+			PID:     pid,
+			PGID:    pgid,
+			Port:    proc.Port,
+			Ports:   proc.Ports,
+			Command: proc.Command,
+			Exec:    proc.opts.Exec,
+			Dir:     proc.Dir,
+			Started: proc.started,
+		})
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].Name < saved[j].Name })
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return
+	}
+	tmp := m.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		fmt.Printf("[roost-dev] Failed to save process state: %v\n", err)
+		return
+	}
+	os.Rename(tmp, m.stateFile)
+}
+
+// Recover deals with the processes a previous roost-dev run left in the
+// state file. Each one still running the same command (per spec, which
+// returns false for names no longer configured) and answering on its port
+// is adopted: it shows as running and is stopped like any other process.
+// The rest are killed along with their process groups. Only processes that
+// carry MarkerEnv for their name are touched, since their pids may since
+// have been reused. Returns the names adopted and killed.
+func (m *Manager) Recover(spec func(name string) (Spec, bool)) (adopted, killed []string) {
+	m.mu.RLock()
+	path := m.stateFile
+	m.mu.RUnlock()
+	if path == "" {
+		return nil, nil
+	}
+	saved, err := LoadState(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Printf("[roost-dev] Could not read process state: %v\n", err)
+		}
+		return nil, nil
+	}
+	table, err := snapshotProcs()
+	if err != nil {
+		fmt.Printf("[roost-dev] Could not list processes to recover: %v\n", err)
+		return nil, nil
+	}
+
+	for _, sp := range saved {
+		members := groupMembers(table, sp.PGID)
+		if !slices.ContainsFunc(members, func(pid int) bool { return hasMarker(pid, sp.Name) }) {
+			continue // Gone, or the pids now belong to something else
+		}
+		s, configured := spec(sp.Name)
+		_, leaderAlive := table[sp.PID]
+		reason := ""
+		switch {
+		case !configured:
+			reason = "no longer configured"
+		case !leaderAlive:
+			reason = "its main process exited"
+		case sp.Command != s.Command || sp.Dir != s.Dir || !slices.Equal(sp.Exec, s.Opts.Exec):
+			reason = "its command changed"
+		case sp.Port != 0 && !portOpen(sp.Port):
+			reason = fmt.Sprintf("not answering on port %d", sp.Port)
+		}
+		if reason != "" {
+			fmt.Printf("[roost-dev] Killing %s left over from a previous run (pid %d, %s)\n", sp.Name, sp.PID, reason)
+			killGroup(sp.PGID, s.Opts)
+			killed = append(killed, sp.Name)
+			continue
+		}
+		if err := m.adopt(sp, s); err != nil {
+			fmt.Printf("[roost-dev] Couldn't adopt %s (pid %d), killing it: %v\n", sp.Name, sp.PID, err)
+			killGroup(sp.PGID, s.Opts)
+			killed = append(killed, sp.Name)
+			continue
+		}
+		adopted = append(adopted, sp.Name)
+	}
+
+	m.mu.Lock()
+	m.saveState()
+	m.mu.Unlock()
+	return adopted, killed
+}
+
+// adopt registers a still-running process from a previous run, watching it
+// for exit since it isn't roost-dev's child any more
+func (m *Manager) adopt(sp SavedProcess, s Spec) error {
+	osProc, err := os.FindProcess(sp.PID)
+	if err != nil {
+		return err
+	}
+	procEnv := buildEnv(sp.Name, s.Opts.inheritedEnv(), s.Env, s.Opts, sp.Port, sp.Ports)
+	cmd := &exec.Cmd{Process: osProc, Env: procEnv, Dir: sp.Dir}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.processes[sp.Name]; exists {
+		return errors.New("already running")
+	}
+	logs := NewLogBuffer(1000)
+	if lf := m.logFile(sp.Name); lf != nil {
+		logs.SetFile(lf)
+	}
+	logs.Write([]byte(fmt.Sprintf("[roost-dev] Adopted %s (pid %d) from a previous roost-dev run; its output isn't captured until it restarts\n", sp.Name, sp.PID)))
+
+	now := time.Now()
+	proc := &Process{
+		Name:        sp.Name,
+		Command:     s.Command,
+		Dir:         sp.Dir,
+		Port:        sp.Port,
+		Ports:       sp.Ports,
+		Env:         s.Env,
+		opts:        s.Opts,
+		cmd:         cmd,
+		cancel:      func() {},
+		logs:        logs,
+		started:     sp.Started,
+		lastRequest: now,
+		stopCh:      make(chan struct{}),
+		exited:      make(chan struct{}),
+		adopted:     true,
+	}
+	m.processes[sp.Name] = proc
+	if sp.Port != 0 {
+		m.rememberPort(sp.Name, sp.Port)
+	}
+	for portName, port := range sp.Ports {
+		m.rememberPort(sp.Name+":"+portName, port)
+	}
+	if s.Opts.Watch != nil {
+		m.watch(sp.Name, sp.Dir, *s.Opts.Watch)
+	}
+	fmt.Printf("[roost-dev] Adopted %s (pid %d, port %d)\n", sp.Name, sp.PID, sp.Port)
+
+	go m.monitorAdopted(proc, sp.PID)
+	return nil
+}
+
+// SetSiblingEnv replaces the sibling variables a process gets when it's next
+// restarted automatically (Options.SiblingEnv)
+func (m *Manager) SetSiblingEnv(name string, env map[string]string) {
+	m.mu.RLock()
+	proc, exists := m.processes[name]
+	m.mu.RUnlock()
+	if !exists {
+		return
+	}
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	proc.opts.SiblingEnv = env
+}
+
+// monitorAdopted waits for an adopted process to exit. Its exit status is
+// lost with its parent, so a crash can't be told from a clean exit.
+func (m *Manager) monitorAdopted(proc *Process, pid int) {
+	for syscall.Kill(pid, 0) == nil {
+		time.Sleep(adoptedPollInterval)
+	}
+	proc.mu.Lock()
+	proc.gone = true
+	stopped := proc.stopped
+	proc.mu.Unlock()
+
+	var err error
+	if !stopped {
+		err = errors.New("exited (status unknown, adopted from a previous run)")
+		proc.logs.Write([]byte("[roost-dev] Process exited\n"))
+	}
+	if hook := proc.opts.Hooks.AfterStop; hook != "" {
+		hookCtx, cancel := context.WithTimeout(context.Background(), afterStopTimeout)
+		if hookErr := proc.runHook(hookCtx, PhaseAfterStop, hook); hookErr != nil {
+			proc.fail(hookError(PhaseAfterStop, hookErr))
+		}
+		cancel()
+	}
+	close(proc.exited)
+	m.handleExit(proc, err)
+
+	m.mu.Lock()
+	m.saveState()
+	m.mu.Unlock()
+}
+
+// groupMembers returns the pids in process group pgid
+func groupMembers(table procTable, pgid int) []int {
+	var pids []int
+	for pid, p := range table {
+		if p.PGID == pgid {
+			pids = append(pids, pid)
+		}
+	}
+	slices.Sort(pids)
+	return pids
+}
+
+// hasMarker returns true if pid was started by roost-dev as process name
+func hasMarker(pid int, name string) bool {
+	env, err := processEnviron(pid)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(env, MarkerEnv+"="+name)
+}
+
+// killGroup stops a process group the way Kill would, without a Process
+func killGroup(pgid int, opts Options) {
+	sig := opts.stopSignal()
+	timeout := opts.stopTimeout()
+	syscall.Kill(-pgid, sig)
+	if !waitForExit(-pgid, timeout) {
+		syscall.Kill(-pgid, syscall.SIGKILL)
+	}
+}
+
+// portOpen returns true if something accepts connections on port
+func portOpen(port int) bool {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), 500*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
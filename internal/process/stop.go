@@ -0,0 +1,92 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultStopTimeout = 5 * time.Second
+
+	// stopPollInterval is how often we check whether a stopping process group has exited
+	stopPollInterval = 20 * time.Millisecond
+)
+
+// stopSignal returns the signal used to ask the process to stop
+func (o Options) stopSignal() syscall.Signal {
+	if o.StopSignal == 0 {
+		return syscall.SIGTERM
+	}
+	return o.StopSignal
+}
+
+// stopTimeout returns how long to wait for a graceful exit before SIGKILL
+func (o Options) stopTimeout() time.Duration {
+	if o.StopTimeout <= 0 {
+		return defaultStopTimeout
+	}
+	return o.StopTimeout
+}
+
+// waitForExit polls until no process matching target is left (a PID, or a
+// negative PGID for a whole group) or the timeout expires. It returns true
+// if everything exited.
+func waitForExit(target int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := syscall.Kill(target, 0); err == syscall.ESRCH {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(stopPollInterval)
+	}
+}
+
+// runStopCommand runs the configured stop command in the process's directory
+// and environment, logging its output. It returns false if the command failed,
+// in which case the caller falls back to sending the stop signal.
+func (p *Process) runStopCommand(env []string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	fmt.Printf("[roost-dev] Kill %s: running stop command: %s\n", p.Name, p.opts.StopCommand)
+	cmd := shellCommand(ctx, p.opts.hookShell(), p.opts.StopCommand)
+	cmd.Dir = p.Dir
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	p.logs.Write(output)
+	if err != nil {
+		fmt.Printf("[roost-dev] Kill %s: stop command failed: %v\n", p.Name, err)
+		p.logs.Write([]byte(fmt.Sprintf("[roost-dev] Stop command failed: %v\n", err)))
+		return false
+	}
+	return true
+}
+
+// signalName returns a short name like SIGTERM for log messages
+func signalName(sig syscall.Signal) string {
+	switch sig {
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGQUIT:
+		return "SIGQUIT"
+	case syscall.SIGHUP:
+		return "SIGHUP"
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	case syscall.SIGUSR1:
+		return "SIGUSR1"
+	case syscall.SIGUSR2:
+		return "SIGUSR2"
+	case syscall.SIGWINCH:
+		return "SIGWINCH"
+	default:
+		return fmt.Sprintf("signal %d", int(sig))
+	}
+}
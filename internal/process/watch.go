@@ -0,0 +1,316 @@
+package process
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/panozzaj/roost-dev/internal/debounce"
+)
+
+// defaultWatchDebounce is how long changes must settle before a restart
+const defaultWatchDebounce = 200 * time.Millisecond
+
+// defaultWatchIgnore are never watched: version control and dependencies at
+// any depth, top-level logs and temp files, and editor swap files
+var defaultWatchIgnore = []string{
+	"**/.git/**", "**/.hg/**", "**/.svn/**", "**/node_modules/**", "log/**", "tmp/**",
+	"*.swp", "*.swx", "*~", ".#*", "4913", ".DS_Store",
+}
+
+// WatchSpec restarts a process when files under its directory change
+type WatchSpec struct {
+	Include  []string      // Globs relative to the process dir (empty = everything)
+	Exclude  []string      // Globs to ignore, on top of defaultWatchIgnore
+	Debounce time.Duration // Wait for changes to settle (0 = default)
+
+	ownFiles []string // Files the process writes itself (see withOwnFiles), relative to its dir
+}
+
+// withOwnFiles returns the spec ignoring the files proc writes as it starts,
+// its ready file and pidfiles, which would otherwise restart it in a loop
+func (w WatchSpec) withOwnFiles(proc *Process) WatchSpec {
+	var paths []string
+	if proc.opts.Ready.Type == ReadyFile && proc.opts.Ready.Path != "" {
+		paths = append(paths, resolvePath(proc.Dir, proc.opts.Ready.Path))
+	}
+	for _, pf := range detectPIDFiles(proc.Command, proc.Dir, proc.Env, proc.opts) {
+		paths = append(paths, pf.path)
+	}
+	w.ownFiles = nil
+	for _, p := range paths {
+		if rel, err := filepath.Rel(proc.Dir, p); err == nil && filepath.IsLocal(rel) {
+			w.ownFiles = append(w.ownFiles, filepath.ToSlash(rel))
+		}
+	}
+	return w
+}
+
+// matches returns true if a change to rel (slash-separated, relative to the
+// process dir) should restart the process
+func (w WatchSpec) matches(rel string) bool {
+	if w.ignored(rel) {
+		return false
+	}
+	if len(w.Include) == 0 {
+		return true
+	}
+	for _, glob := range w.Include {
+		if matchGlob(glob, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignored returns true if rel is excluded
+func (w WatchSpec) ignored(rel string) bool {
+	if slices.Contains(w.ownFiles, rel) {
+		return true
+	}
+	for _, glob := range slices.Concat(defaultWatchIgnore, w.Exclude) {
+		if matchGlob(glob, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipDir returns true if nothing under the directory rel can matter, so it
+// doesn't need an fsnotify watch
+func (w WatchSpec) skipDir(rel string) bool {
+	for _, glob := range slices.Concat(defaultWatchIgnore, w.Exclude) {
+		if dir, ok := strings.CutSuffix(glob, "/**"); ok && matchGlob(dir, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated path against a glob where ** matches
+// any number of directories. A glob without a slash matches the base name at
+// any depth, so *.go matches cmd/main.go.
+func matchGlob(glob, name string) bool {
+	if !strings.Contains(glob, "/") {
+		ok, _ := path.Match(glob, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(glob, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(glob, name []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(glob[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], name[0]); !ok {
+			return false
+		}
+		glob, name = glob[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// fileWatcher watches a directory tree and reports matching changes once
+// they settle
+type fileWatcher struct {
+	watcher *fsnotify.Watcher
+	dir     string
+	spec    WatchSpec
+	done    chan struct{}
+	changes *debounce.Files // Batches changes until they settle
+}
+
+// newFileWatcher watches every directory under dir that isn't ignored.
+// onChange receives the changed paths relative to dir, first change first.
+func newFileWatcher(dir string, spec WatchSpec, onChange func(changedFiles []string)) (*fileWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	delay := spec.Debounce
+	if delay <= 0 {
+		delay = defaultWatchDebounce
+	}
+	fw := &fileWatcher{
+		watcher: w,
+		dir:     dir,
+		spec:    spec,
+		done:    make(chan struct{}),
+		changes: debounce.NewFiles(delay, onChange),
+	}
+	if err := fw.addTree(dir); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return fw, nil
+}
+
+// addTree adds watches for root and the directories below it
+func (w *fileWatcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil // Vanished or unreadable; skip it
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != root && w.spec.skipDir(w.rel(p)) {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(p); err != nil {
+			if p == root {
+				return err
+			}
+			fmt.Printf("[roost-dev] Can't watch %s: %v\n", p, err)
+		}
+		return nil
+	})
+}
+
+// rel returns p relative to the watched dir, slash-separated
+func (w *fileWatcher) rel(p string) string {
+	rel, err := filepath.Rel(w.dir, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Start begins watching for changes
+func (w *fileWatcher) Start() {
+	go w.run()
+}
+
+// Stop stops the watcher
+func (w *fileWatcher) Stop() {
+	close(w.done)
+	w.watcher.Close()
+}
+
+func (w *fileWatcher) run() {
+	for {
+		select {
+		case <-w.done:
+			w.changes.Stop()
+			return
+
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			// Watch directories created after we started (fsnotify isn't recursive)
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() && !w.spec.skipDir(w.rel(event.Name)) {
+					w.addTree(event.Name)
+				}
+			}
+
+			if rel := w.rel(event.Name); w.spec.matches(rel) {
+				w.changes.Add(rel)
+			}
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Printf("[roost-dev] File watcher error for %s: %v\n", w.dir, err)
+		}
+	}
+}
+
+// watch starts restarting name when files under dir change, unless a watcher
+// is already running for it. Caller must hold m.mu.
+func (m *Manager) watch(name, dir string, spec WatchSpec) {
+	if m.watchers[name] != nil {
+		return
+	}
+	var w *fileWatcher
+	w, err := newFileWatcher(dir, spec, func(changedFiles []string) {
+		m.restartForChange(name, w, changedFiles)
+	})
+	if err != nil {
+		fmt.Printf("[roost-dev] Failed to watch %s for %s: %v\n", dir, name, err)
+		return
+	}
+	m.watchers[name] = w
+	w.Start()
+}
+
+// unwatch stops the file watcher for name, if any. Caller must hold m.mu.
+func (m *Manager) unwatch(name string) {
+	if w := m.watchers[name]; w != nil {
+		w.Stop()
+		delete(m.watchers, name)
+	}
+	delete(m.triggers, name)
+}
+
+// restartForChange restarts a process after its watched files changed
+func (m *Manager) restartForChange(name string, w *fileWatcher, changedFiles []string) {
+	trigger := changedFiles[0]
+	if len(changedFiles) > 1 {
+		trigger = fmt.Sprintf("%s (+%d more)", trigger, len(changedFiles)-1)
+	}
+
+	m.mu.Lock()
+	proc, exists := m.processes[name]
+	// Stopped while the change was settling
+	if m.watchers[name] != w || !exists {
+		m.mu.Unlock()
+		return
+	}
+	m.triggers[name] = trigger
+	restart := m.watchRestart
+	m.mu.Unlock()
+
+	proc.logs.Write([]byte(fmt.Sprintf("[roost-dev] %s changed, restarting\n", trigger)))
+	fmt.Printf("[roost-dev] %s: %s changed, restarting\n", name, trigger)
+	if restart == nil {
+		m.RestartAsync(name)
+		return
+	}
+	restart(name)
+	// Restarting that way stops the watcher, which forgets the trigger
+	m.mu.Lock()
+	m.triggers[name] = trigger
+	m.mu.Unlock()
+}
+
+// SetWatchRestart makes restarts for watched file changes go through fn
+// instead of RestartAsync, so processes that restart along with the changed
+// one can be restarted too
+func (m *Manager) SetWatchRestart(fn func(name string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watchRestart = fn
+}
+
+// RestartTrigger returns the watched file whose change caused the last
+// restart of a process, or "" if it hasn't been restarted for a change
+func (m *Manager) RestartTrigger(name string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.triggers[name]
+}
@@ -8,9 +8,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/panozzaj/roost-dev/internal/config"
+	"github.com/panozzaj/roost-dev/internal/process"
 	"github.com/panozzaj/roost-dev/internal/server/pages"
 	"github.com/panozzaj/roost-dev/internal/ui"
 )
@@ -63,6 +66,10 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	case "/":
 		ui.ServeIndex(w, r, s.cfg.TLD, s.cfg.URLPort, s.getStatus(), s.getTheme())
 
+	case "/schema.json":
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.Write(config.Schema())
+
 	case "/icons":
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.Write([]byte(pages.IconsTestPage(s.getTheme())))
@@ -87,9 +94,18 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 	case "/api/start":
 		s.handleStart(w, r)
 
+	case "/api/profile":
+		s.handleProfile(w, r)
+
 	case "/api/logs":
 		s.handleLogs(w, r)
 
+	case "/api/env":
+		s.handleEnv(w, r)
+
+	case "/api/ps":
+		s.handlePS(w, r)
+
 	case "/api/server-logs":
 		// Return roost-dev's request handling logs
 		w.Header().Set("Content-Type", "application/json")
@@ -159,6 +175,7 @@ func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
 	if name != "" {
 		// First try to resolve as a service name (supports app:svc, svc.app, svc, svc-app)
 		if match := s.resolveServiceName(name); match != nil {
+			s.cancelDepWait(match.ProcName)
 			s.procs.Stop(match.ProcName)
 			s.broadcastStatus()
 			w.WriteHeader(http.StatusOK)
@@ -172,75 +189,92 @@ func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
 		if _, found := s.procs.Get(name); found {
 			s.procs.Stop(name)
 		} else if app, found := s.apps.Get(name); found && app.Type == config.AppTypeYAML {
-			// Stop all services for multi-service app
-			for _, svc := range app.Services {
-				procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
-				s.procs.Stop(procName)
-			}
+			// Stop all services for multi-service app, dependents first
+			s.stopServices(app, appServices(app))
 		}
 		s.broadcastStatus()
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
-// handleRestart restarts an app or service
+// restartResult is the response to /api/restart
+type restartResult struct {
+	Restarted []string `json:"restarted"` // Processes restarted, including those via restart_with
+}
+
+// handleRestart restarts an app or service, and reports which processes were
+// restarted
 func (s *Server) handleRestart(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
 	s.logRequest("API restart called for: %s", name)
+	result := restartResult{Restarted: []string{}}
 	if name != "" {
-		// First try to resolve as a service name (supports app:svc, svc.app, svc, svc-app)
-		if match := s.resolveServiceName(name); match != nil {
-			s.logRequest("  Restarting service: %s", match.ProcName)
-			s.procs.Stop(match.ProcName)
-			s.ensureDependencies(match.App, match.Service)
-			s.procs.StartAsync(match.ProcName, match.Service.Command, match.Service.Dir, match.Service.Env)
-			s.broadcastStatus()
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		// Resolve alias to app name
-		if app, found := s.apps.GetByNameOrAlias(name); found {
-			name = app.Name
+		result.Restarted = s.restart(name)
+		s.broadcastStatus()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// restart restarts an app or service by name and returns the processes
+// restarted
+func (s *Server) restart(name string) []string {
+	// First try to resolve as a service name (supports app:svc, svc.app, svc, svc-app)
+	if match := s.resolveServiceName(name); match != nil {
+		s.logRequest("  Restarting service: %s", match.ProcName)
+		restarted := s.restartService(match.App, match.Service)
+		if len(restarted) > 1 {
+			s.logRequest("  Also restarted (restart_with): %s", strings.Join(restarted[1:], ", "))
 		}
-		// Try direct process name first
-		if proc, found := s.procs.Get(name); found {
-			s.logRequest("  Restarting process: %s", proc.Name)
-			// Stop then start fresh to pick up any config changes
-			s.procs.Stop(proc.Name)
-			s.startByName(name)
-		} else if app, found := s.apps.Get(name); found && app.Type == config.AppTypeYAML {
-			// Restart all services for multi-service app
-			// Stop ALL existing processes first (including those still starting/hung)
-			for i := range app.Services {
-				svc := &app.Services[i]
-				procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
-				if proc, found := s.procs.Get(procName); found {
-					status := "idle"
-					if proc.IsRunning() {
-						status = "running"
-					} else if proc.IsStarting() {
-						status = "starting"
-					} else if proc.HasFailed() {
-						status = "failed"
-					}
-					s.logRequest("  Stopping %s (was %s)", procName, status)
-					s.procs.Stop(procName)
-				}
-			}
-			// Now start all services fresh with current config
-			for i := range app.Services {
-				svc := &app.Services[i]
-				procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
-				s.ensureDependencies(app, svc)
-				s.procs.StartAsync(procName, svc.Command, svc.Dir, svc.Env)
+		return restarted
+	}
+	// Resolve alias to app name
+	if app, found := s.apps.GetByNameOrAlias(name); found {
+		name = app.Name
+	}
+	// Try direct process name first
+	if proc, found := s.procs.Get(name); found {
+		s.logRequest("  Restarting process: %s", proc.Name)
+		// Stop then start fresh to pick up any config changes
+		s.procs.Stop(proc.Name)
+		s.startByName(name)
+		return []string{name}
+	}
+	app, found := s.apps.Get(name)
+	if !found {
+		return []string{}
+	}
+	if app.Type != config.AppTypeYAML {
+		// Try to start it fresh
+		s.startByName(name)
+		return []string{name}
+	}
+
+	// Restart all services for multi-service app
+	// Stop ALL existing processes first (including those still starting/hung)
+	restarted := make([]string, 0, len(app.Services))
+	for _, svc := range app.Services {
+		procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+		restarted = append(restarted, procName)
+		if proc, found := s.procs.Get(procName); found {
+			status := "idle"
+			if proc.IsRunning() {
+				status = "running"
+			} else if proc.IsStarting() {
+				status = "starting"
+			} else if proc.HasFailed() {
+				status = "failed"
 			}
-		} else {
-			// Try to start it fresh
-			s.startByName(name)
+			s.logRequest("  Stopping %s (was %s)", procName, status)
 		}
-		s.broadcastStatus()
 	}
-	w.WriteHeader(http.StatusOK)
+	s.stopServices(app, appServices(app))
+	// Now start all services fresh with current config
+	for i := range app.Services {
+		svc := &app.Services[i]
+		s.startWithDeps(app, svc)
+	}
+	return restarted
 }
 
 // handleStart starts an app or service
@@ -249,8 +283,7 @@ func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 	if name != "" {
 		// First try to resolve as a service name (supports app:svc, svc.app, svc, svc-app)
 		if match := s.resolveServiceName(name); match != nil {
-			s.ensureDependencies(match.App, match.Service)
-			s.procs.StartAsync(match.ProcName, match.Service.Command, match.Service.Dir, match.Service.Env)
+			s.startWithDeps(match.App, match.Service)
 			s.broadcastStatus()
 			w.WriteHeader(http.StatusOK)
 			return
@@ -272,6 +305,14 @@ func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
 	if app, found := s.apps.GetByNameOrAlias(name); found {
 		name = app.Name
 	}
+
+	// History options read the log files on disk instead of memory
+	q := r.URL.Query()
+	if q.Has("file") || q.Has("since") || q.Has("before_restart") {
+		s.handleLogFiles(w, r, name)
+		return
+	}
+
 	var allLogs []string
 
 	// Try direct process name first
@@ -295,6 +336,114 @@ func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(allLogs)
 }
 
+// processEnv is the effective environment of one process for /api/env
+type processEnv struct {
+	Name    string   `json:"name"`              // Process name (service-app for services)
+	Service string   `json:"service,omitempty"` // Service name, for multi-service apps
+	Shell   string   `json:"shell"`             // Shell mode it runs under
+	Env     []string `json:"env"`               // NAME=value, sorted by name
+}
+
+// handleEnv returns the environment an app's processes (or one service)
+// would get, for debugging differences from a terminal
+func (s *Server) handleEnv(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+
+	type target struct {
+		app *config.App
+		svc *config.Service
+	}
+	var targets []target
+	if match := s.resolveServiceName(name); match != nil {
+		targets = append(targets, target{match.App, match.Service})
+	} else if app, found := s.apps.GetByNameOrAlias(name); found {
+		switch app.Type {
+		case config.AppTypeCommand:
+			targets = append(targets, target{app, nil})
+		case config.AppTypeYAML:
+			for i := range app.Services {
+				targets = append(targets, target{app, &app.Services[i]})
+			}
+		}
+	}
+	if len(targets) == 0 {
+		http.Error(w, fmt.Sprintf("no app or service named %q runs a command", name), http.StatusNotFound)
+		return
+	}
+
+	result := make([]processEnv, 0, len(targets))
+	for _, t := range targets {
+		env, shell, err := s.effectiveEnv(t.app, t.svc)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		pe := processEnv{Name: t.app.Name, Shell: shell, Env: env}
+		if t.svc != nil {
+			pe.Name = fmt.Sprintf("%s-%s", slugify(t.svc.Name), t.app.Name)
+			pe.Service = t.svc.Name
+		}
+		result = append(result, pe)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleLogFiles returns logs read back from the per-process log files,
+// optionally limited to lines since a time or to the run before the last restart
+func (s *Server) handleLogFiles(w http.ResponseWriter, r *http.Request, name string) {
+	q := r.URL.Query()
+	var query process.LogQuery
+	if since := q.Get("since"); since != "" {
+		t, err := parseSince(since, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query.Since = t
+	}
+	query.BeforeRestart, _ = strconv.ParseBool(q.Get("before_restart"))
+
+	if s.procs.LogPath(name) == "" {
+		http.Error(w, "log files are disabled", http.StatusNotFound)
+		return
+	}
+
+	// Multi-service apps aggregate the files of all their services
+	allLogs := []string{}
+	if app, found := s.apps.Get(name); found && app.Type == config.AppTypeYAML {
+		for _, svc := range app.Services {
+			procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+			lines, _ := process.ReadLogFile(s.procs.LogPath(procName), query)
+			for _, line := range lines {
+				allLogs = append(allLogs, fmt.Sprintf("[%s] %s", svc.Name, line))
+			}
+		}
+	} else {
+		lines, err := process.ReadLogFile(s.procs.LogPath(name), query)
+		if err != nil && !os.IsNotExist(err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		allLogs = append(allLogs, lines...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(allLogs)
+}
+
+// parseSince parses a --since value: a duration ago (10m, 2h) or an RFC 3339 time
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q (expected a duration like 10m or an RFC 3339 time)", value)
+}
+
 // handleAppStatus returns the status of a single app or service
 func (s *Server) handleAppStatus(w http.ResponseWriter, r *http.Request) {
 	name := r.URL.Query().Get("name")
@@ -303,14 +452,26 @@ func (s *Server) handleAppStatus(w http.ResponseWriter, r *http.Request) {
 		name = app.Name
 	}
 	type singleAppStatus struct {
-		Status string `json:"status"` // idle, starting, running, failed
-		Error  string `json:"error,omitempty"`
+		Status     string   `json:"status"`               // idle, starting, running, failed
+		Phase      string   `json:"phase,omitempty"`      // while starting: waiting_for_deps, before_start, boot or after_ready
+		WaitingFor []string `json:"waitingFor,omitempty"` // dependencies not running yet (waiting_for_deps)
+		Error      string   `json:"error,omitempty"`
 	}
 
 	status := singleAppStatus{Status: "idle"}
-	if proc, found := s.procs.Get(name); found {
+	if waitingFor, errMsg, waiting := s.depWaitStatus(name); waiting {
+		if errMsg != "" {
+			status.Status = "failed"
+			status.Error = errMsg
+		} else {
+			status.Status = "starting"
+			status.Phase = "waiting_for_deps"
+			status.WaitingFor = waitingFor
+		}
+	} else if proc, found := s.procs.Get(name); found {
 		if proc.IsStarting() {
 			status.Status = "starting"
+			status.Phase = proc.Phase()
 		} else if proc.IsRunning() {
 			status.Status = "running"
 		} else if proc.HasFailed() {
@@ -325,7 +486,7 @@ func (s *Server) handleAppStatus(w http.ResponseWriter, r *http.Request) {
 		if serviceName, appName, ok := parseServiceName(name); ok {
 			if app, svc, found := s.apps.GetService(appName, serviceName); found {
 				for _, depName := range svc.DependsOn {
-					depProcName := fmt.Sprintf("%s-%s", depName, app.Name)
+					depProcName := fmt.Sprintf("%s-%s", slugify(depName), app.Name)
 					depProc, found := s.procs.Get(depProcName)
 					if !found {
 						// Dependency not started yet - report starting
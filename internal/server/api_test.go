@@ -1,6 +1,19 @@
 package server
 
-import "testing"
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/panozzaj/roost-dev/internal/config"
+	"github.com/panozzaj/roost-dev/internal/process"
+)
 
 func TestParseServiceName(t *testing.T) {
 	tests := []struct {
@@ -69,3 +82,579 @@ func TestParseServiceName(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"duration", "10m", now.Add(-10 * time.Minute), false},
+		{"rfc3339", "2026-01-02T14:00:00Z", time.Date(2026, 1, 2, 14, 0, 0, 0, time.UTC), false},
+		{"invalid", "yesterday", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSince(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSince(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseSince(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleLogFiles(t *testing.T) {
+	cfg := &config.Config{TLD: "test", Dir: t.TempDir()}
+	apps := config.NewAppStore(cfg)
+	procs := process.NewManager()
+	logDir := t.TempDir()
+	procs.SetLogDir(logDir)
+	s := newTestServer(cfg, apps, procs)
+
+	lf, err := process.OpenLogFile(filepath.Join(logDir, "web-myapp.log"))
+	if err != nil {
+		t.Fatalf("OpenLogFile failed: %v", err)
+	}
+	base := time.Now().Add(-time.Hour)
+	lf.WriteLine(base, "[roost-dev] Started web-myapp on port 50001")
+	lf.WriteLine(base.Add(time.Second), "panic: boom")
+	lf.WriteLine(base.Add(time.Minute), "[roost-dev] Started web-myapp on port 50002")
+	lf.WriteLine(base.Add(2*time.Minute), "listening")
+	lf.Close()
+
+	t.Run("before_restart returns the previous run", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.handleLogs(w, httptest.NewRequest("GET", "/api/logs?name=web-myapp&before_restart=true", nil))
+
+		var lines []string
+		json.NewDecoder(w.Body).Decode(&lines)
+		if len(lines) != 2 || !strings.HasSuffix(lines[1], "panic: boom") {
+			t.Errorf("expected crashed run, got %v", lines)
+		}
+	})
+
+	t.Run("file returns all history", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.handleLogs(w, httptest.NewRequest("GET", "/api/logs?name=web-myapp&file=true", nil))
+
+		var lines []string
+		json.NewDecoder(w.Body).Decode(&lines)
+		if len(lines) != 4 {
+			t.Errorf("expected 4 lines, got %v", lines)
+		}
+	})
+
+	t.Run("invalid since is a bad request", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.handleLogs(w, httptest.NewRequest("GET", "/api/logs?name=web-myapp&since=whenever", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", w.Code)
+		}
+	})
+}
+
+func TestHandleEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{TLD: "test", Dir: tmpDir, URLPort: 80}
+	apps := config.NewAppStore(cfg)
+	procs := process.NewManager()
+	s := newTestServer(cfg, apps, procs)
+
+	yamlContent := `
+name: envapp
+root: /tmp
+inherit_env: [ROOST_TEST_KEEP]
+services:
+  api:
+    cmd: sleep 999
+    env:
+      API_URL: http://localhost:$PORT
+  web:
+    cmd: sleep 999
+    inherit_env: true
+`
+	if err := os.WriteFile(tmpDir+"/envapp.yml", []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := apps.Load(); err != nil {
+		t.Fatalf("failed to load apps: %v", err)
+	}
+	t.Setenv("ROOST_TEST_KEEP", "kept")
+	t.Setenv("ROOST_TEST_STALE", "stale")
+
+	get := func(t *testing.T, name string) (int, []processEnv) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/env?name="+name, nil)
+		w := httptest.NewRecorder()
+		s.handleEnv(w, req)
+		var envs []processEnv
+		json.NewDecoder(w.Body).Decode(&envs)
+		return w.Code, envs
+	}
+
+	t.Run("service env is clean with the allowlist", func(t *testing.T) {
+		code, envs := get(t, "envapp:api")
+		if code != http.StatusOK || len(envs) != 1 {
+			t.Fatalf("expected one process, got %d %v", code, envs)
+		}
+		env := envs[0].Env
+		if envs[0].Name != "api-envapp" || envs[0].Shell != "login" {
+			t.Errorf("expected api-envapp under a login shell, got %+v", envs[0])
+		}
+		if !slices.Contains(env, "ROOST_TEST_KEEP=kept") || slices.Contains(env, "ROOST_TEST_STALE=stale") {
+			t.Errorf("expected only allowlisted variables to be inherited, got %v", env)
+		}
+		var port string
+		for _, kv := range env {
+			if v, ok := strings.CutPrefix(kv, "PORT="); ok {
+				port = v
+			}
+		}
+		if port == "" || !slices.Contains(env, "API_URL=http://localhost:"+port) || !slices.Contains(env, "FORCE_COLOR=1") {
+			t.Errorf("expected PORT, expanded env and FORCE_COLOR, got %v", env)
+		}
+		if !slices.IsSorted(env) {
+			t.Errorf("expected variables sorted by name, got %v", env)
+		}
+		if last := procs.LastPort("api-envapp"); last != 0 {
+			t.Errorf("expected the query not to claim ports, got %d remembered", last)
+		}
+	})
+
+	t.Run("app lists every service", func(t *testing.T) {
+		code, envs := get(t, "envapp")
+		if code != http.StatusOK || len(envs) != 2 {
+			t.Fatalf("expected two processes, got %d %v", code, envs)
+		}
+		for _, pe := range envs {
+			if pe.Service == "web" && !slices.Contains(pe.Env, "ROOST_TEST_STALE=stale") {
+				t.Errorf("expected web to inherit everything, got %v", pe.Env)
+			}
+		}
+	})
+
+	t.Run("unknown name", func(t *testing.T) {
+		if code, _ := get(t, "nope"); code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", code)
+		}
+	})
+}
+
+func TestWorkerService(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{TLD: "test", Dir: tmpDir, URLPort: 80}
+	apps := config.NewAppStore(cfg)
+	procs := process.NewManager()
+	s := newTestServer(cfg, apps, procs)
+
+	yamlContent := `
+name: shop
+root: /tmp
+services:
+  web:
+    cmd: sleep 999
+  jobs:
+    cmd: sleep 999
+    type: worker
+`
+	if err := os.WriteFile(tmpDir+"/shop.yml", []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := apps.Load(); err != nil {
+		t.Fatalf("failed to load apps: %v", err)
+	}
+	app, _ := apps.Get("shop")
+
+	t.Run("siblings get no port or URL for a worker", func(t *testing.T) {
+		env := s.siblingEnv(app)
+		if env["PORT_WEB"] == "" {
+			t.Errorf("expected PORT_WEB, got %v", env)
+		}
+		for _, k := range []string{"PORT_JOBS", "URL_JOBS", "INTERNAL_URL_JOBS"} {
+			if _, ok := env[k]; ok {
+				t.Errorf("expected no %s, got %v", k, env)
+			}
+		}
+	})
+
+	t.Run("worker hostname isn't routed", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://jobs-shop.test/", nil)
+		rec := httptest.NewRecorder()
+		s.handleRequest(rec, req)
+		if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "Worker not routable") {
+			t.Errorf("expected worker 404, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("status marks the worker without a URL", func(t *testing.T) {
+		var status []appStatus
+		if err := json.Unmarshal(s.getStatus(), &status); err != nil {
+			t.Fatalf("failed to parse status: %v", err)
+		}
+		for _, as := range status {
+			if as.Name != "shop" {
+				continue
+			}
+			for _, ss := range as.Services {
+				if ss.Name == "jobs" && (!ss.Worker || ss.URL != "") {
+					t.Errorf("expected jobs to be a worker with no URL, got %+v", ss)
+				}
+				if ss.Name == "web" && ss.URL == "" {
+					t.Errorf("expected web to have a URL, got %+v", ss)
+				}
+			}
+		}
+	})
+}
+
+func TestHandleRestart(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{TLD: "test", Dir: tmpDir}
+	apps := config.NewAppStore(cfg)
+	procs := process.NewManager()
+	s := newTestServer(cfg, apps, procs)
+	s.requestLog = process.NewLogBuffer(100)
+
+	yamlContent := `
+name: cascade
+root: /tmp
+ready: none
+stop_signal: KILL
+stop_timeout: 100ms
+services:
+  agent:
+    cmd: sleep 999
+    restart_with: [api]
+  api:
+    cmd: sleep 999
+  web:
+    cmd: sleep 999
+    depends_on: [api]
+    restart_with: [api]
+  admin:
+    cmd: sleep 999
+    restart_with: [web]
+  docs:
+    cmd: sleep 999
+    depends_on: [api]
+`
+	if err := os.WriteFile(tmpDir+"/cascade.yml", []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := apps.Load(); err != nil {
+		t.Fatalf("failed to load apps: %v", err)
+	}
+	app, _ := apps.Get("cascade")
+	defer s.stopServices(app, appServices(app))
+
+	restart := func(name string) []string {
+		w := httptest.NewRecorder()
+		s.handleRestart(w, httptest.NewRequest("GET", "/api/restart?name="+name, nil))
+		var result restartResult
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("failed to parse response %q: %v", w.Body.String(), err)
+		}
+		return result.Restarted
+	}
+
+	// Start everything but admin
+	s.startWithDeps(app, s.findService(app, "agent"))
+	s.startWithDeps(app, s.findService(app, "api"))
+	s.startWithDeps(app, s.findService(app, "web"))
+	s.startWithDeps(app, s.findService(app, "docs"))
+
+	t.Run("restarting a service restarts running services that restart with it", func(t *testing.T) {
+		web, _ := procs.Get("web-cascade")
+		got := restart("cascade:api")
+		if want := []string{"api-cascade", "agent-cascade", "web-cascade"}; !slices.Equal(got, want) {
+			t.Errorf("expected %v (not docs, or admin which isn't running), got %v", want, got)
+		}
+		// agent starts before api but is still one of the extras
+		if logs := strings.Join(s.requestLog.Lines(), "\n"); !strings.Contains(logs, "Also restarted (restart_with): agent-cascade, web-cascade") {
+			t.Errorf("expected agent and web logged as restarted along with api, got:\n%s", logs)
+		}
+		if again, found := procs.Get("web-cascade"); found && again == web {
+			t.Error("expected web to be a new process")
+		}
+		if _, found := procs.Get("admin-cascade"); found {
+			t.Error("expected admin to stay stopped")
+		}
+	})
+
+	t.Run("a service without restart_with dependents restarts alone", func(t *testing.T) {
+		if got := restart("cascade:docs"); !slices.Equal(got, []string{"docs-cascade"}) {
+			t.Errorf("expected only docs, got %v", got)
+		}
+	})
+
+	t.Run("a watched file change restarts services that restart with it", func(t *testing.T) {
+		api, _ := procs.Get("api-cascade")
+		web, _ := procs.Get("web-cascade")
+		s.restartWatched("api-cascade")
+		if again, found := procs.Get("api-cascade"); !found || again == api {
+			t.Error("expected api to be a new process")
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if again, found := procs.Get("web-cascade"); found && again != web {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if again, found := procs.Get("web-cascade"); !found || again == web {
+			t.Error("expected web to restart with api")
+		}
+	})
+
+	t.Run("restarting the app reports every service", func(t *testing.T) {
+		got := restart("cascade")
+		if want := []string{"admin-cascade", "agent-cascade", "api-cascade", "docs-cascade", "web-cascade"}; !slices.Equal(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("unknown names restart nothing", func(t *testing.T) {
+		if got := restart("nope"); got == nil || len(got) != 0 {
+			t.Errorf("expected an empty list, got %v", got)
+		}
+	})
+}
+
+func TestHandlePS(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{TLD: "test", Dir: tmpDir, URLPort: 80}
+	apps := config.NewAppStore(cfg)
+	procs := process.NewManager()
+	s := newTestServer(cfg, apps, procs)
+
+	yamlContent := `
+name: psapp
+root: ` + tmpDir + `
+stop_signal: KILL
+stop_timeout: 100ms
+services:
+  web:
+    cmd: sleep 999
+    ready: none
+  jobs:
+    cmd: sleep 999
+    type: worker
+`
+	if err := os.WriteFile(tmpDir+"/psapp.yml", []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := apps.Load(); err != nil {
+		t.Fatalf("failed to load apps: %v", err)
+	}
+	app, _ := apps.Get("psapp")
+	web := s.findService(app, "web")
+	if _, err := s.startService(app, web); err != nil {
+		t.Fatalf("failed to start web: %v", err)
+	}
+	defer procs.Stop("web-psapp")
+	// Status only reports metrics once the process is running
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if proc, _ := procs.Get("web-psapp"); proc.IsRunning() {
+			break
+		}
+	}
+	procs.SampleMetrics()
+
+	w := httptest.NewRecorder()
+	s.handlePS(w, httptest.NewRequest("GET", "/api/ps", nil))
+	var entries []psEntry
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the running web service, got %+v", entries)
+	}
+	e := entries[0]
+	proc, _ := procs.Get("web-psapp")
+	if e.Name != "web-psapp" || e.App != "psapp" || e.Service != "web" || e.Port != proc.Port {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if pid, _ := proc.Pid(); e.Tree == nil || e.Tree.PID != pid {
+		t.Errorf("expected the tree to start at pid %d, got %+v", pid, e.Tree)
+	}
+	if e.Metrics == nil || e.Metrics.Memory <= 0 {
+		t.Errorf("expected sampled metrics, got %+v", e.Metrics)
+	}
+	if status := string(s.getStatus()); !strings.Contains(status, `"metrics":{"cpu":`) {
+		t.Errorf("expected metrics in the status, got %s", status)
+	}
+}
+
+func TestStatusDiagnostics(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{TLD: "test", Dir: tmpDir, URLPort: 80}
+	apps := config.NewAppStore(cfg)
+	s := newTestServer(cfg, apps, process.NewManager())
+
+	os.WriteFile(tmpDir+"/shop.yml", []byte("root: "+tmpDir+"\ncmd: bin/server\ncmnd: bin/other\n"), 0644)
+	os.WriteFile(tmpDir+"/broken.yml", []byte("root: "+tmpDir+"\nservices:\n  web:\n    port: lots\n"), 0644)
+	if err := apps.Load(); err != nil {
+		t.Fatalf("failed to load apps: %v", err)
+	}
+
+	var status []appStatus
+	if err := json.Unmarshal(s.getStatus(), &status); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if len(status) != 2 || status[0].Name != "broken" || status[1].Name != "shop" {
+		t.Fatalf("expected broken and shop, got %+v", status)
+	}
+
+	broken := status[0]
+	if broken.Type != "invalid" || !broken.Failed || broken.Error == "" || len(broken.Diagnostics) == 0 {
+		t.Errorf("expected an invalid entry for the broken config, got %+v", broken)
+	}
+	if d := broken.Diagnostics[0]; d.Line != 4 || d.Severity != config.SeverityError {
+		t.Errorf("expected an error on line 4, got %+v", d)
+	}
+
+	shop := status[1]
+	if shop.Type == "invalid" || len(shop.Diagnostics) != 1 || shop.Diagnostics[0].Line != 3 {
+		t.Errorf("expected the unknown key on line 3, got %+v", shop)
+	}
+}
+
+func TestServeSchema(t *testing.T) {
+	cfg := &config.Config{TLD: "test", Dir: t.TempDir(), URLPort: 80}
+	s := newTestServer(cfg, config.NewAppStore(cfg), process.NewManager())
+
+	w := httptest.NewRecorder()
+	s.handleDashboard(w, httptest.NewRequest("GET", "/schema.json", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/schema+json" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	var schema map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&schema); err != nil || schema["properties"] == nil {
+		t.Errorf("expected the schema, got %v (%v)", schema, err)
+	}
+}
+
+func TestHandleProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{TLD: "test", Dir: tmpDir, URLPort: 80}
+	apps := config.NewAppStore(cfg)
+	procs := process.NewManager()
+	s := newTestServer(cfg, apps, procs)
+	s.requestLog = process.NewLogBuffer(100)
+
+	yamlContent := `
+name: modes
+root: /tmp
+ready: none
+stop_signal: KILL
+stop_timeout: 100ms
+services:
+  web:
+    cmd: sleep 999
+  api:
+    cmd: sleep 999
+  jobs:
+    cmd: sleep 999
+    type: worker
+profiles:
+  staging:
+    description: Against staging
+    services:
+      api:
+        env:
+          DATABASE_URL: postgres://staging/modes
+  lean:
+    without: [jobs]
+`
+	if err := os.WriteFile(tmpDir+"/modes.yml", []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := apps.Load(); err != nil {
+		t.Fatalf("failed to load apps: %v", err)
+	}
+	s.startByName("modes")
+	defer func() {
+		app, _ := apps.Get("modes")
+		s.stopServices(app, appServices(app))
+		procs.Stop("jobs-modes")
+	}()
+
+	profile := func(query string) (int, profileResult) {
+		w := httptest.NewRecorder()
+		s.handleProfile(w, httptest.NewRequest("GET", "/api/profile?"+query, nil))
+		var result profileResult
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatalf("failed to parse response %q: %v", w.Body.String(), err)
+			}
+		}
+		return w.Code, result
+	}
+
+	t.Run("lists the profiles", func(t *testing.T) {
+		code, result := profile("name=modes")
+		if code != http.StatusOK || result.Profile != "" || len(result.Profiles) != 2 || result.Profiles[1].Description != "Against staging" {
+			t.Errorf("expected lean and staging with none active, got %d %+v", code, result)
+		}
+	})
+
+	t.Run("restarts only what the profile changes", func(t *testing.T) {
+		web, _ := procs.Get("web-modes")
+		code, result := profile("name=modes&profile=staging")
+		if code != http.StatusOK || result.Profile != "staging" {
+			t.Fatalf("expected staging active, got %d %+v", code, result)
+		}
+		if !slices.Equal(result.Restarted, []string{"api-modes"}) || len(result.Stopped) != 0 {
+			t.Errorf("expected only api restarted, got %+v", result)
+		}
+		if again, _ := procs.Get("web-modes"); again != web {
+			t.Error("expected web to keep running")
+		}
+		if app, _ := apps.Get("modes"); app.Profile != "staging" {
+			t.Errorf("expected the store to have staging active, got %q", app.Profile)
+		}
+	})
+
+	t.Run("stops services the profile leaves out", func(t *testing.T) {
+		_, result := profile("name=modes&profile=lean")
+		if !slices.Equal(result.Restarted, []string{"api-modes"}) || !slices.Equal(result.Stopped, []string{"jobs-modes"}) {
+			t.Errorf("expected api restarted (back to its own env) and jobs stopped, got %+v", result)
+		}
+		if proc, found := procs.Get("jobs-modes"); found && proc.IsRunning() {
+			t.Error("expected jobs to be stopped")
+		}
+	})
+
+	t.Run("brings them back with the default", func(t *testing.T) {
+		_, result := profile("name=modes&profile=default")
+		if result.Profile != "" || !slices.Equal(result.Restarted, []string{"jobs-modes"}) {
+			t.Errorf("expected jobs started again, got %+v", result)
+		}
+	})
+
+	t.Run("shows in the status", func(t *testing.T) {
+		profile("name=modes&profile=lean")
+		var status []appStatus
+		if err := json.Unmarshal(s.getStatus(), &status); err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		if len(status) != 1 || status[0].Profile != "lean" || len(status[0].Profiles) != 2 || len(status[0].Services) != 2 {
+			t.Errorf("expected modes on lean with two services, got %+v", status)
+		}
+	})
+
+	t.Run("unknown apps and profiles", func(t *testing.T) {
+		if code, _ := profile("name=nope&profile=lean"); code != http.StatusNotFound {
+			t.Errorf("expected 404 for an unknown app, got %d", code)
+		}
+		if code, _ := profile("name=modes&profile=prod"); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for an unknown profile, got %d", code)
+		}
+	})
+}
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/panozzaj/roost-dev/internal/config"
+	"github.com/panozzaj/roost-dev/internal/process"
+)
+
+// depsTimeout is how long a service waits for its dependencies to be running
+// before it's marked failed
+var depsTimeout = 2 * time.Minute
+
+// depsPollInterval is how often a waiting service checks its dependencies
+const depsPollInterval = 100 * time.Millisecond
+
+// depsRecheckInterval is how often requests to a running service check that
+// its dependencies are still running
+var depsRecheckInterval = 1 * time.Second
+
+// depWait is a service waiting for its dependencies before it starts
+type depWait struct {
+	waitingFor []string // Dependencies not running yet
+	err        string   // Why the wait failed (a dependency failed or it timed out)
+	cancel     chan struct{}
+}
+
+// startWithDeps starts a service once its dependencies are running. If they
+// already are it starts right away; otherwise the dependencies are started
+// and the service waits in the background (see depWaitStatus), returning a
+// nil process. It fails if a dependency can't be started at all.
+func (s *Server) startWithDeps(app *config.App, svc *config.Service) (*process.Process, error) {
+	procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+	pending, err := s.ensureDependencies(app, svc)
+	if err == nil && len(pending) == 0 {
+		s.cancelDepWait(procName)
+		return s.startService(app, svc)
+	}
+
+	s.depsMu.Lock()
+	if w, ok := s.depWaits[procName]; ok && w.err == "" {
+		s.depsMu.Unlock()
+		return nil, nil // Already waiting
+	}
+	if s.depWaits == nil {
+		s.depWaits = make(map[string]*depWait)
+	}
+	w := &depWait{waitingFor: pending, cancel: make(chan struct{})}
+	s.depWaits[procName] = w
+	s.depsMu.Unlock()
+
+	if err != nil {
+		s.failDepWait(procName, w, err.Error())
+		return nil, err
+	}
+
+	fmt.Printf("[roost-dev] %s waiting for %s\n", procName, strings.Join(pending, ", "))
+	go s.waitForDeps(app, svc, procName, w)
+	return nil, nil
+}
+
+// recheckDependencies brings back any dependency of a running service that
+// was stopped since it started. Requests to the service call it, so it only
+// checks once per depsRecheckInterval.
+func (s *Server) recheckDependencies(app *config.App, svc *config.Service) {
+	if len(svc.DependsOn) == 0 {
+		return
+	}
+	procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+	s.depsMu.Lock()
+	if time.Since(s.depChecks[procName]) < depsRecheckInterval {
+		s.depsMu.Unlock()
+		return
+	}
+	if s.depChecks == nil {
+		s.depChecks = make(map[string]time.Time)
+	}
+	s.depChecks[procName] = time.Now()
+	s.depsMu.Unlock()
+	s.ensureDependencies(app, svc)
+}
+
+// waitForDeps polls a service's dependencies and starts it once they're all
+// running, or marks the wait failed if one fails or depsTimeout passes
+func (s *Server) waitForDeps(app *config.App, svc *config.Service, procName string, w *depWait) {
+	ticker := time.NewTicker(depsPollInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(depsTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-w.cancel:
+			return
+		case <-timeout.C:
+			s.failDepWait(procName, w, fmt.Sprintf("timed out after %s waiting for %s", depsTimeout, strings.Join(w.waitingFor, ", ")))
+			return
+		case <-ticker.C:
+		}
+
+		var pending []string
+		for _, depName := range svc.DependsOn {
+			if s.findService(app, depName) == nil {
+				continue
+			}
+			running, err := s.depState(app, depName)
+			if err != nil {
+				s.failDepWait(procName, w, err.Error())
+				return
+			}
+			if !running {
+				pending = append(pending, depName)
+			}
+		}
+
+		s.depsMu.Lock()
+		if s.depWaits[procName] != w {
+			s.depsMu.Unlock()
+			return // Canceled while checking
+		}
+		if len(pending) > 0 {
+			changed := !slices.Equal(pending, w.waitingFor)
+			w.waitingFor = pending
+			s.depsMu.Unlock()
+			if changed {
+				s.broadcastStatus()
+			}
+			continue
+		}
+		s.depsMu.Unlock()
+
+		// Start before clearing the wait, so services waiting on this one
+		// never see it neither waiting nor started
+		if _, err := s.startService(app, svc); err != nil {
+			fmt.Printf("[roost-dev] Failed to start %s: %v\n", procName, err)
+		}
+		s.depsMu.Lock()
+		if s.depWaits[procName] == w {
+			delete(s.depWaits, procName)
+		}
+		s.depsMu.Unlock()
+		s.broadcastStatus()
+		return
+	}
+}
+
+// depState returns whether a dependency is running, or why it won't be
+func (s *Server) depState(app *config.App, depName string) (bool, error) {
+	procName := fmt.Sprintf("%s-%s", slugify(depName), app.Name)
+	if proc, found := s.procs.Get(procName); found {
+		switch {
+		case proc.IsRunning():
+			return true, nil
+		case proc.IsStarting():
+			return false, nil
+		case proc.HasFailed():
+			return false, fmt.Errorf("dependency %s failed: %s", depName, proc.ExitError())
+		}
+	}
+	// Not started: fine while it waits on its own dependencies
+	if _, errMsg, ok := s.depWaitStatus(procName); !ok {
+		return false, fmt.Errorf("dependency %s was stopped", depName)
+	} else if errMsg != "" {
+		return false, fmt.Errorf("dependency %s failed: %s", depName, errMsg)
+	}
+	return false, nil
+}
+
+// failDepWait marks a wait failed, keeping it so the status shows the error
+// until the service is started, restarted or stopped again
+func (s *Server) failDepWait(procName string, w *depWait, msg string) {
+	s.depsMu.Lock()
+	if s.depWaits[procName] != w {
+		s.depsMu.Unlock()
+		return
+	}
+	w.err = msg
+	s.depsMu.Unlock()
+	fmt.Printf("[roost-dev] %s not started: %s\n", procName, msg)
+	s.broadcastStatus()
+}
+
+// cancelDepWait stops a service waiting for its dependencies and clears any
+// failure from a previous wait
+func (s *Server) cancelDepWait(procName string) {
+	s.depsMu.Lock()
+	defer s.depsMu.Unlock()
+	if w, ok := s.depWaits[procName]; ok {
+		if w.err == "" {
+			close(w.cancel)
+		}
+		delete(s.depWaits, procName)
+	}
+}
+
+// depWaitStatus returns the dependencies a service is waiting for and the
+// error if the wait failed; ok is false if it isn't waiting
+func (s *Server) depWaitStatus(procName string) (waitingFor []string, errMsg string, ok bool) {
+	s.depsMu.Lock()
+	defer s.depsMu.Unlock()
+	w, ok := s.depWaits[procName]
+	if !ok {
+		return nil, "", false
+	}
+	return slices.Clone(w.waitingFor), w.err, true
+}
+
+// stopServices stops services of an app, dependents before the services they
+// depend on, canceling any waits for dependencies first so none fails on
+// seeing a dependency stop
+func (s *Server) stopServices(app *config.App, svcs []*config.Service) {
+	s.procs.StopGroup(s.cancelDepWaits(app, svcs))
+}
+
+// stopIdleServices is stopServices for services stopped for inactivity,
+// which then show as stopped (idle)
+func (s *Server) stopIdleServices(app *config.App, svcs []*config.Service) {
+	s.procs.StopIdleGroup(s.cancelDepWaits(app, svcs))
+}
+
+// cancelDepWaits cancels any waits for dependencies of services of an app
+// and returns their process names
+func (s *Server) cancelDepWaits(app *config.App, svcs []*config.Service) []string {
+	names := make([]string, len(svcs))
+	for i, svc := range svcs {
+		names[i] = fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+		s.cancelDepWait(names[i])
+	}
+	return names
+}
+
+// restartService restarts a service along with the services that restart
+// with it (restart_with) and are running, starting or waiting. It returns
+// the process names restarted: svc's first, then the others in start order.
+func (s *Server) restartService(app *config.App, svc *config.Service) []string {
+	svcs := []*config.Service{svc}
+	for _, other := range restartsWith(app, svc) {
+		procName := fmt.Sprintf("%s-%s", slugify(other.Name), app.Name)
+		_, found := s.procs.Get(procName)
+		if _, _, waiting := s.depWaitStatus(procName); found || waiting {
+			svcs = append(svcs, other)
+		}
+	}
+	s.stopServices(app, svcs)
+
+	// Start in dependency order; dependents wait for the restarted service
+	restarted := []string{fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)}
+	for i := range app.Services {
+		other := &app.Services[i]
+		if !slices.ContainsFunc(svcs, func(s *config.Service) bool { return s.Name == other.Name }) {
+			continue
+		}
+		s.startWithDeps(app, other)
+		if other.Name != svc.Name {
+			restarted = append(restarted, fmt.Sprintf("%s-%s", slugify(other.Name), app.Name))
+		}
+	}
+	return restarted
+}
+
+// restartWatched restarts a process whose watched files changed: a service
+// along with the services that restart with it, like restartService, and
+// anything else on its own. Either way it starts from the config again, so
+// its env and exec args are filled in with the ports it gets this time.
+func (s *Server) restartWatched(name string) {
+	app, svc := s.findProcess(name)
+	if app == nil {
+		s.procs.RestartAsync(name)
+		return
+	}
+	if svc == nil {
+		s.procs.Stop(name)
+		if _, err := s.startApp(app); err != nil {
+			fmt.Printf("[roost-dev] Failed to restart %s: %v\n", name, err)
+		}
+		s.broadcastStatus()
+		return
+	}
+	restarted := s.restartService(app, svc)
+	if len(restarted) > 1 {
+		s.logRequest("  Also restarted (restart_with): %s", strings.Join(restarted[1:], ", "))
+	}
+	s.broadcastStatus()
+}
+
+// restartsWith returns the services that restart along with svc: those
+// naming it in restart_with, and in turn those naming them
+func restartsWith(app *config.App, svc *config.Service) []*config.Service {
+	var result []*config.Service
+	queue := []string{svc.Name}
+	seen := map[string]bool{svc.Name: true}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for i := range app.Services {
+			other := &app.Services[i]
+			if !seen[other.Name] && slices.Contains(other.RestartWith, name) {
+				seen[other.Name] = true
+				result = append(result, other)
+				queue = append(queue, other.Name)
+			}
+		}
+	}
+	return result
+}
+
+// appServices returns pointers to all of an app's services
+func appServices(app *config.App) []*config.Service {
+	svcs := make([]*config.Service, len(app.Services))
+	for i := range app.Services {
+		svcs[i] = &app.Services[i]
+	}
+	return svcs
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"html"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/panozzaj/roost-dev/internal/config"
@@ -35,7 +36,7 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 	if strings.HasSuffix(host, ".roost-dev."+s.cfg.TLD) {
 		// Subdomain of roost-dev.test → route to roost-dev-tests services
 		subdomain := strings.TrimSuffix(host, ".roost-dev."+s.cfg.TLD)
-		if app, svc, found := s.apps.GetService("roost-dev-tests", subdomain); found {
+		if app, svc, found := s.routableService("roost-dev-tests", subdomain); found {
 			s.handleService(w, r, app, svc)
 			return
 		}
@@ -71,19 +72,29 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 	name := strings.TrimSuffix(host, "."+s.cfg.TLD)
 
 	// Check for service-app pattern (service-appname)
+	var worker *config.Service
 	if idx := strings.Index(name, "-"); idx != -1 {
 		serviceName := name[:idx]
 		appName := name[idx+1:]
 
 		// Try to find as multi-service app
 		app, service, found := s.apps.GetService(appName, serviceName)
-		if found {
+		if found && !service.Worker {
 			s.handleService(w, r, app, service)
 			return
 		}
+		if found {
+			worker = service // Not routed; explain below unless another app matches
+		}
 		// If not found as service, continue to try other patterns
 	}
 
+	// Named extra port: livereload.blog → blog's livereload port
+	if port, ok := s.namedPort(name); ok {
+		proxy.NewReverseProxy(port, s.getTheme()).ServeHTTP(w, r)
+		return
+	}
+
 	// Try progressively shorter names to support subdomains
 	// e.g., admin.myapp → try "admin.myapp", then "myapp"
 	app, found := s.findApp(name)
@@ -93,6 +104,16 @@ func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
 		app, found = s.findApp(name)
 	}
 
+	if !found && worker != nil {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, pages.Error(
+			"Worker not routable",
+			fmt.Sprintf("'%s' is a worker service with no port, so it has no URL", worker.Name),
+			fmt.Sprintf(`<p class="hint">See its status and logs at <a href="//roost-dev.%s">roost-dev.%s</a></p>`, s.cfg.TLD, s.cfg.TLD),
+			s.cfg.TLD, s.getTheme()))
+		return
+	}
 	if !found {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.WriteHeader(http.StatusNotFound)
@@ -130,6 +151,47 @@ func (s *Server) findApp(name string) (*config.App, bool) {
 	return nil, false
 }
 
+// namedPort resolves "<port>.<app>" or "<port>.<service>-<app>" to a running
+// process's named port (declared with ports: in the config)
+func (s *Server) namedPort(name string) (int, bool) {
+	portName, rest, ok := strings.Cut(name, ".")
+	if !ok {
+		return 0, false
+	}
+
+	var procName string
+	if app, found := s.apps.GetByNameOrAlias(rest); found {
+		switch app.Type {
+		case config.AppTypeCommand:
+			if slices.Contains(app.NamedPorts, portName) {
+				procName = app.Name
+			}
+		case config.AppTypeYAML:
+			// First service declaring the port, so livereload.blog works without naming the service
+			for _, svc := range app.Services {
+				if slices.Contains(svc.NamedPorts, portName) {
+					procName = fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+					break
+				}
+			}
+		}
+	} else if idx := strings.Index(rest, "-"); idx != -1 {
+		if app, svc, found := s.apps.GetService(rest[idx+1:], rest[:idx]); found && slices.Contains(svc.NamedPorts, portName) {
+			procName = fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+		}
+	}
+	if procName == "" {
+		return 0, false
+	}
+
+	proc, found := s.procs.Get(procName)
+	if !found || !proc.IsRunning() || proc.Ports[portName] == 0 {
+		return 0, false
+	}
+	proc.Touch()
+	return proc.Ports[portName], true
+}
+
 // handleApp handles a request for a simple app
 func (s *Server) handleApp(w http.ResponseWriter, r *http.Request, app *config.App) {
 	switch app.Type {
@@ -142,6 +204,7 @@ func (s *Server) handleApp(w http.ResponseWriter, r *http.Request, app *config.A
 		proc, found := s.procs.Get(app.Name)
 		if found && proc.IsRunning() {
 			// Already running - proxy directly
+			proc.Touch()
 			proxy.NewReverseProxy(proc.Port, s.getTheme()).ServeHTTP(w, r)
 			return
 		}
@@ -160,7 +223,7 @@ func (s *Server) handleApp(w http.ResponseWriter, r *http.Request, app *config.A
 			return
 		}
 		// Idle - start async and show interstitial
-		_, err := s.procs.StartAsync(app.Name, app.Command, app.Dir, app.Env)
+		_, err := s.startApp(app)
 		if err != nil {
 			// Immediate failure (e.g., directory doesn't exist)
 			w.Header().Set("Content-Type", "text/html")
@@ -195,6 +258,9 @@ func (s *Server) handleApp(w http.ResponseWriter, r *http.Request, app *config.A
 		w.Header().Set("Content-Type", "text/html")
 		fmt.Fprintf(w, "<h1>%s</h1>\n<p>Available services:</p>\n<ul>\n", app.Name)
 		for _, svc := range app.Services {
+			if svc.Worker {
+				continue
+			}
 			url := fmt.Sprintf("http://%s-%s.%s", slugify(svc.Name), app.Name, s.cfg.TLD)
 			fmt.Fprintf(w, "<li><a href=\"%s\">%s</a></li>\n", url, svc.Name)
 		}
@@ -202,6 +268,16 @@ func (s *Server) handleApp(w http.ResponseWriter, r *http.Request, app *config.A
 	}
 }
 
+// routableService finds a service that is served at its own hostname, which
+// workers aren't
+func (s *Server) routableService(appName, serviceName string) (*config.App, *config.Service, bool) {
+	app, svc, found := s.apps.GetService(appName, serviceName)
+	if !found || svc.Worker {
+		return nil, nil, false
+	}
+	return app, svc, true
+}
+
 // findService finds a service by name within an app
 func (s *Server) findService(app *config.App, name string) *config.Service {
 	for i := range app.Services {
@@ -212,8 +288,11 @@ func (s *Server) findService(app *config.App, name string) *config.Service {
 	return nil
 }
 
-// ensureDependencies starts any dependencies that aren't already running
-func (s *Server) ensureDependencies(app *config.App, svc *config.Service) {
+// ensureDependencies starts any dependencies that aren't already running or
+// starting, and returns the names of those that aren't running yet. It fails
+// if a dependency can't be started at all.
+func (s *Server) ensureDependencies(app *config.App, svc *config.Service) ([]string, error) {
+	var pending []string
 	for _, depName := range svc.DependsOn {
 		dep := s.findService(app, depName)
 		if dep == nil {
@@ -221,11 +300,18 @@ func (s *Server) ensureDependencies(app *config.App, svc *config.Service) {
 		}
 		procName := fmt.Sprintf("%s-%s", slugify(dep.Name), app.Name)
 		proc, found := s.procs.Get(procName)
-		if !found || (!proc.IsRunning() && !proc.IsStarting()) {
-			// Start the dependency
-			s.procs.StartAsync(procName, dep.Command, dep.Dir, dep.Env)
+		if found && proc.IsRunning() {
+			continue
+		}
+		if !found || !proc.IsStarting() {
+			// Start the dependency (once its own dependencies are running)
+			if _, err := s.startWithDeps(app, dep); err != nil {
+				return nil, fmt.Errorf("dependency %s failed: %v", depName, err)
+			}
 		}
+		pending = append(pending, depName)
 	}
+	return pending, nil
 }
 
 // handleService handles a request for a service within a multi-service app
@@ -240,9 +326,6 @@ func (s *Server) handleService(w http.ResponseWriter, r *http.Request, app *conf
 	configName := app.Name // e.g., "roost-dev-tests"
 	s.logRequest("handleService: %s (path=%s)", procName, r.URL.Path)
 
-	// Start dependencies first
-	s.ensureDependencies(app, svc)
-
 	// Check process status and serve appropriately
 	proc, found := s.procs.Get(procName)
 	s.logRequest("  %s: found=%v, running=%v, starting=%v, failed=%v",
@@ -254,6 +337,8 @@ func (s *Server) handleService(w http.ResponseWriter, r *http.Request, app *conf
 	if found && proc.IsRunning() {
 		// Already running - proxy directly
 		s.logRequest("  -> PROXY to port %d", proc.Port)
+		s.recheckDependencies(app, svc)
+		proc.Touch()
 		proxy.NewReverseProxy(proc.Port, s.getTheme()).ServeHTTP(w, r)
 		return
 	}
@@ -273,9 +358,17 @@ func (s *Server) handleService(w http.ResponseWriter, r *http.Request, app *conf
 		w.Write([]byte(pages.Interstitial(procName, displayName, configName, s.cfg.TLD, s.getTheme(), false, "")))
 		return
 	}
-	// Idle - start async and show interstitial
+	if _, errMsg, waiting := s.depWaitStatus(procName); waiting {
+		// Waiting for dependencies, or gave up on them
+		s.logRequest("  -> INTERSTITIAL (waiting for deps)")
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate")
+		w.Write([]byte(pages.Interstitial(procName, displayName, configName, s.cfg.TLD, s.getTheme(), errMsg != "", errMsg)))
+		return
+	}
+	// Idle - start async (once dependencies are running) and show interstitial
 	s.logRequest("  -> INTERSTITIAL (idle, starting %s)", procName)
-	_, err := s.procs.StartAsync(procName, svc.Command, svc.Dir, svc.Env)
+	_, err := s.startWithDeps(app, svc)
 	if err != nil {
 		// Immediate failure (e.g., directory doesn't exist)
 		s.logRequest("  -> FAILED to start: %v", err)
@@ -306,17 +399,13 @@ func (s *Server) startByName(name string) {
 	if app, found := s.apps.Get(name); found {
 		switch app.Type {
 		case config.AppTypeCommand:
-			s.procs.StartAsync(app.Name, app.Command, app.Dir, app.Env)
+			s.startApp(app)
 		case config.AppTypeYAML:
-			// Start all services for multi-service app, respecting depends_on
-			// TODO: Consider pre-allocating ports and passing PORT_<SERVICE> env vars
-			// to each service, so they can reference each other's ports directly.
-			// Example: frontend could use $PORT_API to connect to the api service.
+			// Start all services for multi-service app, respecting depends_on.
+			// Each service gets its siblings' ports and URLs (see siblingEnv).
 			for i := range app.Services {
 				svc := &app.Services[i]
-				s.ensureDependencies(app, svc)
-				procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
-				s.procs.StartAsync(procName, svc.Command, svc.Dir, svc.Env)
+				s.startWithDeps(app, svc)
 			}
 		}
 		return
@@ -331,9 +420,8 @@ func (s *Server) startByName(name string) {
 			svc := &app.Services[i]
 			procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
 			if procName == name {
-				// Start dependencies first
-				s.ensureDependencies(app, svc)
-				s.procs.StartAsync(procName, svc.Command, svc.Dir, svc.Env)
+				// Starts once its dependencies are running
+				s.startWithDeps(app, svc)
 				return
 			}
 		}
@@ -479,7 +567,7 @@ func (s *Server) handleTailscaleRequest(w http.ResponseWriter, r *http.Request)
 	if idx := strings.Index(name, "-"); idx != -1 {
 		serviceName := name[:idx]
 		appName := name[idx+1:]
-		if app, svc, found := s.apps.GetService(appName, serviceName); found {
+		if app, svc, found := s.routableService(appName, serviceName); found {
 			s.handleService(w, r, app, svc)
 			return
 		}
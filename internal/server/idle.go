@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/panozzaj/roost-dev/internal/config"
+)
+
+// idleCheckInterval is how often running processes are checked for inactivity
+const idleCheckInterval = 15 * time.Second
+
+// idleTimeout returns how long an app may go without requests before it is stopped (0 = never)
+func (s *Server) idleTimeout(app *config.App) time.Duration {
+	if app.IdleTimeout != nil {
+		return *app.IdleTimeout
+	}
+	return s.cfg.IdleTimeout
+}
+
+// watchIdle periodically stops processes that have not received requests recently
+func (s *Server) watchIdle() {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if s.stopIdleProcesses(now) {
+			s.broadcastStatus()
+		}
+	}
+}
+
+// stopIdleProcesses stops every running app whose idle timeout has elapsed.
+// A multi-service app is active while any of its services gets requests, so
+// workers and services nothing depends on keep running along with the rest;
+// once it's idle all of its services stop, dependents first.
+// Returns true if anything was stopped.
+func (s *Server) stopIdleProcesses(now time.Time) bool {
+	stopped := false
+	for _, app := range s.apps.All() {
+		timeout := s.idleTimeout(app)
+		if timeout <= 0 {
+			continue
+		}
+
+		switch app.Type {
+		case config.AppTypeCommand:
+			proc, found := s.procs.Get(app.Name)
+			if !found || (!proc.IsRunning() && !proc.IsStarting()) {
+				continue
+			}
+			if now.Sub(proc.LastRequest()) >= timeout {
+				s.logRequest("Stopping %s (no requests for %s)", app.Name, timeout)
+				s.procs.StopIdle(app.Name)
+				stopped = true
+			}
+
+		case config.AppTypeYAML:
+			// Last activity of any running service
+			var lastActive time.Time
+			running := false
+			for _, svc := range app.Services {
+				procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+				if proc, found := s.procs.Get(procName); found && (proc.IsRunning() || proc.IsStarting()) {
+					running = true
+					if last := proc.LastRequest(); last.After(lastActive) {
+						lastActive = last
+					}
+				}
+			}
+			if !running || now.Sub(lastActive) < timeout {
+				continue
+			}
+			s.logRequest("Stopping %s (no requests for %s)", app.Name, timeout)
+			s.stopIdleServices(app, appServices(app))
+			stopped = true
+		}
+	}
+	return stopped
+}
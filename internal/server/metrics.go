@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/panozzaj/roost-dev/internal/config"
+	"github.com/panozzaj/roost-dev/internal/process"
+)
+
+// metricsInterval is how often CPU and memory use are sampled
+const metricsInterval = 5 * time.Second
+
+// metricsStatus is the resource use of a running process and its children
+type metricsStatus struct {
+	CPU      float64 `json:"cpu"`               // Percent of one core
+	Memory   int64   `json:"memory"`            // Resident memory in bytes
+	Threads  int     `json:"threads,omitempty"` // Not reported on every platform
+	Children int     `json:"children"`          // Processes besides the main one
+}
+
+// newMetricsStatus returns the last sample of a process's resource use, or
+// nil if it hasn't been sampled yet
+func newMetricsStatus(proc *process.Process) *metricsStatus {
+	m := proc.Metrics()
+	if m.Sampled.IsZero() {
+		return nil
+	}
+	return &metricsStatus{CPU: m.CPU, Memory: m.RSS, Threads: m.Threads, Children: m.Children}
+}
+
+// watchMetrics periodically samples the resource use of running processes
+func (s *Server) watchMetrics() {
+	ticker := time.NewTicker(metricsInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.procs.SampleMetrics(); err != nil {
+			fmt.Printf("[roost-dev] Failed to sample process metrics: %v\n", err)
+		}
+	}
+}
+
+// psEntry is one running process and its tree, as returned by /api/ps
+type psEntry struct {
+	Name    string            `json:"name"`              // Process name (service-app for services)
+	App     string            `json:"app"`               // App it belongs to
+	Service string            `json:"service,omitempty"` // Service name, for multi-service apps
+	Port    int               `json:"port,omitempty"`
+	Ports   map[string]int    `json:"ports,omitempty"`
+	Metrics *metricsStatus    `json:"metrics,omitempty"`
+	Tree    *process.ProcTree `json:"tree"`
+}
+
+// handlePS returns the process tree of every running app and service
+func (s *Server) handlePS(w http.ResponseWriter, r *http.Request) {
+	trees, err := s.procs.Trees()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	entries := []psEntry{}
+	add := func(name string, app *config.App, svc *config.Service) {
+		tree, ok := trees[name]
+		if !ok {
+			return
+		}
+		proc, ok := s.procs.Get(name)
+		if !ok {
+			return
+		}
+		e := psEntry{Name: name, App: app.Name, Port: proc.Port, Ports: proc.Ports, Metrics: newMetricsStatus(proc), Tree: tree}
+		if svc != nil {
+			e.Service = svc.Name
+		}
+		entries = append(entries, e)
+	}
+	for _, app := range s.apps.All() {
+		switch app.Type {
+		case config.AppTypeCommand:
+			add(app.Name, app, nil)
+		case config.AppTypeYAML:
+			for i := range app.Services {
+				svc := &app.Services[i]
+				add(fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name), app, svc)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
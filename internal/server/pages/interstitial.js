@@ -137,6 +137,15 @@ function analyzeLogsWithAI(lines) {
         })
 }
 
+// Status line for the phase of a starting process, so a long hook
+// (bundle install, migrations) or a slow dependency isn't mistaken for a slow boot
+function phaseText(phase, waitingFor) {
+    if (phase === 'waiting_for_deps') return 'Waiting for ' + (waitingFor || []).join(', ') + '...'
+    if (phase === 'before_start') return 'Running before_start hook...'
+    if (phase === 'after_ready') return 'Running after_ready hook...'
+    return 'Starting...'
+}
+
 function poll() {
     Promise.all([
         fetch(baseUrl + '/api/app-status?name=' + encodeURIComponent(appName)),
@@ -174,6 +183,8 @@ function poll() {
             } else if (status.status === 'failed') {
                 showError(status.error)
                 return
+            } else if (status.status === 'starting') {
+                document.getElementById('status').textContent = phaseText(status.phase, status.waitingFor)
             }
             setTimeout(poll, 200)
         })
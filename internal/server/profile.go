@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/panozzaj/roost-dev/internal/config"
+	"github.com/panozzaj/roost-dev/internal/process"
+)
+
+// profileResult is the response to /api/profile
+type profileResult struct {
+	App       string           `json:"app"`
+	Profile   string           `json:"profile,omitempty"` // Active profile, empty when none is
+	Profiles  []config.Profile `json:"profiles"`
+	Restarted []string         `json:"restarted,omitempty"` // Processes restarted (or started) for the switch
+	Stopped   []string         `json:"stopped,omitempty"`   // Processes of services the profile leaves out
+}
+
+// handleProfile reports an app's profiles, or with profile set switches the
+// app to it and restarts the processes it changes
+func (s *Server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	profile := r.URL.Query().Get("profile")
+
+	app, found := s.apps.GetByNameOrAlias(name)
+	if !found {
+		http.Error(w, fmt.Sprintf("unknown app %q", name), http.StatusNotFound)
+		return
+	}
+	result := profileResult{App: app.Name, Profile: app.Profile, Profiles: app.Profiles}
+	if profile != "" {
+		s.logRequest("API profile called for: %s (%s)", app.Name, profile)
+		var err error
+		if result, err = s.switchProfile(app, profile); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.broadcastStatus()
+	}
+	if result.Profiles == nil {
+		result.Profiles = []config.Profile{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// switchProfile makes profile the active profile of app (DefaultProfile for
+// none). If the app is running, processes whose command or env the switch
+// changes are restarted, services it leaves out are stopped and services it
+// brings back are started; the rest keep running.
+func (s *Server) switchProfile(app *config.App, profile string) (profileResult, error) {
+	active := s.activeProcesses(app)
+	if err := s.apps.SetProfile(app.Name, profile); err != nil {
+		return profileResult{}, err
+	}
+	updated, found := s.apps.Get(app.Name)
+	if !found {
+		return profileResult{}, fmt.Errorf("%s was removed", app.Name)
+	}
+	result := profileResult{App: updated.Name, Profile: updated.Profile, Profiles: updated.Profiles}
+	s.logRequest("Switched %s to profile %s", app.Name, profile)
+	if len(active) == 0 {
+		return result, nil
+	}
+
+	switch updated.Type {
+	case config.AppTypeCommand:
+		if specChanged(configSpec(app, nil), configSpec(updated, nil)) {
+			s.logRequest("  Restarting %s (profile changed)", app.Name)
+			s.procs.Stop(app.Name)
+			s.startApp(updated)
+			result.Restarted = append(result.Restarted, app.Name)
+		}
+
+	case config.AppTypeYAML:
+		var removed []*config.Service
+		for i := range app.Services {
+			svc := &app.Services[i]
+			procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+			if s.findService(updated, svc.Name) == nil && active[procName] {
+				removed = append(removed, svc)
+				result.Stopped = append(result.Stopped, procName)
+			}
+		}
+		if len(removed) > 0 {
+			s.logRequest("  Stopping %s (not in profile)", strings.Join(result.Stopped, ", "))
+			s.stopServices(app, removed)
+		}
+
+		for i := range updated.Services {
+			svc := &updated.Services[i]
+			procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+			if slices.Contains(result.Restarted, procName) {
+				continue // Already restarted along with another service
+			}
+			old := s.findService(app, svc.Name)
+			switch {
+			case old == nil:
+				s.logRequest("  Starting %s (back in profile)", procName)
+				s.startWithDeps(updated, svc)
+				result.Restarted = append(result.Restarted, procName)
+			case !active[procName]:
+			case specChanged(configSpec(app, old), configSpec(updated, svc)):
+				s.logRequest("  Restarting %s (profile changed)", procName)
+				for _, name := range s.restartService(updated, svc) {
+					if !slices.Contains(result.Restarted, name) {
+						result.Restarted = append(result.Restarted, name)
+					}
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// activeProcesses returns the names of an app's processes that are running,
+// starting or waiting for their dependencies
+func (s *Server) activeProcesses(app *config.App) map[string]bool {
+	names := []string{app.Name}
+	for _, svc := range app.Services {
+		names = append(names, fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name))
+	}
+	active := make(map[string]bool)
+	for _, name := range names {
+		if proc, found := s.procs.Get(name); found && (proc.IsRunning() || proc.IsStarting()) {
+			active[name] = true
+		} else if _, _, waiting := s.depWaitStatus(name); waiting {
+			active[name] = true
+		}
+	}
+	return active
+}
+
+// specChanged returns true if a process started from b would run a
+// different command or environment than one started from a
+func specChanged(a, b process.Spec) bool {
+	return a.Command != b.Command || !slices.Equal(a.Opts.Exec, b.Opts.Exec) || !maps.Equal(a.Env, b.Env)
+}
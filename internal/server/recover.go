@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/panozzaj/roost-dev/internal/config"
+	"github.com/panozzaj/roost-dev/internal/process"
+)
+
+// recoverProcesses adopts the processes a previous roost-dev run left
+// running if they still match their config, and kills the rest
+func (s *Server) recoverProcesses() {
+	adopted, killed := s.procs.Recover(s.processSpec)
+	if len(adopted) > 0 {
+		s.logRequest("Adopted processes from a previous run: %s", strings.Join(adopted, ", "))
+	}
+	if len(killed) > 0 {
+		s.logRequest("Killed stale processes from a previous run: %s", strings.Join(killed, ", "))
+	}
+
+	// Sibling ports can only be worked out once the adopted services hold
+	// theirs, so automatic restarts of adopted services get them now
+	for _, name := range adopted {
+		if app, svc := s.findProcess(name); svc != nil {
+			s.procs.SetSiblingEnv(name, s.siblingEnv(app))
+		}
+	}
+}
+
+// processSpec returns how the named process would be started with the
+// current config. References are filled in with the ports the processes got
+// last time, which the ones being recovered still hold.
+func (s *Server) processSpec(name string) (process.Spec, bool) {
+	app, svc := s.findProcess(name)
+	if app == nil {
+		return process.Spec{}, false
+	}
+	ports := make(map[string]int)
+	for procName := range preferredPorts(app) {
+		ports[procName] = s.procs.LastPort(procName)
+	}
+	spec := configSpec(app, svc)
+	if err := interpolate(&spec, s.configVars(app, svc, ports)); err != nil {
+		return configSpec(app, svc), true // Won't match, so it's killed
+	}
+	return spec, true
+}
+
+// findProcess returns the app (and service, for multi-service apps) that
+// runs as the named process, or nil if none does
+func (s *Server) findProcess(name string) (*config.App, *config.Service) {
+	for _, app := range s.apps.All() {
+		switch app.Type {
+		case config.AppTypeCommand:
+			if app.Name == name {
+				return app, nil
+			}
+		case config.AppTypeYAML:
+			for i := range app.Services {
+				if fmt.Sprintf("%s-%s", slugify(app.Services[i].Name), app.Name) == name {
+					return app, &app.Services[i]
+				}
+			}
+		}
+	}
+	return nil, nil
+}
@@ -3,10 +3,13 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/panozzaj/roost-dev/internal/certs"
@@ -50,6 +53,10 @@ type Server struct {
 	broadcaster   *Broadcaster       // SSE broadcaster for real-time updates
 	configWatcher *config.Watcher    // Watches config directory for changes
 	ollamaClient  *ollama.Client     // Optional LLM client for log analysis
+
+	depsMu    sync.Mutex
+	depWaits  map[string]*depWait  // Services waiting for their dependencies, by process name
+	depChecks map[string]time.Time // When running services last had their dependencies rechecked
 }
 
 // New creates a new server
@@ -66,6 +73,17 @@ func New(cfg *config.Config) (*Server, error) {
 		requestLog:  process.NewLogBuffer(500), // Keep last 500 request log entries
 		broadcaster: NewBroadcaster(),
 	}
+	s.logDiagnostics()
+	s.procs.SetWatchRestart(s.restartWatched)
+	s.procs.SetLogDir(filepath.Join(cfg.Dir, "logs"))
+	if err := s.procs.SetPortsFile(filepath.Join(cfg.Dir, "ports.json")); err != nil {
+		fmt.Printf("Warning: could not load port assignments: %v\n", err)
+	}
+	s.procs.SetStateFile(filepath.Join(cfg.Dir, "processes.json"))
+	s.recoverProcesses()
+	// Resolve the login environment in the background so the first process
+	// with shell: plain or none doesn't wait for it
+	go process.LoginEnv()
 
 	// Initialize Ollama client if configured
 	if cfg.Ollama != nil && cfg.Ollama.Enabled {
@@ -77,7 +95,20 @@ func New(cfg *config.Config) (*Server, error) {
 	watcher, err := config.NewWatcher(cfg.Dir, func(changedFiles []string) {
 		// Build a set of app names whose configs changed
 		changedApps := make(map[string]bool)
+		envFiles := s.apps.EnvFiles()
+		linkedFiles := s.apps.LinkedFiles()
 		for _, filename := range changedFiles {
+			// Env files and linked project configs are reported by full path;
+			// restart the apps that read them
+			if filepath.IsAbs(filename) {
+				for _, appName := range envFiles[filename] {
+					changedApps[appName] = true
+				}
+				if appName, ok := linkedFiles[filename]; ok {
+					changedApps[appName] = true
+				}
+				continue
+			}
 			// Strip .yml/.yaml extension to get app name
 			appName := strings.TrimSuffix(filename, ".yml")
 			appName = strings.TrimSuffix(appName, ".yaml")
@@ -113,6 +144,8 @@ func New(cfg *config.Config) (*Server, error) {
 			s.logRequest("Config reload error: %v", err)
 			return
 		}
+		s.logDiagnostics()
+		s.watchAppFiles()
 
 		// Collect process names for apps after reload
 		newProcessNames := s.collectProcessNames()
@@ -137,13 +170,12 @@ func New(cfg *config.Config) (*Server, error) {
 			switch app.Type {
 			case config.AppTypeCommand:
 				s.procs.Stop(appName)
-				s.procs.StartAsync(appName, app.Command, app.Dir, app.Env)
+				s.startApp(app)
 			case config.AppTypeYAML:
-				// Restart all services for this app
-				for _, svc := range app.Services {
-					procName := fmt.Sprintf("%s-%s", slugify(svc.Name), appName)
-					s.procs.Stop(procName)
-					s.procs.StartAsync(procName, svc.Command, svc.Dir, svc.Env)
+				// Restart all services for this app, stopping dependents first
+				s.stopServices(app, appServices(app))
+				for i := range app.Services {
+					s.startWithDeps(app, &app.Services[i])
 				}
 			}
 		}
@@ -156,16 +188,47 @@ func New(cfg *config.Config) (*Server, error) {
 		fmt.Printf("Warning: could not watch config directory: %v\n", err)
 	} else {
 		s.configWatcher = watcher
+		s.watchAppFiles()
 	}
 
 	return s, nil
 }
 
+// logDiagnostics prints the problems found in the configs at the last load
+func (s *Server) logDiagnostics() {
+	for _, d := range s.apps.Diagnostics() {
+		fmt.Printf("Warning: %s\n", d)
+	}
+}
+
+// watchAppFiles has the config watcher watch the env files apps read and the
+// project configs linked into the config directory
+func (s *Server) watchAppFiles() {
+	if s.configWatcher == nil {
+		return
+	}
+	files := slices.Collect(maps.Keys(s.apps.EnvFiles()))
+	files = append(files, slices.Collect(maps.Keys(s.apps.LinkedFiles()))...)
+	s.configWatcher.WatchFiles(files)
+}
+
 // getCertsDir returns the path to the certs directory
 func (s *Server) getCertsDir() string {
 	return filepath.Join(s.cfg.Dir, "certs")
 }
 
+// publicURL returns the URL browsers use for name (a hostname without the TLD),
+// https when certificates are set up and ports 80/443 are forwarded
+func (s *Server) publicURL(name string) string {
+	if s.cfg.URLPort != 80 {
+		return fmt.Sprintf("http://%s.%s:%d", name, s.cfg.TLD, s.cfg.URLPort)
+	}
+	if certs.CAExists(s.getCertsDir()) {
+		return fmt.Sprintf("https://%s.%s", name, s.cfg.TLD)
+	}
+	return fmt.Sprintf("http://%s.%s", name, s.cfg.TLD)
+}
+
 // Start starts the HTTP server
 func (s *Server) Start() error {
 	// Start config watcher
@@ -173,6 +236,12 @@ func (s *Server) Start() error {
 		s.configWatcher.Start()
 	}
 
+	// Stop apps that nobody has requested for a while
+	go s.watchIdle()
+
+	// Sample CPU and memory use for the dashboard and roost-dev ps
+	go s.watchMetrics()
+
 	// Periodic status broadcast to catch state changes (process ready/failed)
 	go func() {
 		ticker := time.NewTicker(1 * time.Second)
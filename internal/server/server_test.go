@@ -1,9 +1,13 @@
 package server
 
 import (
+	"net/http/httptest"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/panozzaj/roost-dev/internal/config"
 	"github.com/panozzaj/roost-dev/internal/process"
@@ -137,6 +141,28 @@ func TestEnsureDependencies(t *testing.T) {
 		procs.Stop("api-myapp")
 	})
 
+	t.Run("rechecks a running service's dependencies at most once per interval", func(t *testing.T) {
+		defer func(d time.Duration) { depsRecheckInterval = d }(depsRecheckInterval)
+		depsRecheckInterval = time.Hour
+		webSvc := s.findService(app, "web")
+		s.recheckDependencies(app, webSvc)
+		if _, found := procs.Get("api-myapp"); !found {
+			t.Fatal("expected api-myapp to be brought back")
+		}
+		procs.Stop("api-myapp")
+
+		s.recheckDependencies(app, webSvc)
+		if _, found := procs.Get("api-myapp"); found {
+			t.Error("expected no recheck so soon after the last")
+		}
+		depsRecheckInterval = 0
+		s.recheckDependencies(app, webSvc)
+		if _, found := procs.Get("api-myapp"); !found {
+			t.Error("expected api-myapp to be brought back once the interval passed")
+		}
+		procs.Stop("api-myapp")
+	})
+
 	t.Run("handles service with no dependencies", func(t *testing.T) {
 		apiSvc := s.findService(app, "api")
 		if apiSvc == nil {
@@ -262,23 +288,30 @@ func TestEnsureDependenciesIntegration(t *testing.T) {
 		}
 
 		// Start dependencies for c
-		s.ensureDependencies(app, cSvc)
-
-		// b should be starting (depends on by c)
-		bProc, bFound := procs.Get("b-chainapp")
-		if !bFound {
-			t.Error("expected b-chainapp to be started as dependency of c")
-		} else if !bProc.IsStarting() && !bProc.IsRunning() {
-			t.Error("expected b-chainapp to be starting or running")
+		pending, err := s.ensureDependencies(app, cSvc)
+		if err != nil || !slices.Equal(pending, []string{"b"}) {
+			t.Errorf("expected c to wait for b, got %v, %v", pending, err)
+		}
+		defer s.stopServices(app, appServices(app))
+
+		// a is started right away, since it has no dependencies
+		aProc, aFound := procs.Get("a-chainapp")
+		if !aFound {
+			t.Error("expected a-chainapp to be started as dependency of b")
+		} else if !aProc.IsStarting() && !aProc.IsRunning() {
+			t.Error("expected a-chainapp to be starting or running")
 		}
 
-		// Note: ensureDependencies only starts direct dependencies,
-		// not transitive ones. This is intentional - each service
-		// call ensureDependencies for itself.
+		// b waits for a to be running before starting
+		if _, found := procs.Get("b-chainapp"); found {
+			t.Error("expected b-chainapp not to start before a is running")
+		}
+		if waitingFor, _, waiting := s.depWaitStatus("b-chainapp"); !waiting || !slices.Equal(waitingFor, []string{"a"}) {
+			t.Errorf("expected b-chainapp to be waiting for a, got %v", waitingFor)
+		}
 
 		// Clean up
 		procs.Stop("a-chainapp")
-		procs.Stop("b-chainapp")
 	})
 }
 
@@ -798,3 +831,332 @@ services:
 		t.Error("multi-service app should list services, not app name")
 	}
 }
+
+func TestStopIdleProcesses(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{TLD: "test", Dir: tmpDir, IdleTimeout: 300 * time.Millisecond}
+	apps := config.NewAppStore(cfg)
+	procs := process.NewManager()
+	s := newTestServer(cfg, apps, procs)
+	s.requestLog = process.NewLogBuffer(100)
+
+	yamlContent := `
+name: idleapp
+root: /tmp
+stop_timeout: 100ms
+services:
+  api:
+    cmd: sleep 999
+  worker:
+    type: worker
+    cmd: sleep 999
+  web:
+    cmd: sleep 999
+    depends_on: [api]
+`
+	if err := os.WriteFile(tmpDir+"/idleapp.yml", []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := apps.Load(); err != nil {
+		t.Fatalf("failed to load apps: %v", err)
+	}
+	app, _ := apps.Get("idleapp")
+
+	for i := range app.Services {
+		if _, err := s.startService(app, &app.Services[i]); err != nil {
+			t.Fatalf("failed to start %s: %v", app.Services[i].Name, err)
+		}
+	}
+	defer procs.StopAll()
+
+	t.Run("nothing is stopped before the timeout", func(t *testing.T) {
+		if s.stopIdleProcesses(time.Now()) {
+			t.Error("expected no processes to be stopped yet")
+		}
+	})
+
+	t.Run("keeps every service running while any is active", func(t *testing.T) {
+		time.Sleep(400 * time.Millisecond)
+		web, _ := procs.Get("web-idleapp")
+		web.Touch()
+
+		if s.stopIdleProcesses(time.Now()) {
+			t.Fatal("expected nothing to be stopped while web is active")
+		}
+		for _, name := range []string{"api-idleapp", "worker-idleapp", "web-idleapp"} {
+			if _, found := procs.Get(name); !found {
+				t.Errorf("expected %s to keep running while web is active", name)
+			}
+		}
+	})
+
+	t.Run("per-app timeout of zero disables stopping", func(t *testing.T) {
+		never := time.Duration(0)
+		app.IdleTimeout = &never
+		defer func() { app.IdleTimeout = nil }()
+
+		if s.stopIdleProcesses(time.Now().Add(time.Hour)) {
+			t.Error("expected nothing to be stopped when idle_timeout is 0")
+		}
+	})
+
+	t.Run("stops the whole app once no service is active", func(t *testing.T) {
+		if !s.stopIdleProcesses(time.Now().Add(time.Second)) {
+			t.Fatal("expected the idle app to be stopped")
+		}
+		for _, name := range []string{"api-idleapp", "worker-idleapp", "web-idleapp"} {
+			if _, found := procs.Get(name); found {
+				t.Errorf("expected %s to be stopped", name)
+			}
+			if !procs.IsIdleStopped(name) {
+				t.Errorf("expected %s to be marked as stopped for inactivity", name)
+			}
+		}
+	})
+}
+
+func TestSiblingEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{TLD: "test", Dir: tmpDir, URLPort: 80}
+	apps := config.NewAppStore(cfg)
+	procs := process.NewManager()
+	s := newTestServer(cfg, apps, procs)
+
+	yamlContent := `
+name: siblings
+root: /tmp
+services:
+  api:
+    cmd: sleep 999
+  web-ui:
+    cmd: sleep 999
+`
+	if err := os.WriteFile(tmpDir+"/siblings.yml", []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := apps.Load(); err != nil {
+		t.Fatalf("failed to load apps: %v", err)
+	}
+	app, _ := apps.Get("siblings")
+
+	env := s.siblingEnv(app)
+	if env["PORT_API"] == "" || env["PORT_API"] == env["PORT_WEB_UI"] {
+		t.Fatalf("expected distinct ports for api and web-ui, got %v", env)
+	}
+	if got := env["URL_WEB_UI"]; got != "http://web-ui-siblings.test" {
+		t.Errorf("expected public URL http://web-ui-siblings.test, got %q", got)
+	}
+	if got, want := env["INTERNAL_URL_API"], "http://127.0.0.1:"+env["PORT_API"]; got != want {
+		t.Errorf("expected internal URL %q, got %q", want, got)
+	}
+
+	// The assignment is stable, so services started later see the same ports
+	if again := s.siblingEnv(app); again["PORT_API"] != env["PORT_API"] {
+		t.Errorf("expected api port to stay %s, got %s", env["PORT_API"], again["PORT_API"])
+	}
+}
+
+func TestStartSpec(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.Mkdir(tmpDir+"/web", 0755)
+	cfg := &config.Config{TLD: "test", Dir: tmpDir, URLPort: 80}
+	apps := config.NewAppStore(cfg)
+	s := newTestServer(cfg, apps, process.NewManager())
+
+	yamlContent := `
+name: refs
+root: ` + tmpDir + `
+services:
+  api:
+    cmd: bin/api --port ${port}
+  web:
+    dir: ${root}/web
+    exec: [bin/web, --api, "${service.api.url}", --port, $PORT, $HOST]
+    ports: [debug]
+    env:
+      API_PORT: ${service.api.port}
+      DEBUG_URL: http://localhost:$PORT_DEBUG
+      HOST: ${name}.${tld}
+    before_start: echo ${root} $${root}
+  broken:
+    cmd: bin/broken ${service.nope.url}
+`
+	if err := os.WriteFile(tmpDir+"/refs.yml", []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := apps.Load(); err != nil {
+		t.Fatalf("failed to load apps: %v", err)
+	}
+	app, _ := apps.Get("refs")
+	siblings := s.siblingEnv(app)
+
+	api, err := s.startSpec(app, s.findService(app, "api"))
+	if err != nil {
+		t.Fatalf("startSpec failed: %v", err)
+	}
+	if want := "bin/api --port " + siblings["PORT_API"]; api.Command != want {
+		t.Errorf("expected command %q, got %q", want, api.Command)
+	}
+
+	web := s.findService(app, "web")
+	spec, err := s.startSpec(app, web)
+	if err != nil {
+		t.Fatalf("startSpec failed: %v", err)
+	}
+	if spec.Dir != tmpDir+"/web" {
+		t.Errorf("expected dir %s/web, got %s", tmpDir, spec.Dir)
+	}
+	port := siblings["PORT_WEB"]
+	wantExec := []string{"bin/web", "--api", "http://api-refs.test", "--port", port, "refs.test"}
+	if !slices.Equal(spec.Opts.Exec, wantExec) {
+		t.Errorf("expected exec %q, got %q", wantExec, spec.Opts.Exec)
+	}
+	debugURL := "http://localhost:" + strconv.Itoa(s.procs.LastPort("web-refs:debug"))
+	if spec.Env["API_PORT"] != siblings["PORT_API"] || spec.Env["HOST"] != "refs.test" || spec.Env["DEBUG_URL"] != debugURL {
+		t.Errorf("expected env filled in, got %v", spec.Env)
+	}
+	if strconv.Itoa(spec.Opts.Port) != port || spec.Opts.Ports["debug"] != s.procs.LastPort("web-refs:debug") {
+		t.Errorf("expected the process pinned to the ports it's filled in with, got %d and %v", spec.Opts.Port, spec.Opts.Ports)
+	}
+	if want := "echo " + tmpDir + " ${root}"; spec.Opts.Hooks.BeforeStart != want {
+		t.Errorf("expected before_start %q, got %q", want, spec.Opts.Hooks.BeforeStart)
+	}
+	if web.Env["HOST"] != "${name}.${tld}" || web.Exec[2] != "${service.api.url}" {
+		t.Error("expected the config itself left as written")
+	}
+
+	if _, err := s.startSpec(app, s.findService(app, "broken")); err == nil || !strings.Contains(err.Error(), `unknown service "nope"`) {
+		t.Errorf("expected an unknown service error, got %v", err)
+	}
+}
+
+func TestDependencyWait(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{TLD: "test", Dir: tmpDir}
+	apps := config.NewAppStore(cfg)
+	procs := process.NewManager()
+	s := newTestServer(cfg, apps, procs)
+
+	load := func(t *testing.T, apiCmd string) (*config.App, *config.Service) {
+		t.Helper()
+		yamlContent := `
+name: waitapp
+root: ` + tmpDir + `
+shell: plain
+stop_signal: KILL
+stop_timeout: 100ms
+services:
+  api:
+    cmd: ` + apiCmd + `
+    ready: {type: file, path: api-ready}
+  web:
+    cmd: touch web-started; sleep 999
+    ready: none
+    depends_on: [api]
+`
+		os.Remove(tmpDir + "/api-ready")
+		os.Remove(tmpDir + "/web-started")
+		if err := os.WriteFile(tmpDir+"/waitapp.yml", []byte(yamlContent), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		if err := apps.Load(); err != nil {
+			t.Fatalf("failed to load apps: %v", err)
+		}
+		app, _ := apps.Get("waitapp")
+		t.Cleanup(func() {
+			s.stopServices(app, appServices(app))
+			procs.Stop("api-waitapp")
+			procs.Stop("web-waitapp")
+		})
+		return app, s.findService(app, "web")
+	}
+
+	waitFor := func(cond func() bool) bool {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if cond() {
+				return true
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return false
+	}
+
+	t.Run("starts once dependencies are running", func(t *testing.T) {
+		app, web := load(t, "sleep 0.5; touch api-ready; sleep 999")
+
+		proc, err := s.startWithDeps(app, web)
+		if err != nil || proc != nil {
+			t.Fatalf("expected web to wait, got %v, %v", proc, err)
+		}
+		if waitingFor, _, waiting := s.depWaitStatus("web-waitapp"); !waiting || !slices.Equal(waitingFor, []string{"api"}) {
+			t.Errorf("expected web to be waiting for api, got %v", waitingFor)
+		}
+		if _, err := os.Stat(tmpDir + "/web-started"); err == nil {
+			t.Error("expected web not to start before api is ready")
+		}
+
+		if !waitFor(func() bool { p, ok := procs.Get("web-waitapp"); return ok && p.IsRunning() }) {
+			t.Fatal("expected web to start once api was ready")
+		}
+		if _, _, waiting := s.depWaitStatus("web-waitapp"); waiting {
+			t.Error("expected the wait to be cleared")
+		}
+	})
+
+	t.Run("status shows what a service is waiting for", func(t *testing.T) {
+		app, web := load(t, "sleep 999")
+		s.startWithDeps(app, web)
+
+		w := httptest.NewRecorder()
+		s.handleAppStatus(w, httptest.NewRequest("GET", "/api/app-status?name=web-waitapp", nil))
+		if body := w.Body.String(); !strings.Contains(body, `"phase":"waiting_for_deps"`) || !strings.Contains(body, `"waitingFor":["api"]`) {
+			t.Errorf("expected waiting_for_deps status, got %s", body)
+		}
+		if status := string(s.getStatus()); !strings.Contains(status, `"name":"web","running":false,"starting":true,"waitingFor":["api"]`) {
+			t.Errorf("expected web to be starting and waiting for api, got %s", status)
+		}
+	})
+
+	t.Run("fails if a dependency fails", func(t *testing.T) {
+		app, web := load(t, "exit 1")
+		s.startWithDeps(app, web)
+
+		var errMsg string
+		if !waitFor(func() bool { _, errMsg, _ = s.depWaitStatus("web-waitapp"); return errMsg != "" }) {
+			t.Fatal("expected the wait to fail")
+		}
+		if !strings.HasPrefix(errMsg, "dependency api failed") {
+			t.Errorf("expected dependency failure, got %q", errMsg)
+		}
+		if _, found := procs.Get("web-waitapp"); found {
+			t.Error("expected web not to be started")
+		}
+	})
+
+	t.Run("fails after the timeout", func(t *testing.T) {
+		defer func(d time.Duration) { depsTimeout = d }(depsTimeout)
+		depsTimeout = 300 * time.Millisecond
+
+		app, web := load(t, "sleep 999")
+		s.startWithDeps(app, web)
+
+		var errMsg string
+		if !waitFor(func() bool { _, errMsg, _ = s.depWaitStatus("web-waitapp"); return errMsg != "" }) {
+			t.Fatal("expected the wait to time out")
+		}
+		if errMsg != "timed out after 300ms waiting for api" {
+			t.Errorf("unexpected error %q", errMsg)
+		}
+	})
+
+	t.Run("stopping cancels the wait", func(t *testing.T) {
+		app, web := load(t, "sleep 999")
+		s.startWithDeps(app, web)
+
+		s.handleStop(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/stop?name=waitapp:web", nil))
+		if _, _, waiting := s.depWaitStatus("web-waitapp"); waiting {
+			t.Error("expected stop to cancel the wait")
+		}
+	})
+}
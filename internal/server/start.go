@@ -0,0 +1,317 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode"
+
+	"github.com/panozzaj/roost-dev/internal/config"
+	"github.com/panozzaj/roost-dev/internal/process"
+)
+
+// startApp starts a single-command app without waiting for it to be ready
+func (s *Server) startApp(app *config.App) (*process.Process, error) {
+	spec, err := s.startSpec(app, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.procs.StartAsyncWithOptions(app.Name, spec.Command, spec.Dir, spec.Env, spec.Opts)
+}
+
+// startService starts a service of a multi-service app without waiting for it to be ready
+func (s *Server) startService(app *config.App, svc *config.Service) (*process.Process, error) {
+	spec, err := s.startSpec(app, svc)
+	if err != nil {
+		return nil, err
+	}
+	procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+	return s.procs.StartAsyncWithOptions(procName, spec.Command, spec.Dir, spec.Env, spec.Opts)
+}
+
+// effectiveEnv returns the environment a single-command app (svc nil) or a
+// service would start with, and the shell it would run under
+func (s *Server) effectiveEnv(app *config.App, svc *config.Service) ([]string, string, error) {
+	// Only a query, so ports aren't claimed for processes that never start
+	ports, err := s.procs.PredictPorts(preferredPorts(app))
+	if err != nil {
+		return nil, "", err
+	}
+	spec, err := s.specWithPorts(app, svc, ports)
+	if err != nil {
+		return nil, "", err
+	}
+	name := app.Name
+	if svc != nil {
+		name = fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+	}
+	return process.Environment(name, spec.Env, spec.Opts, ports), spec.Opts.ShellMode(), nil
+}
+
+// startSpec returns how a single-command app (svc nil) or a service starts
+// now: its options and sibling variables, with the ${...} references in its
+// config filled in. Ports for the whole app are assigned up front so
+// references to them hold once the processes start.
+func (s *Server) startSpec(app *config.App, svc *config.Service) (process.Spec, error) {
+	ports, err := s.procs.AssignPorts(preferredPorts(app))
+	if err != nil {
+		return process.Spec{}, err
+	}
+	return s.specWithPorts(app, svc, ports)
+}
+
+// specWithPorts is startSpec given the ports of the app's processes
+func (s *Server) specWithPorts(app *config.App, svc *config.Service, ports map[string]int) (process.Spec, error) {
+	spec := configSpec(app, svc)
+	name, named := app.Name, app.NamedPorts
+	if svc != nil {
+		name, named = fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name), svc.NamedPorts
+		spec.Opts.SiblingEnv = s.siblingVars(app, ports)
+	}
+	// The process must get the ports its config is filled in with
+	spec.Opts.Port = ports[name]
+	for _, portName := range named {
+		if spec.Opts.Ports == nil {
+			spec.Opts.Ports = make(map[string]int, len(named))
+		}
+		spec.Opts.Ports[portName] = ports[name+":"+portName]
+	}
+	err := interpolate(&spec, s.configVars(app, svc, ports))
+	return spec, err
+}
+
+// configSpec returns how a single-command app (svc nil) or a service starts
+// as configured, before references are filled in
+func configSpec(app *config.App, svc *config.Service) process.Spec {
+	if svc == nil {
+		return process.Spec{Command: app.Command, Dir: app.Dir, Env: app.Env, Opts: appOptions(app)}
+	}
+	return process.Spec{Command: svc.Command, Dir: svc.Dir, Env: svc.Env, Opts: serviceOptions(app, svc)}
+}
+
+// preferredPorts returns the ports an app's processes need, by process name
+// (and "<process>:<name>" for named ports), with their preferred ports
+func preferredPorts(app *config.App) map[string]int {
+	preferred := make(map[string]int)
+	add := func(name string, port int, named []string) {
+		preferred[name] = port
+		for _, portName := range named {
+			preferred[name+":"+portName] = 0
+		}
+	}
+	if app.Type == config.AppTypeCommand {
+		add(app.Name, app.PreferredPort, app.NamedPorts)
+	}
+	for _, svc := range app.Services {
+		if !svc.Worker {
+			add(fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name), svc.PreferredPort, svc.NamedPorts)
+		}
+	}
+	return preferred
+}
+
+// configVars returns what references in the config of a single-command app
+// (svc nil) or a service resolve to, given the ports of the app's processes
+func (s *Server) configVars(app *config.App, svc *config.Service, ports map[string]int) config.Vars {
+	name, named := app.Name, app.NamedPorts
+	if svc != nil {
+		name, named = fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name), svc.NamedPorts
+	}
+	vars := config.Vars{
+		Root:     app.Dir,
+		Name:     app.Name,
+		TLD:      s.cfg.TLD,
+		Port:     ports[name],
+		Services: make(map[string]config.ServiceVars, len(app.Services)),
+		Env:      make(map[string]string),
+	}
+	for _, other := range app.Services {
+		if other.Worker {
+			vars.Services[other.Name] = config.ServiceVars{}
+			continue
+		}
+		procName := fmt.Sprintf("%s-%s", slugify(other.Name), app.Name)
+		vars.Services[other.Name] = config.ServiceVars{URL: s.publicURL(procName), Port: ports[procName]}
+	}
+
+	// $PORT, $PORT_<NAME> and the sibling variables, for env values and exec args
+	if svc != nil {
+		maps.Copy(vars.Env, s.siblingVars(app, ports))
+	}
+	for _, portName := range named {
+		vars.Env[process.PortEnvName(portName)] = strconv.Itoa(ports[name+":"+portName])
+	}
+	if vars.Port != 0 {
+		vars.Env["PORT"] = strconv.Itoa(vars.Port)
+	}
+	return vars
+}
+
+// interpolate fills in the references in a spec's command, dir, env values,
+// exec args and hooks, and $PORT and friends in env values and exec args. A
+// dir that isn't absolute once filled in is relative to the app's root.
+func interpolate(spec *process.Spec, vars config.Vars) error {
+	var err error
+	for _, field := range []*string{
+		&spec.Command,
+		&spec.Dir,
+		&spec.Opts.StopCommand,
+		&spec.Opts.Hooks.BeforeStart,
+		&spec.Opts.Hooks.AfterReady,
+		&spec.Opts.Hooks.AfterStop,
+	} {
+		if *field, err = vars.Interpolate(*field); err != nil {
+			return err
+		}
+	}
+	if spec.Dir != "" && !filepath.IsAbs(spec.Dir) {
+		spec.Dir = filepath.Join(vars.Root, spec.Dir)
+	}
+
+	// Copies, since the config's own are shared
+	env := make(map[string]string, len(spec.Env))
+	for k, v := range spec.Env {
+		if env[k], err = vars.InterpolateEnv(v); err != nil {
+			return fmt.Errorf("env %s: %w", k, err)
+		}
+	}
+	spec.Env = env
+	if len(spec.Opts.Exec) > 0 {
+		// No shell sees the args, so they can use the process's env too
+		argVars := vars
+		argVars.Env = maps.Clone(env)
+		maps.Copy(argVars.Env, vars.Env)
+		argv := make([]string, len(spec.Opts.Exec))
+		for i, arg := range spec.Opts.Exec {
+			if argv[i], err = argVars.InterpolateEnv(arg); err != nil {
+				return err
+			}
+		}
+		spec.Opts.Exec = argv
+	}
+	return nil
+}
+
+// siblingEnv assigns ports for every service of a multi-service app up front
+// and returns its sibling variables (see siblingVars)
+func (s *Server) siblingEnv(app *config.App) map[string]string {
+	ports, err := s.procs.AssignPorts(preferredPorts(app))
+	if err != nil {
+		fmt.Printf("[roost-dev] Failed to assign ports for %s: %v\n", app.Name, err)
+		return nil
+	}
+	return s.siblingVars(app, ports)
+}
+
+// siblingVars returns PORT_<SVC>, URL_<SVC> and INTERNAL_URL_<SVC> for each
+// service of a multi-service app, given their ports, so services can reach
+// each other without hardcoding ports or hostnames. Workers have neither, so
+// they're left out.
+func (s *Server) siblingVars(app *config.App, ports map[string]int) map[string]string {
+	env := make(map[string]string, 3*len(app.Services))
+	for _, svc := range app.Services {
+		if svc.Worker {
+			continue
+		}
+		procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
+		suffix := envSuffix(svc.Name)
+		env["PORT_"+suffix] = strconv.Itoa(ports[procName])
+		env["URL_"+suffix] = s.publicURL(procName)
+		env["INTERNAL_URL_"+suffix] = fmt.Sprintf("http://127.0.0.1:%d", ports[procName])
+	}
+	return env
+}
+
+// envSuffix turns a service name into an environment variable suffix (web-ui → WEB_UI)
+func envSuffix(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, name)
+}
+
+// appOptions returns the process options for a single-command app
+func appOptions(app *config.App) process.Options {
+	opts := processOptions(app.ProcessConfig)
+	opts.PreferredPort = app.PreferredPort
+	opts.NamedPorts = app.NamedPorts
+	opts.Watch = watchSpec(app.Watch)
+	opts.Exec = app.Exec
+	return opts
+}
+
+// serviceOptions returns the process options for a service of a multi-service app
+func serviceOptions(app *config.App, svc *config.Service) process.Options {
+	opts := processOptions(svc.ProcessConfig)
+	opts.PreferredPort = svc.PreferredPort
+	opts.NamedPorts = svc.NamedPorts
+	opts.Watch = watchSpec(svc.Watch)
+	opts.Exec = svc.Exec
+	opts.NoPort = svc.Worker
+	for _, dep := range svc.DependsOn {
+		opts.DependsOn = append(opts.DependsOn, fmt.Sprintf("%s-%s", slugify(dep), app.Name))
+	}
+	return opts
+}
+
+// processOptions converts the process settings shared by apps and services
+func processOptions(pc config.ProcessConfig) process.Options {
+	return process.Options{
+		Restart:     restartPolicy(pc.Restart),
+		StopSignal:  syscall.Signal(pc.StopSignal),
+		StopTimeout: pc.StopTimeout,
+		StopCommand: pc.StopCommand,
+		Shell:       pc.Shell,
+		CleanEnv:    pc.InheritEnv.Clean(),
+		EnvAllow:    pc.InheritEnv.Allow,
+		PIDFile:     pc.PIDFile,
+		Limits: process.Limits{
+			Memory:    int64(pc.Limits.Memory),
+			OpenFiles: pc.Limits.OpenFiles,
+			Nice:      pc.Limits.Nice,
+			IOClass:   pc.Limits.IONice.Class,
+			IOLevel:   pc.Limits.IONice.Level,
+		},
+		Hooks: process.Hooks{
+			BeforeStart: pc.BeforeStart,
+			AfterReady:  pc.AfterReady,
+			AfterStop:   pc.AfterStop,
+		},
+		Ready: process.ReadyCheck{
+			Type:    pc.Ready.Type,
+			Path:    pc.Ready.Path,
+			Status:  pc.Ready.Status,
+			Port:    pc.Ready.Port,
+			Command: pc.Ready.Command,
+			Pattern: pc.Ready.Regexp,
+			Timeout: pc.Ready.Timeout,
+		},
+	}
+}
+
+// watchSpec converts a watch config for the process manager (nil = not watched)
+func watchSpec(w config.WatchConfig) *process.WatchSpec {
+	if !w.Enabled {
+		return nil
+	}
+	return &process.WatchSpec{
+		Include:  w.Include,
+		Exclude:  w.Exclude,
+		Debounce: w.Debounce,
+	}
+}
+
+// restartPolicy converts a configured restart policy for the process manager
+func restartPolicy(r config.RestartConfig) process.RestartPolicy {
+	return process.RestartPolicy{
+		Mode:       r.Policy,
+		MaxRetries: r.MaxRetries,
+		Backoff:    r.Backoff,
+		MaxBackoff: r.MaxBackoff,
+	}
+}
@@ -4,38 +4,56 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 
 	"github.com/panozzaj/roost-dev/internal/config"
 )
 
 // serviceStatus represents the status of a single service
 type serviceStatus struct {
-	Name     string `json:"name"`
-	Running  bool   `json:"running"`
-	Starting bool   `json:"starting,omitempty"`
-	Failed   bool   `json:"failed,omitempty"`
-	Error    string `json:"error,omitempty"`
-	Port     int    `json:"port,omitempty"`
-	Uptime   string `json:"uptime,omitempty"`
-	Default  bool   `json:"default,omitempty"`
-	URL      string `json:"url,omitempty"`
+	Name           string         `json:"name"`
+	Running        bool           `json:"running"`
+	Starting       bool           `json:"starting,omitempty"`
+	WaitingFor     []string       `json:"waitingFor,omitempty"` // dependencies it's waiting for before starting
+	Failed         bool           `json:"failed,omitempty"`
+	CrashLooping   bool           `json:"crashLooping,omitempty"`
+	StoppedIdle    bool           `json:"stoppedIdle,omitempty"` // stopped after idle_timeout with no requests
+	Error          string         `json:"error,omitempty"`
+	Restarts       int            `json:"restarts,omitempty"`
+	Port           int            `json:"port,omitempty"`
+	Ports          map[string]int `json:"ports,omitempty"` // named extra ports (livereload, debug, ...)
+	Uptime         string         `json:"uptime,omitempty"`
+	Metrics        *metricsStatus `json:"metrics,omitempty"`        // CPU and memory at the last sample
+	RestartTrigger string         `json:"restartTrigger,omitempty"` // watched file that caused the last restart
+	Default        bool           `json:"default,omitempty"`
+	Worker         bool           `json:"worker,omitempty"` // no port or URL
+	URL            string         `json:"url,omitempty"`
 }
 
 // appStatus represents the status of an app
 type appStatus struct {
-	Name        string          `json:"name"`
-	Description string          `json:"description,omitempty"`
-	Aliases     []string        `json:"aliases,omitempty"`
-	Type        string          `json:"type"`
-	URL         string          `json:"url"`
-	Running     bool            `json:"running,omitempty"`
-	Starting    bool            `json:"starting,omitempty"`
-	Failed      bool            `json:"failed,omitempty"`
-	Error       string          `json:"error,omitempty"`
-	Port        int             `json:"port,omitempty"`
-	Uptime      string          `json:"uptime,omitempty"`
-	Services    []serviceStatus `json:"services,omitempty"`
-	Warnings    []string        `json:"warnings,omitempty"`
+	Name           string              `json:"name"`
+	Description    string              `json:"description,omitempty"`
+	Aliases        []string            `json:"aliases,omitempty"`
+	Profile        string              `json:"profile,omitempty"`  // Active profile
+	Profiles       []config.Profile    `json:"profiles,omitempty"` // Profiles to switch to
+	Type           string              `json:"type"`
+	URL            string              `json:"url"`
+	Running        bool                `json:"running,omitempty"`
+	Starting       bool                `json:"starting,omitempty"`
+	Failed         bool                `json:"failed,omitempty"`
+	CrashLooping   bool                `json:"crashLooping,omitempty"`
+	StoppedIdle    bool                `json:"stoppedIdle,omitempty"`
+	Error          string              `json:"error,omitempty"`
+	Restarts       int                 `json:"restarts,omitempty"`
+	Port           int                 `json:"port,omitempty"`
+	Ports          map[string]int      `json:"ports,omitempty"`
+	Uptime         string              `json:"uptime,omitempty"`
+	Metrics        *metricsStatus      `json:"metrics,omitempty"`
+	RestartTrigger string              `json:"restartTrigger,omitempty"`
+	Services       []serviceStatus     `json:"services,omitempty"`
+	Warnings       []string            `json:"warnings,omitempty"`
+	Diagnostics    []config.Diagnostic `json:"diagnostics,omitempty"` // Problems in the app's config file
 }
 
 // reservedTailscalePaths are path prefixes reserved for roost-dev internal use.
@@ -64,16 +82,26 @@ func (s *Server) getStatus() []byte {
 		return fmt.Sprintf("http://%s.%s:%d", name, s.cfg.TLD, s.cfg.URLPort)
 	}
 
+	diagnostics := make(map[string][]config.Diagnostic)
+	for _, d := range s.apps.Diagnostics() {
+		diagnostics[d.App] = append(diagnostics[d.App], d)
+	}
+
 	for _, app := range s.apps.All() {
 		if app.Hidden {
+			delete(diagnostics, app.Name)
 			continue
 		}
 		as := appStatus{
 			Name:        app.Name,
 			Description: app.Description,
 			Aliases:     app.Aliases,
+			Profile:     app.Profile,
+			Profiles:    app.Profiles,
 			URL:         baseURL(app.Name),
+			Diagnostics: diagnostics[app.Name],
 		}
+		delete(diagnostics, app.Name)
 
 		// Check for reserved Tailscale path conflicts
 		if isReservedTailscalePath(app.Name) {
@@ -95,18 +123,26 @@ func (s *Server) getStatus() []byte {
 
 		case config.AppTypeCommand:
 			as.Type = "command"
+			as.RestartTrigger = s.procs.RestartTrigger(app.Name)
 			if proc, found := s.procs.Get(app.Name); found {
+				as.Restarts = proc.Restarts()
 				if proc.IsRunning() {
 					as.Running = true
 					as.Port = proc.Port
+					as.Ports = proc.Ports
 					as.Uptime = proc.Uptime().Round(1e9).String()
+					as.Metrics = newMetricsStatus(proc)
 				} else if proc.IsStarting() {
 					as.Starting = true
 					as.Port = proc.Port
+					as.Ports = proc.Ports
 				} else if proc.HasFailed() {
 					as.Failed = true
+					as.CrashLooping = proc.IsCrashLooping()
 					as.Error = proc.ExitError()
 				}
+			} else {
+				as.StoppedIdle = s.procs.IsIdleStopped(app.Name)
 			}
 
 		case config.AppTypeStatic:
@@ -117,28 +153,46 @@ func (s *Server) getStatus() []byte {
 			as.Type = "multi-service"
 			// Keep base URL (app.test) - default service routes there automatically
 			for _, svc := range app.Services {
-				ss := serviceStatus{Name: svc.Name, Default: svc.Default}
+				ss := serviceStatus{Name: svc.Name, Default: svc.Default, Worker: svc.Worker}
 				procName := fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name)
-				// Set service URL
-				if app.Name == "roost-dev-tests" {
+				// Set service URL (workers aren't routed, so they have none)
+				switch {
+				case svc.Worker:
+				case app.Name == "roost-dev-tests":
 					ss.URL = fmt.Sprintf("http://%s.roost-dev.%s", svc.Name, s.cfg.TLD)
-				} else if svc.Default {
+				case svc.Default:
 					ss.URL = baseURL(app.Name)
-				} else {
+				default:
 					ss.URL = baseURL(fmt.Sprintf("%s-%s", slugify(svc.Name), app.Name))
 				}
-				if proc, found := s.procs.Get(procName); found {
+				ss.RestartTrigger = s.procs.RestartTrigger(procName)
+				if waitingFor, errMsg, waiting := s.depWaitStatus(procName); waiting {
+					if errMsg != "" {
+						ss.Failed = true
+						ss.Error = errMsg
+					} else {
+						ss.Starting = true
+						ss.WaitingFor = waitingFor
+					}
+				} else if proc, found := s.procs.Get(procName); found {
+					ss.Restarts = proc.Restarts()
 					if proc.IsRunning() {
 						ss.Running = true
 						ss.Port = proc.Port
+						ss.Ports = proc.Ports
 						ss.Uptime = proc.Uptime().Round(1e9).String()
+						ss.Metrics = newMetricsStatus(proc)
 					} else if proc.IsStarting() {
 						ss.Starting = true
 						ss.Port = proc.Port
+						ss.Ports = proc.Ports
 					} else if proc.HasFailed() {
 						ss.Failed = true
+						ss.CrashLooping = proc.IsCrashLooping()
 						ss.Error = proc.ExitError()
 					}
+				} else {
+					ss.StoppedIdle = s.procs.IsIdleStopped(procName)
 				}
 				as.Services = append(as.Services, ss)
 			}
@@ -147,6 +201,19 @@ func (s *Server) getStatus() []byte {
 		status = append(status, as)
 	}
 
+	// Configs that failed to load show up with what's wrong with them
+	for name, diags := range diagnostics {
+		as := appStatus{Name: name, Type: "invalid", Failed: true, Diagnostics: diags}
+		for _, d := range diags {
+			if d.Severity == config.SeverityError {
+				as.Error = d.Message
+				break
+			}
+		}
+		status = append(status, as)
+	}
+	sort.SliceStable(status, func(i, j int) bool { return status[i].Name < status[j].Name })
+
 	data, _ := json.Marshal(status)
 	return data
 }
@@ -537,7 +537,133 @@ function tt(text) {
     return 'data-tooltip="' + text + '"'
 }
 
-// Icon button helper - generates button HTML with tooltip
+// Port span for an app or service, listing named extra ports in the tooltip
+function portSpan(item) {
+    if (!item.port) return '<span class="app-port"></span>'
+    var names = Object.keys(item.ports || {}).sort()
+    if (!names.length) return '<span class="app-port">:' + item.port + '</span>'
+    var extra = names.map(function (name) {
+        return name + ' :' + item.ports[name]
+    })
+    return '<span class="app-port" ' + tt(extra.join(', ')) + '>:' + item.port + ' +' + names.length + '</span>'
+}
+
+// Link to a service, or a label for workers, which have no URL
+function serviceLink(svc) {
+    if (svc.worker) return '<span class="app-worker" ' + tt('Runs without a port or URL') + '>worker</span>'
+    return (
+        '<a class="app-url" href="' +
+        fixProtocol(svc.url) +
+        '" target="_blank" rel="noopener">' +
+        svc.url.replace(/^https?:\/\//, '') +
+        '</a>'
+    )
+}
+
+// Watched file whose change caused the last restart, if any
+function triggerSpan(item) {
+    if (!item.restartTrigger) return ''
+    var name = item.restartTrigger.split('/').pop()
+    return (
+        '<span class="app-trigger" ' +
+        tt(escapeHtml('Restarted after ' + item.restartTrigger + ' changed')) +
+        '>\u21bb ' +
+        escapeHtml(name) +
+        '</span>'
+    )
+}
+
+// Human-readable memory size (12.5 MB, 1.2 GB)
+function formatMemory(bytes) {
+    var units = ['GB', 'MB', 'KB']
+    for (var i = 0; i < units.length; i++) {
+        var size = Math.pow(1024, 3 - i)
+        if (bytes >= size) return (bytes / size).toFixed(1) + ' ' + units[i]
+    }
+    return bytes + ' B'
+}
+
+// CPU and memory of a running app or service, with process counts in the tooltip
+function metricsSpan(item) {
+    var m = item.metrics
+    if (!m) return ''
+    var detail = m.children + (m.children === 1 ? ' child process' : ' child processes')
+    if (m.threads) detail += ', ' + m.threads + ' threads'
+    return (
+        '<span class="app-metrics" ' +
+        tt(escapeHtml(detail)) +
+        '>' +
+        m.cpu.toFixed(1) +
+        '% \u00b7 ' +
+        formatMemory(m.memory) +
+        '</span>'
+    )
+}
+
+function diagnosticPosition(d) {
+    var pos = d.file.split('/').pop()
+    if (d.line) pos += ':' + d.line + (d.column ? ':' + d.column : '')
+    return pos
+}
+
+function diagnosticsList(app) {
+    if (!app.diagnostics || !app.diagnostics.length) return ''
+    return (
+        '<ul class="app-diagnostics">' +
+        app.diagnostics
+            .map(function (d) {
+                return (
+                    '<li class="diagnostic ' +
+                    d.severity +
+                    '"><span class="diagnostic-pos">' +
+                    escapeHtml(diagnosticPosition(d)) +
+                    '</span> ' +
+                    escapeHtml(d.message) +
+                    '</li>'
+                )
+            })
+            .join('') +
+        '</ul>'
+    )
+}
+
+// The profiles an app can switch to, starting with the config as written
+function profileOptions(app) {
+    if (!app.profiles || !app.profiles.length) return []
+    return [{ name: 'default', description: 'The config as written', active: !app.profile }].concat(
+        app.profiles.map(function (p) {
+            return { name: p.name, description: p.description || '', active: p.name === app.profile }
+        })
+    )
+}
+
+function profileMenu(app) {
+    var options = profileOptions(app)
+    if (!options.length) return ''
+    return (
+        '<span class="app-settings-section">Profile</span>' +
+        options
+            .map(function (p) {
+                return (
+                    '<button class="app-settings-action app-settings-profile' +
+                    (p.active ? ' active' : '') +
+                    '" onclick="event.stopPropagation(); switchProfile(\'' +
+                    app.name +
+                    "', '" +
+                    escapeHtml(p.name) +
+                    '\', event)" ' +
+                    (p.description ? tt(escapeHtml(p.description).replace(/"/g, '&quot;')) : '') +
+                    '>' +
+                    (p.active ? ICONS.checkGreen : '<span class="profile-spacer"></span>') +
+                    ' ' +
+                    escapeHtml(p.name) +
+                    '</button>'
+                )
+            })
+            .join('')
+    )
+}
+
 function iconBtn(opts) {
     var classes = [opts.className]
     if (opts.visible === false) classes.push('hidden')
@@ -766,6 +892,9 @@ function renderApp(app) {
                     var svcStatus = getServiceStatus(svc)
                     var svcTooltip =
                         { failed: 'Failed', running: 'Running', starting: 'Starting', idle: 'Idle' }[svcStatus] || ''
+                    if (svc.crashLooping) svcTooltip = 'Crash-looping'
+                    if (svc.waitingFor) svcTooltip = 'Waiting for ' + escapeHtml(svc.waitingFor.join(', '))
+                    if (svcStatus === 'idle' && svc.stoppedIdle) svcTooltip = 'Stopped (idle)'
                     var svcSlug = slugify(svc.name)
                     var svcName = svcSlug + '-' + app.name
                     return (
@@ -806,17 +935,13 @@ function renderApp(app) {
                         (svc.error ? '<span class="app-error">' + svc.error + '</span>' : '') +
                         '</div>' +
                         '<div class="service-meta">' +
-                        '<span class="app-port">' +
-                        (svc.port ? ':' + svc.port : '') +
-                        '</span>' +
+                        portSpan(svc) +
                         '<span class="app-uptime">' +
-                        (svc.uptime || '') +
+                        (svc.uptime || (svcStatus === 'idle' && svc.stoppedIdle ? 'stopped (idle)' : '')) +
                         '</span>' +
-                        '<a class="app-url" href="' +
-                        fixProtocol(svc.url) +
-                        '" target="_blank" rel="noopener">' +
-                        svc.url.replace(/^https?:\/\//, '') +
-                        '</a>' +
+                        metricsSpan(svc) +
+                        triggerSpan(svc) +
+                        serviceLink(svc) +
                         '</div>' +
                         '</div>'
                     )
@@ -826,11 +951,28 @@ function renderApp(app) {
     }
 
     var statusTooltip = { failed: 'Failed', running: 'Running', starting: 'Starting', idle: 'Idle' }[statusClass] || ''
+    var isCrashLooping =
+        app.crashLooping ||
+        (app.services &&
+            app.services.some(function (s) {
+                return s.crashLooping
+            }))
+    if (isCrashLooping) statusTooltip = 'Crash-looping'
+    var isStoppedIdle =
+        statusClass === 'idle' &&
+        (app.stoppedIdle ||
+            (app.services &&
+                app.services.some(function (s) {
+                    return s.stoppedIdle
+                })))
+    if (isStoppedIdle) statusTooltip = 'Stopped (idle)'
 
     var statusIndicator =
         app.type === 'static'
             ? '<div class="status-placeholder"></div>'
-            : '<div class="status-dot-wrapper">' +
+            : app.type === 'invalid'
+              ? '<div class="status-dot-wrapper"><div class="status-dot failed" data-tooltip="Config error"></div></div>'
+              : '<div class="status-dot-wrapper">' +
               '<div class="status-dot ' +
               statusClass +
               '" data-tooltip="' +
@@ -875,14 +1017,17 @@ function renderApp(app) {
         (app.aliases && app.aliases.length
             ? '<span class="app-aliases">aka ' + app.aliases.join(', ') + '</span>'
             : '') +
+        (app.profile
+            ? '<span class="app-profile" ' + tt('Profile') + '>' + escapeHtml(app.profile) + '</span>'
+            : '') +
         '</div>' +
         '<div class="app-meta">' +
-        '<span class="app-port">' +
-        (app.port ? ':' + app.port : '') +
-        '</span>' +
+        portSpan(app) +
         '<span class="app-uptime">' +
-        (app.uptime || '') +
+        (app.uptime || (isStoppedIdle ? 'stopped (idle)' : '')) +
         '</span>' +
+        metricsSpan(app) +
+        triggerSpan(app) +
         '<div class="app-settings-dropdown">' +
         '<button class="app-settings-btn" onclick="event.stopPropagation(); toggleAppSettings(\'' +
         app.name +
@@ -905,9 +1050,10 @@ function renderApp(app) {
         '\', event)">' +
         ICONS.externalLink +
         ' Open in editor</button>' +
+        profileMenu(app) +
         '</div>' +
         '</div>' +
-        (!(app.services && app.services.length) ||
+        ((app.type !== 'invalid' && !(app.services && app.services.length)) ||
         (app.services &&
             app.services.some(function (s) {
                 return s.default
@@ -922,6 +1068,7 @@ function renderApp(app) {
             : '') +
         '</div>' +
         '</div>' +
+        diagnosticsList(app) +
         servicesHTML +
         '<div class="logs-panel" id="logs-' +
         app.name +
@@ -1237,6 +1384,32 @@ function openAppConfig(name, event) {
         })
 }
 
+function switchProfile(name, profile, event) {
+    var btn = event.target.closest('button')
+    var origHTML = btn.innerHTML
+    btn.disabled = true
+    fetch('/api/profile?name=' + encodeURIComponent(name) + '&profile=' + encodeURIComponent(profile))
+        .then(function (response) {
+            if (!response.ok) {
+                return response.text().then(function (text) {
+                    throw new Error(text.trim())
+                })
+            }
+            // The status update redraws the menu with the new profile checked
+            var menu = document.getElementById('app-settings-menu-' + name)
+            menu.classList.remove('open')
+            menu.previousElementSibling.classList.remove('open')
+        })
+        .catch(function (err) {
+            console.error('Failed to switch profile:', err)
+            btn.innerHTML = ICONS.xRed + ' ' + escapeHtml(err.message)
+            setTimeout(function () {
+                btn.innerHTML = origHTML
+                btn.disabled = false
+            }, 3000)
+        })
+}
+
 // Close app settings menu when clicking outside
 document.addEventListener('click', function (e) {
     if (!e.target.closest('.app-settings-dropdown')) {
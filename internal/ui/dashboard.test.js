@@ -35,6 +35,30 @@ function matchesFilter(text, normalizedQuery) {
     return normalizeForSearch(text).indexOf(normalizedQuery) !== -1
 }
 
+function formatMemory(bytes) {
+    var units = ['GB', 'MB', 'KB']
+    for (var i = 0; i < units.length; i++) {
+        var size = Math.pow(1024, 3 - i)
+        if (bytes >= size) return (bytes / size).toFixed(1) + ' ' + units[i]
+    }
+    return bytes + ' B'
+}
+
+function diagnosticPosition(d) {
+    var pos = d.file.split('/').pop()
+    if (d.line) pos += ':' + d.line + (d.column ? ':' + d.column : '')
+    return pos
+}
+
+function profileOptions(app) {
+    if (!app.profiles || !app.profiles.length) return []
+    return [{ name: 'default', description: 'The config as written', active: !app.profile }].concat(
+        app.profiles.map(function (p) {
+            return { name: p.name, description: p.description || '', active: p.name === app.profile }
+        })
+    )
+}
+
 // Tests for normalizeForSearch
 console.log('\n=== normalizeForSearch ===')
 assertEqual(normalizeForSearch('hello'), 'hello', 'lowercase passthrough')
@@ -73,6 +97,28 @@ assert(matchesFilter('android-assistant', normalizeForSearch('android_assist')),
 assert(matchesFilter('my-cool-app', normalizeForSearch('my cool')), 'query: "my cool"')
 assert(matchesFilter('foo_bar_service', normalizeForSearch('bar service')), 'query: "bar service"')
 
+// Tests for formatMemory
+console.log('\n=== formatMemory ===')
+assertEqual(formatMemory(512), '512 B', 'bytes')
+assertEqual(formatMemory(2048), '2.0 KB', 'kilobytes')
+assertEqual(formatMemory(157286400), '150.0 MB', 'megabytes')
+assertEqual(formatMemory(1610612736), '1.5 GB', 'gigabytes')
+
+// Tests for diagnosticPosition
+console.log('\n=== diagnosticPosition ===')
+assertEqual(diagnosticPosition({ file: '/home/me/.config/roost-dev/shop.yml', line: 9, column: 7 }), 'shop.yml:9:7', 'line and column')
+assertEqual(diagnosticPosition({ file: '/code/shop/roost.yml', line: 3 }), 'roost.yml:3', 'line only')
+assertEqual(diagnosticPosition({ file: '/code/shop/roost.yml' }), 'roost.yml', 'whole file')
+
+// Tests for profileOptions
+console.log('\n=== profileOptions ===')
+assertEqual(profileOptions({ name: 'shop' }).length, 0, 'no profiles, no options')
+var options = profileOptions({ name: 'shop', profiles: [{ name: 'staging', description: 'Staging DB' }, { name: 'prod' }] })
+assertEqual(options.map(function (p) { return p.name }).join(','), 'default,staging,prod', 'default comes first')
+assert(options[0].active && !options[1].active, 'default is active without a profile')
+options = profileOptions({ name: 'shop', profile: 'prod', profiles: [{ name: 'staging' }, { name: 'prod' }] })
+assert(!options[0].active && options[2].active, 'active profile is marked')
+
 // Summary
 console.log('\n=== Summary ===')
 console.log('Passed:', passed)